@@ -0,0 +1,146 @@
+package main
+
+// 一次性把旧格式（整文件 JSON）的追踪/新闻数据转换为 NDJSON 格式
+// 用法: go run ./cmd/convert-tracking [-tracking-dir data/tracking] [-news-dir data/news] [-keep] [-dry-run]
+// 请在主服务停止后运行，避免与刷新任务同时写同一个文件
+import (
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	trackingDir := flag.String("tracking-dir", storage.TrackingDir(), "追踪数据根目录")
+	newsDir := flag.String("news-dir", storage.NewsDir(), "新闻浏览记录根目录")
+	keep := flag.Bool("keep", false, "转换成功后保留旧的 .json 文件")
+	dryRun := flag.Bool("dry-run", false, "只列出需要转换的文件，不做修改")
+	flag.Parse()
+
+	converted, failed := 0, 0
+
+	trackingFiles, err := findLegacyFiles(*trackingDir)
+	if err != nil {
+		log.Fatalf("扫描追踪数据目录失败: %v", err)
+	}
+	for _, path := range trackingFiles {
+		if *dryRun {
+			fmt.Println("[tracking]", path)
+			continue
+		}
+		if err := convertTrackingFile(path, *keep); err != nil {
+			log.Printf("转换 %s 失败: %v", path, err)
+			failed++
+			continue
+		}
+		converted++
+	}
+
+	newsFiles, err := findLegacyFiles(*newsDir)
+	if err != nil {
+		log.Fatalf("扫描新闻数据目录失败: %v", err)
+	}
+	for _, path := range newsFiles {
+		if *dryRun {
+			fmt.Println("[news]", path)
+			continue
+		}
+		if err := convertNewsFile(path, *keep); err != nil {
+			log.Printf("转换 %s 失败: %v", path, err)
+			failed++
+			continue
+		}
+		converted++
+	}
+
+	if *dryRun {
+		fmt.Printf("共 %d 个文件需要转换\n", len(trackingFiles)+len(newsFiles))
+		return
+	}
+
+	fmt.Printf("转换完成: 成功 %d 个, 失败 %d 个\n", converted, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// findLegacyFiles 查找 <dir>/<date>/<user>.json 形式的旧格式文件
+func findLegacyFiles(dir string) ([]string, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return filepath.Glob(filepath.Join(dir, "*", "*"+storage.LegacyJSONExt))
+}
+
+func convertTrackingFile(path string, keep bool) error {
+	var batch models.UserTrackingBatchRecord
+	if err := readLegacyFile(path, &batch); err != nil {
+		return err
+	}
+	return rewriteAsNDJSON(path, storage.TrackingHeader(), batch.Records, keep)
+}
+
+func convertNewsFile(path string, keep bool) error {
+	var batch models.UserNewsBatchRecord
+	if err := readLegacyFile(path, &batch); err != nil {
+		return err
+	}
+	return rewriteAsNDJSON(path, storage.NewsHeader(), batch.Records, keep)
+}
+
+func readLegacyFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("解析旧格式失败: %w", err)
+	}
+	return nil
+}
+
+// rewriteAsNDJSON 生成 <user>.ndjson：旧记录在前，已存在的 NDJSON 记录（更新的数据）在后
+// 先写临时文件再原子重命名，中途失败不会影响原有文件
+func rewriteAsNDJSON[T any](legacyPath string, header storage.FileHeader, legacy []T, keep bool) error {
+	target := strings.TrimSuffix(legacyPath, storage.LegacyJSONExt) + storage.NDJSONExt
+	tmpPath := target + ".converting"
+	os.Remove(tmpPath)
+
+	records := append([]T(nil), legacy...)
+	if _, err := os.Stat(target); err == nil {
+		err := storage.ReadFile(target, func(_ storage.FileHeader, record T) error {
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("读取已有的 NDJSON 文件失败: %w", err)
+		}
+	}
+
+	if len(records) == 0 {
+		if keep {
+			return nil
+		}
+		return os.Remove(legacyPath)
+	}
+
+	if err := storage.AppendRecords(tmpPath, header, records); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("替换目标文件失败: %w", err)
+	}
+
+	log.Printf("已转换 %s -> %s（%d 条记录）", legacyPath, target, len(legacy))
+	if keep {
+		return nil
+	}
+	return os.Remove(legacyPath)
+}
@@ -0,0 +1,137 @@
+package main
+
+// 解密上传器生成的加密归档（<批次>.zip.enc）
+// 用法: go run ./cmd/decrypt-archive -in <批次>.zip.enc [-out <批次>.zip] [-manifest <批次>.manifest.json] [-key <主密钥>] [-key-id local-1]
+//
+//	go run ./cmd/decrypt-archive -in <批次>.zip.enc -info   # 只查看文件头（主密钥标识等），不需要密钥
+//
+// 主密钥默认读取 ARCHIVE_MASTER_KEY / ARCHIVE_KEY_ID 环境变量。与输入同目录的清单会自动用于校验明文压缩包。
+import (
+	"NewsEyeTracking/internal/envelope"
+	"NewsEyeTracking/internal/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	in := flag.String("in", "", "加密归档路径")
+	out := flag.String("out", "", "输出路径，默认为去掉 .enc 后缀")
+	manifestPath := flag.String("manifest", "", "批次清单路径，默认查找同目录的 <批次>.manifest.json")
+	keyValue := flag.String("key", "", "主密钥（base64 或十六进制），默认读取 ARCHIVE_MASTER_KEY")
+	keyID := flag.String("key-id", "", "主密钥标识，默认读取 ARCHIVE_KEY_ID，未设置时为 local-1")
+	info := flag.Bool("info", false, "只打印文件头")
+	flag.Parse()
+
+	if *in == "" {
+		log.Fatal("必须提供 -in")
+	}
+
+	if *info {
+		file, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("打开文件失败: %v", err)
+		}
+		defer file.Close()
+		header, err := envelope.ReadHeader(file)
+		if err != nil {
+			log.Fatalf("读取文件头失败: %v", err)
+		}
+		fmt.Printf("算法: %s\n主密钥: %s\n分块大小: %d\n", header.Algorithm, header.KeyID, header.ChunkSize)
+		return
+	}
+
+	keys, err := loadKeys(*keyValue, *keyID)
+	if err != nil {
+		log.Fatalf("加载主密钥失败: %v", err)
+	}
+
+	if *out == "" {
+		*out = strings.TrimSuffix(*in, envelope.FileExt)
+		if *out == *in {
+			*out = *in + ".decrypted"
+		}
+	}
+
+	if _, err := envelope.DecryptFile(*in, *out, keys); err != nil {
+		log.Fatalf("解密失败: %v", err)
+	}
+	fmt.Printf("已解密: %s\n", *out)
+
+	if *manifestPath == "" {
+		candidate := strings.TrimSuffix(strings.TrimSuffix(*in, envelope.FileExt), ".zip") + utils.ManifestSuffix
+		if _, err := os.Stat(candidate); err == nil {
+			*manifestPath = candidate
+		}
+	}
+	if *manifestPath == "" {
+		fmt.Println("未找到清单，跳过明文校验")
+		return
+	}
+
+	if err := verifyPlaintext(*out, *manifestPath); err != nil {
+		log.Fatalf("明文校验失败: %v", err)
+	}
+	fmt.Printf("明文压缩包与清单 %s 一致\n", *manifestPath)
+}
+
+func loadKeys(value, keyID string) (envelope.KeyWrapper, error) {
+	if value == "" {
+		if keyID != "" {
+			os.Setenv("ARCHIVE_KEY_ID", keyID)
+		}
+		return envelope.FromEnv()
+	}
+
+	key, err := envelope.ParseMasterKey(value)
+	if err != nil {
+		return nil, err
+	}
+	if keyID == "" {
+		keyID = os.Getenv("ARCHIVE_KEY_ID")
+	}
+	if keyID == "" {
+		keyID = "local-1"
+	}
+	return envelope.NewLocalKeyWrapper(keyID, key)
+}
+
+// verifyPlaintext 用清单中记录的大小和 SHA-256 校验解密后的压缩包
+func verifyPlaintext(path, manifestPath string) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	var manifest utils.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("解析清单失败: %w", err)
+	}
+	if manifest.Encryption == nil {
+		return fmt.Errorf("清单中没有加密信息")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+	if size != manifest.Encryption.PlaintextSize {
+		return fmt.Errorf("大小不一致: 清单 %d，实际 %d", manifest.Encryption.PlaintextSize, size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != manifest.Encryption.PlaintextSHA256 {
+		return fmt.Errorf("SHA-256 不一致: 清单 %s，实际 %s", manifest.Encryption.PlaintextSHA256, sum)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+// 签发实验者令牌，用于访问 /api/v1/admin 下的接口
+// 用法: ADMIN_JWT_SECRET=... go run ./cmd/experimenter-token -name alice -ttl 720h
+import (
+	"NewsEyeTracking/internal/api/middleware"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	name := flag.String("name", "", "实验者名称（写入令牌的 subject）")
+	ttl := flag.Duration("ttl", 7*24*time.Hour, "令牌有效期")
+	flag.Parse()
+
+	godotenv.Load(".env")
+
+	if *name == "" {
+		log.Fatal("请通过 -name 指定实验者名称")
+	}
+
+	secret := os.Getenv("ADMIN_JWT_SECRET")
+	if secret == "" {
+		log.Fatal("ADMIN_JWT_SECRET 环境变量未设置")
+	}
+
+	token, err := middleware.MakeExperimenterJWT(*name, secret, *ttl)
+	if err != nil {
+		log.Fatalf("签发令牌失败: %v", err)
+	}
+
+	fmt.Println(token)
+}
@@ -0,0 +1,111 @@
+package main
+
+// 把一段日期内的追踪数据和新闻浏览记录导出为 Parquet 文件（eye_samples / clicks / scrolls / news_exposures）
+// 用法: go run ./cmd/export-parquet -from 2026-10-01 [-to 2026-10-07] [-out data/exports/20261001_20261007]
+//
+//	[-tracking-dir data/tracking] [-news-dir data/news] [-db <DB_URL>]
+//
+// 会话的文章和设备信息从数据库 reading_sessions 读取，默认使用 DB_URL 环境变量；-db "" 时只导出会话ID和用户ID
+// TRACKING_SINK 为 postgres 时追踪数据从 eye_samples / clicks / scrolls 表读取，此时必须能连接数据库；
+// both 模式下文件是主落地目标（数据库写入失败只记录日志），仍从文件读取
+import (
+	"NewsEyeTracking/internal/database"
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/export"
+	"NewsEyeTracking/internal/storage"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	fromValue := flag.String("from", "", "开始日期（YYYY-MM-DD）")
+	toValue := flag.String("to", "", "结束日期（YYYY-MM-DD，含），默认与开始日期相同")
+	out := flag.String("out", "", "输出目录，默认为 data/exports/<开始>_<结束>")
+	trackingDir := flag.String("tracking-dir", storage.TrackingDir(), "追踪数据根目录")
+	newsDir := flag.String("news-dir", storage.NewsDir(), "新闻浏览记录根目录")
+	dbURL := flag.String("db", os.Getenv("DB_URL"), "数据库连接串，用于读取会话元信息")
+	flag.Parse()
+
+	if *fromValue == "" {
+		log.Fatal("必须提供 -from")
+	}
+	from, err := time.ParseInLocation("2006-01-02", *fromValue, time.Local)
+	if err != nil {
+		log.Fatalf("-from 无效: %v", err)
+	}
+	to := from
+	if *toValue != "" {
+		if to, err = time.ParseInLocation("2006-01-02", *toValue, time.Local); err != nil {
+			log.Fatalf("-to 无效: %v", err)
+		}
+	}
+	if to.Before(from) {
+		log.Fatal("-to 不能早于 -from")
+	}
+	if *out == "" {
+		*out = filepath.Join("data/exports", from.Format("20060102")+"_"+to.Format("20060102"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := export.Options{From: from, To: to, TrackingDir: *trackingDir, NewsDir: *newsDir, OutDir: *out}
+	trackingMode, err := storage.SinkMode()
+	if err != nil {
+		log.Fatal(err)
+	}
+	trackingInDatabase := trackingMode == storage.SinkPostgres
+	if trackingInDatabase && *dbURL == "" {
+		log.Fatal("TRACKING_SINK=postgres 时追踪数据从数据库导出，必须提供 -db")
+	}
+
+	if *dbURL != "" {
+		conn, err := database.Connect(*dbURL)
+		if err != nil {
+			log.Fatalf("数据库连接失败: %v", err)
+		}
+		defer conn.Close()
+		opts.Sessions, err = export.LoadSessions(ctx, db.New(conn), from, to)
+		if err != nil {
+			conn.Close()
+			log.Fatalf("读取会话元信息失败: %v", err)
+		}
+		fmt.Printf("已读取 %d 个阅读会话\n", len(opts.Sessions))
+
+		// 新闻展示写入数据库时（NEWS_SINK 为 postgres 或 both）从 news_impressions 表导出
+		if mode, _ := storage.NewsSinkMode(); mode == storage.SinkPostgres || mode == storage.SinkBoth {
+			opts.NewsImpressions, err = export.LoadNewsImpressions(ctx, db.New(conn), from, to)
+			if err != nil {
+				conn.Close()
+				log.Fatalf("读取新闻展示失败: %v", err)
+			}
+			fmt.Printf("已读取 %d 条新闻展示\n", len(opts.NewsImpressions))
+		}
+
+		// 追踪数据只写入数据库时（TRACKING_SINK=postgres）从 eye_samples / clicks / scrolls 表导出
+		if trackingInDatabase {
+			opts.TrackingQueries = db.New(conn)
+			fmt.Println("追踪数据从数据库导出")
+		}
+	} else {
+		fmt.Println("未配置数据库，导出结果中不包含文章和设备信息")
+	}
+
+	result, err := export.Run(ctx, opts)
+	if err != nil {
+		log.Fatalf("导出失败: %v", err)
+	}
+	for _, file := range result.Files {
+		fmt.Printf("%-24s %10d 行 %12d 字节\n", file.Name, file.Rows, file.Size)
+	}
+	if result.UnknownSessions > 0 {
+		fmt.Printf("%d 个会话在数据库中没有记录，文章和设备列为空\n", result.UnknownSessions)
+	}
+	fmt.Printf("已导出到 %s\n", *out)
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"NewsEyeTracking/internal/api/routes"
+	"NewsEyeTracking/internal/blobstore"
 	"NewsEyeTracking/internal/database"
+	"NewsEyeTracking/internal/envelope"
 	"NewsEyeTracking/internal/service"
 	"NewsEyeTracking/pkg/logger"
 	"context"
@@ -142,18 +144,25 @@ func main() {
 }
 
 // validateUploadEnvVars 验证上传服务所需的环境变量
+// 对象存储的凭证只在所选后端需要时检查，local 后端无需任何云端配置
 func validateUploadEnvVars() error {
-	requiredVars := []string{
-		"ACCESS_ID",
-		"ACCESS_KEY",
-		"OSS_REGION",
-		"OSS_BUCKET_NAME",
+	storageVars, err := blobstore.RequiredEnv(blobstore.Backend())
+	if err != nil {
+		return err
+	}
+
+	requiredVars := append(storageVars,
 		"UPLOAD_TRACKING_DIR",
 		"UPLOAD_NEWS_DIR",
 		"UPLOAD_TEMP_DIR",
 		"UPLOAD_MAX_FILES",
 		"UPLOAD_MAX_SIZE",
 		"UPLOAD_CHECK_INTERVAL",
+	)
+
+	// 要求加密时必须配置主密钥，否则归档会以明文上传
+	if envelope.Required() {
+		requiredVars = append(requiredVars, "ARCHIVE_MASTER_KEY")
 	}
 
 	for _, varName := range requiredVars {
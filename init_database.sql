@@ -221,9 +221,136 @@ CREATE TRIGGER trigger_update_comment_count
     EXECUTE FUNCTION update_comment_count_trigger();
 
 
+-- ============================================================================
+-- 8. 创建追踪数据表 - TRACKING_SINK=postgres 或 both 时写入
+-- 按批次时间按月分区，分区表（如 eye_samples_y2026m10）由服务写入时按需创建
+-- ============================================================================
+CREATE TABLE eye_samples (
+    session_id    UUID             NOT NULL,
+    user_id       UUID             NOT NULL,
+    batch_time    TIMESTAMPTZ      NOT NULL,            -- 批次时间
+    batch_seq     BIGINT           NOT NULL DEFAULT 0,  -- 批次序号，旧客户端为 0
+    batch_id      TEXT             NOT NULL DEFAULT '', -- 批次ID，旧客户端为空
+    sample_index  INTEGER          NOT NULL,            -- 批次内的顺序
+    sample_time   TIMESTAMPTZ      NOT NULL,            -- 换算后的绝对采样时间，与注视检测使用的时间轴一致
+    client_t      DOUBLE PRECISION,                     -- 客户端单调时钟（毫秒），未提供时为空
+    client_clock  DOUBLE PRECISION,                     -- 批次的 client_clock
+    sampling_rate REAL             NOT NULL DEFAULT 0,  -- 批次的标称采样率，0 表示未提供
+    element_id    TEXT             NOT NULL DEFAULT '',
+    x             REAL             NOT NULL,
+    y             REAL             NOT NULL,
+    pupil         REAL,
+    valid         BOOLEAN,                              -- 为空表示客户端未提供（视为有效）
+    left_x        REAL,                                 -- 单眼数据，为空表示未提供
+    left_y        REAL,
+    left_pupil    REAL,
+    left_valid    BOOLEAN,
+    right_x       REAL,
+    right_y       REAL,
+    right_pupil   REAL,
+    right_valid   BOOLEAN
+) PARTITION BY RANGE (batch_time);
+
+CREATE INDEX idx_eye_samples_session ON eye_samples (session_id, batch_time);
+
+CREATE TABLE clicks (
+    session_id  UUID        NOT NULL,
+    user_id     UUID        NOT NULL,
+    batch_time  TIMESTAMPTZ NOT NULL,
+    batch_seq   BIGINT      NOT NULL DEFAULT 0,
+    batch_id    TEXT        NOT NULL DEFAULT '',
+    event_index INTEGER     NOT NULL,
+    clicked_at  TIMESTAMPTZ NOT NULL,
+    element_id  TEXT        NOT NULL DEFAULT '',
+    x           REAL        NOT NULL,
+    y           REAL        NOT NULL
+) PARTITION BY RANGE (batch_time);
+
+CREATE INDEX idx_clicks_session ON clicks (session_id, batch_time);
+
+CREATE TABLE scrolls (
+    session_id  UUID        NOT NULL,
+    user_id     UUID        NOT NULL,
+    batch_time  TIMESTAMPTZ NOT NULL,
+    batch_seq   BIGINT      NOT NULL DEFAULT 0,
+    batch_id    TEXT        NOT NULL DEFAULT '',
+    event_index INTEGER     NOT NULL,
+    scrolled_at TIMESTAMPTZ NOT NULL,
+    delta_y     REAL        NOT NULL
+) PARTITION BY RANGE (batch_time);
+
+CREATE INDEX idx_scrolls_session ON scrolls (session_id, batch_time);
+
+-- ============================================================================
+-- 9. 创建阅读会话概要指标表
+-- ============================================================================
+-- 阅读会话的概要指标，会话结束、最后一批追踪数据落地后计算，重新计算时覆盖
+-- 时间单位为毫秒；分词相关的指标只对文章页会话有意义，列表页会话为空
+CREATE TABLE reading_session_metrics (
+    session_id           UUID             PRIMARY KEY REFERENCES reading_sessions(id) ON DELETE CASCADE,
+    user_id              UUID             NOT NULL,
+    article_id           VARCHAR(512)     NOT NULL,
+    dwell_time_ms        BIGINT           NOT NULL,            -- 停留时间：结束时间 - 开始时间
+    active_time_ms       BIGINT           NOT NULL,            -- 停留时间中有注视、点击或滚动的部分
+    idle_time_ms         BIGINT           NOT NULL,            -- 相邻两次活动间隔超过阈值的部分
+    gaze_samples         INTEGER          NOT NULL,            -- 眼动采样数
+    valid_sample_percent DOUBLE PRECISION NOT NULL,            -- 有效采样百分比（0~100），没有采样时为 0
+    max_scroll_depth     DOUBLE PRECISION NOT NULL,            -- 到达的最大滚动偏移（像素）
+    click_count          INTEGER          NOT NULL,
+    token_count          INTEGER,                              -- 文章分词数
+    fixated_tokens       INTEGER,                              -- 被注视到的分词数
+    fixated_token_ratio  DOUBLE PRECISION,                     -- 被注视到的分词比例（0~1）
+    reading_speed        DOUBLE PRECISION,                     -- 阅读速度：每分钟活跃时间注视到的分词数
+    algorithm            VARCHAR(16)      NOT NULL,            -- 计算分词指标使用的注视检测算法
+    computed_at          TIMESTAMPTZ      NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_reading_session_metrics_article ON reading_session_metrics (article_id);
+
+-- ============================================================================
+-- 10. 创建新闻展示表
+-- ============================================================================
+-- 列表页新闻展示，每次返回新闻列表时每篇新闻一行
+-- 推荐服务通过它排除或降权用户已经看过的新闻；重复写入同一个展示ID时忽略，刷新失败重试不会重复
+CREATE TABLE news_impressions (
+    impression_id     UUID             PRIMARY KEY,
+    user_id           UUID             NOT NULL,
+    list_session_id   UUID,                                    -- 展示这批新闻的列表页阅读会话
+    guid              VARCHAR(512)     NOT NULL,               -- 新闻 GUID
+    position          INTEGER          NOT NULL,               -- 在本次返回列表中的位置，从 0 开始
+    score             DOUBLE PRECISION,                        -- 推荐服务给出的分数
+    strategy          VARCHAR(64)      NOT NULL DEFAULT '',    -- 推荐算法名称
+    recommend_user_id VARCHAR(64)      NOT NULL DEFAULT '',    -- 推荐服务返回的用户ID
+    shown_at          TIMESTAMPTZ      NOT NULL
+);
+
+CREATE INDEX idx_news_impressions_user_guid ON news_impressions (user_id, guid, shown_at);
+CREATE INDEX idx_news_impressions_shown_at ON news_impressions (shown_at);
+
+-- ============================================================================
+-- 11. 创建新闻点击表
+-- ============================================================================
+-- 新闻详情打开记录，与 news_impressions 一起计算点击率
+-- 点击ID由服务端生成，重复写入时忽略，刷新失败重试不会重复
+CREATE TABLE news_clicks (
+    click_id          UUID             PRIMARY KEY,
+    user_id           UUID             NOT NULL,
+    guid              VARCHAR(512)     NOT NULL,               -- 新闻 GUID
+    impression_id     UUID,                                    -- 归属的展示，找不到对应展示时为空
+    position          INTEGER,                                 -- 展示在列表中的位置
+    strategy          VARCHAR(64)      NOT NULL DEFAULT '',    -- 展示所用的推荐算法
+    list_session_id   UUID,                                    -- 展示这篇新闻的列表页阅读会话
+    detail_session_id UUID,                                    -- 打开详情时创建的文章页阅读会话
+    clicked_at        TIMESTAMPTZ      NOT NULL
+);
+
+CREATE INDEX idx_news_clicks_clicked_at ON news_clicks (clicked_at);
+CREATE INDEX idx_news_clicks_impression ON news_clicks (impression_id);
+
+
 
 -- 输出完成信息
 SELECT 'NewsEyeTracking数据库初始化完成！' AS status;
-SELECT 'Created tables: feeds, feed_items, invite_codes, users, user_sessions, reading_sessions' AS tables_created;
+SELECT 'Created tables: feeds, feed_items, invite_codes, users, user_sessions, reading_sessions, eye_samples, clicks, scrolls, reading_session_metrics, news_impressions, news_clicks' AS tables_created;
 SELECT 'All indexes and constraints have been applied.' AS indexes_status;
 SELECT 'Comment count trigger has been created.' AS trigger_status;
@@ -0,0 +1,288 @@
+package analysis
+
+// 词级兴趣区（AOI）指标
+// Split 服务把文章标题和正文中的每个分词包在 <span data-id="..."> 中，
+// 眼动采样的 ID 即为这些 data-id。这里把分词后的 HTML 解析为 AOI 索引，
+// 再根据注视序列计算阅读研究常用的逐词指标。
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// AOIToken 一个分词兴趣区
+type AOIToken struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Source    string `json:"source"`    // title 或 content
+	Index     int    `json:"index"`     // 在全文（标题在前）中的顺序
+	Paragraph int    `json:"paragraph"` // 段落序号（从 0 开始，标题为 0）
+	Sentence  int    `json:"sentence"`  // 全文中的句子序号
+	Position  int    `json:"position"`  // 在句子中的位置
+}
+
+// AOIIndex 分词ID到兴趣区的索引
+type AOIIndex struct {
+	Tokens []AOIToken
+	byID   map[string]int
+}
+
+// Lookup 根据分词ID查找兴趣区
+func (idx *AOIIndex) Lookup(id string) (AOIToken, bool) {
+	i, ok := idx.byID[id]
+	if !ok {
+		return AOIToken{}, false
+	}
+	return idx.Tokens[i], true
+}
+
+// 句末标点
+const sentenceTerminators = "。！？!?；;…"
+
+// 段落级标签
+var blockAtoms = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Li: true, atom.Blockquote: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+// BuildAOIIndex 解析文章标题和正文的分词 HTML
+func BuildAOIIndex(titleHTML, contentHTML string) (*AOIIndex, error) {
+	b := &aoiBuilder{index: &AOIIndex{byID: make(map[string]int)}}
+
+	if err := b.parse(titleHTML, "title"); err != nil {
+		return nil, fmt.Errorf("解析标题失败: %w", err)
+	}
+	b.newParagraph()
+	if err := b.parse(contentHTML, "content"); err != nil {
+		return nil, fmt.Errorf("解析正文失败: %w", err)
+	}
+
+	return b.index, nil
+}
+
+type aoiBuilder struct {
+	index     *AOIIndex
+	paragraph int
+	sentence  int
+	position  int
+	dirty     bool // 当前段落是否已有分词
+}
+
+func (b *aoiBuilder) parse(fragment, source string) error {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		b.walk(node, source)
+	}
+	return nil
+}
+
+func (b *aoiBuilder) walk(node *html.Node, source string) {
+	switch node.Type {
+	case html.TextNode:
+		b.endSentenceIf(node.Data)
+		return
+	case html.ElementNode:
+		if node.DataAtom == atom.Br {
+			b.newParagraph()
+			return
+		}
+		if id := spanDataID(node); id != "" {
+			b.addToken(id, textContent(node), source)
+			return
+		}
+	}
+
+	block := node.Type == html.ElementNode && blockAtoms[node.DataAtom]
+	if block {
+		b.newParagraph()
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		b.walk(child, source)
+	}
+	if block {
+		b.newParagraph()
+	}
+}
+
+func (b *aoiBuilder) addToken(id, text, source string) {
+	if _, exists := b.index.byID[id]; exists {
+		return // 重复的 data-id 只保留第一次出现的位置
+	}
+
+	token := AOIToken{
+		ID:        id,
+		Text:      text,
+		Source:    source,
+		Index:     len(b.index.Tokens),
+		Paragraph: b.paragraph,
+		Sentence:  b.sentence,
+		Position:  b.position,
+	}
+	b.index.byID[id] = token.Index
+	b.index.Tokens = append(b.index.Tokens, token)
+	b.position++
+	b.dirty = true
+
+	b.endSentenceIf(text)
+}
+
+// endSentenceIf 文本以句末标点结尾时开始新句子
+func (b *aoiBuilder) endSentenceIf(text string) {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" || b.position == 0 {
+		return
+	}
+	runes := []rune(trimmed)
+	if strings.ContainsRune(sentenceTerminators, runes[len(runes)-1]) ||
+		(runes[len(runes)-1] == '.' && len(runes) == 1) {
+		b.sentence++
+		b.position = 0
+	}
+}
+
+// newParagraph 开始新段落，同时也开始新句子
+func (b *aoiBuilder) newParagraph() {
+	if !b.dirty {
+		return
+	}
+	b.paragraph++
+	if b.position > 0 {
+		b.sentence++
+	}
+	b.position = 0
+	b.dirty = false
+}
+
+func spanDataID(node *html.Node) string {
+	if node.DataAtom != atom.Span {
+		return ""
+	}
+	for _, attr := range node.Attr {
+		if attr.Key == "data-id" {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func textContent(node *html.Node) string {
+	var sb strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(node)
+	return strings.TrimSpace(sb.String())
+}
+
+// TokenMetrics 单个分词的阅读指标（时长单位：毫秒）
+type TokenMetrics struct {
+	AOIToken
+	FirstFixationDuration float64 `json:"first_fixation_duration"` // 首次注视时长（仅首遍阅读）
+	GazeDuration          float64 `json:"gaze_duration"`           // 凝视时长：首遍阅读中离开该词之前的注视总时长
+	TotalReadingTime      float64 `json:"total_reading_time"`      // 总阅读时间
+	FixationCount         int     `json:"fixation_count"`
+	RegressionsIn         int     `json:"regressions_in"`  // 从后文回视到该词的次数
+	RegressionsOut        int     `json:"regressions_out"` // 从该词回视到前文的次数
+	Skipped               bool    `json:"skipped"`         // 首遍阅读中被跳过
+}
+
+// AOIMetrics 一个会话的逐词指标
+type AOIMetrics struct {
+	TokenCount      int            `json:"token_count"`
+	FixatedTokens   int            `json:"fixated_tokens"`
+	SkipRate        float64        `json:"skip_rate"`         // 首遍阅读被跳过的分词比例
+	OffTextFixation int            `json:"off_text_fixation"` // 未落在任何分词上的注视数
+	Tokens          []TokenMetrics `json:"tokens"`
+}
+
+// ComputeAOIMetrics 根据按时间排序的注视序列计算逐词指标
+//
+// 首遍阅读：某个词在更靠后的词被注视之前就被注视到，则其首次注视属于首遍阅读；
+// 否则视为跳过，首次注视时长和凝视时长按惯例记为 0。
+// 回视：相邻两个落在文本上的注视，后者的词序小于前者。
+func ComputeAOIMetrics(index *AOIIndex, fixations []Fixation) *AOIMetrics {
+	metrics := &AOIMetrics{
+		TokenCount: len(index.Tokens),
+		Tokens:     make([]TokenMetrics, len(index.Tokens)),
+	}
+	for i, token := range index.Tokens {
+		metrics.Tokens[i] = TokenMetrics{AOIToken: token}
+	}
+
+	visited := make([]bool, len(index.Tokens))
+	firstPass := make([]bool, len(index.Tokens))
+	furthest := -1 // 目前注视到的最靠后的词序
+	prev := -1     // 上一个落在文本上的注视所在词序
+	inGaze := -1   // 正在累计凝视时长的词序
+
+	for _, fixation := range fixations {
+		i, ok := index.byID[fixation.TargetID]
+		if !ok {
+			metrics.OffTextFixation++
+			continue
+		}
+		m := &metrics.Tokens[i]
+
+		m.TotalReadingTime += fixation.Duration
+		m.FixationCount++
+
+		if prev >= 0 && i != prev {
+			if i < prev {
+				metrics.Tokens[prev].RegressionsOut++
+				m.RegressionsIn++
+			}
+			if inGaze != i {
+				inGaze = -1
+			}
+		}
+
+		if !visited[i] {
+			visited[i] = true
+			if i >= furthest {
+				firstPass[i] = true
+				m.FirstFixationDuration = fixation.Duration
+				m.GazeDuration = fixation.Duration
+				inGaze = i
+			}
+		} else if inGaze == i && prev == i {
+			m.GazeDuration += fixation.Duration
+		}
+
+		if i > furthest {
+			furthest = i
+		}
+		prev = i
+	}
+
+	skipped := 0
+	for i := range metrics.Tokens {
+		if visited[i] {
+			metrics.FixatedTokens++
+		}
+		// 只统计首遍阅读已经越过的词，读者还没读到的词不算跳过
+		if !firstPass[i] && i < furthest {
+			metrics.Tokens[i].Skipped = true
+			skipped++
+		}
+	}
+	if furthest >= 0 {
+		metrics.SkipRate = float64(skipped) / float64(furthest+1)
+	}
+
+	return metrics
+}
@@ -0,0 +1,180 @@
+package analysis
+
+// 文档坐标重建
+// 眼动和点击坐标相对于浏览器视口，页面滚动后同一个坐标对应的文字不同。
+// 这里把会话的滚动事件累加为滚动偏移时间线，再把每个采样和点击投影到文档坐标：
+// 文档 y = 视口 y + 采样时刻的滚动偏移。视口大小取会话开始时的设备信息，
+// 之后按批次中附带的 device_info 更新；宽度变化时滚动偏移按比例缩放（见 ViewportScrollTimeline）。
+import (
+	"NewsEyeTracking/internal/models"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ViewportChange 视口大小，从 T 时刻（Unix 毫秒）起生效
+type ViewportChange struct {
+	T      float64 `json:"t"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+}
+
+// DocumentPoint 投影到文档坐标的一个采样或点击
+type DocumentPoint struct {
+	T       float64 `json:"t"`        // 绝对时间（Unix 毫秒）
+	X       float64 `json:"x"`        // 文档坐标（像素），与视口 x 相同
+	Y       float64 `json:"y"`        // 文档坐标（像素），视口 y + scroll_y
+	ScrollY float64 `json:"scroll_y"` // 该时刻的滚动偏移（像素）
+	NX      float64 `json:"nx"`       // 按视口宽度归一化的 x，视口未知时为 0
+	NY      float64 `json:"ny"`       // 按视口宽度归一化的 y，视口未知时为 0
+}
+
+// ScrollPosition 滚动事件之后的偏移
+type ScrollPosition struct {
+	T       float64 `json:"t"`
+	ScrollY float64 `json:"scroll_y"`
+}
+
+// DocumentRecord 一个原始批次的文档坐标（document 文件中的一行）
+// Eye 与原始批次的 eye_event 一一对应（包括无效采样），Clicks 与 click_event 一一对应，Scrolls 与 scroll_event 一一对应
+type DocumentRecord struct {
+	SessionID      uuid.UUID        `json:"session_id"`
+	BatchTime      time.Time        `json:"batch_time"`
+	Seq            uint64           `json:"seq,omitempty"`
+	BatchID        string           `json:"batch_id,omitempty"`
+	ComputedAt     time.Time        `json:"computed_at"`
+	ViewportWidth  int              `json:"viewport_width"` // 批次开始时的视口大小，0 表示未知
+	ViewportHeight int              `json:"viewport_height"`
+	Eye            []DocumentPoint  `json:"eye,omitempty"`
+	Clicks         []DocumentPoint  `json:"clicks,omitempty"`
+	Scrolls        []ScrollPosition `json:"scrolls,omitempty"`
+}
+
+// Projector 把会话中某一时刻的视口坐标投影到文档坐标
+type Projector struct {
+	viewports []ViewportChange
+	scroll    *ScrollTimeline
+}
+
+// NewProjector 用会话开始时的设备信息（可为 nil）和各批次附带的 device_info 建立视口和滚动时间线
+func NewProjector(records []models.UserTrackingRecord, sessionID uuid.UUID, initial *models.DeviceInfo) *Projector {
+	viewports := SessionViewports(records, sessionID, initial)
+	return &Projector{
+		viewports: viewports,
+		scroll:    ViewportScrollTimeline(records, sessionID, viewports),
+	}
+}
+
+// SessionViewports 会话的视口时间线，按时间升序
+// 批次附带的 device_info 从该批次最早的事件时间起生效，会话开始时的设备信息从最早处生效
+func SessionViewports(records []models.UserTrackingRecord, sessionID uuid.UUID, initial *models.DeviceInfo) []ViewportChange {
+	var viewports []ViewportChange
+	if initial != nil && initial.ViewportWidth > 0 {
+		viewports = append(viewports, ViewportChange{T: math.Inf(-1), Width: initial.ViewportWidth, Height: initial.ViewportHeight})
+	}
+	for _, record := range records {
+		device := record.Data.DeviceInfo
+		if device == nil || (sessionID != uuid.Nil && record.SessionID != sessionID) {
+			continue
+		}
+		viewports = append(viewports, ViewportChange{
+			T:      batchStartMillis(record),
+			Width:  device.ViewportWidth,
+			Height: device.ViewportHeight,
+		})
+	}
+	sort.SliceStable(viewports, func(i, j int) bool {
+		return viewports[i].T < viewports[j].T
+	})
+	return viewports
+}
+
+// HasViewport 会话是否有已知的视口宽度
+func (p *Projector) HasViewport() bool {
+	for _, viewport := range p.viewports {
+		if viewport.Width > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ViewportAt t 时刻的视口，早于第一次已知视口时返回零值
+func (p *Projector) ViewportAt(t float64) ViewportChange {
+	i := sort.Search(len(p.viewports), func(i int) bool { return p.viewports[i].T > t })
+	if i == 0 {
+		return ViewportChange{}
+	}
+	return p.viewports[i-1]
+}
+
+// Project 把 t 时刻的视口坐标投影到文档坐标
+func (p *Projector) Project(t, x, y float64) DocumentPoint {
+	offset := p.scroll.OffsetAt(t)
+	point := DocumentPoint{T: t, X: x, Y: y + offset, ScrollY: offset}
+	if width := float64(p.ViewportAt(t).Width); width > 0 {
+		point.NX, point.NY = point.X/width, point.Y/width
+	}
+	return point
+}
+
+// ProjectSession 逐个批次投影会话的全部眼动采样、点击和滚动事件，批次按时间排序
+func ProjectSession(records []models.UserTrackingRecord, sessionID uuid.UUID, initial *models.DeviceInfo) []DocumentRecord {
+	var batches []models.UserTrackingRecord
+	for _, record := range records {
+		if record.SessionID == sessionID {
+			batches = append(batches, record)
+		}
+	}
+	sort.SliceStable(batches, func(i, j int) bool {
+		return batches[i].StartTime.Before(batches[j].StartTime)
+	})
+
+	projector := NewProjector(batches, sessionID, initial)
+	computedAt := time.Now()
+	results := make([]DocumentRecord, 0, len(batches))
+	for _, record := range batches {
+		viewport := projector.ViewportAt(batchStartMillis(record))
+		result := DocumentRecord{
+			SessionID:      sessionID,
+			BatchTime:      record.StartTime,
+			Seq:            record.Seq,
+			BatchID:        record.BatchID,
+			ComputedAt:     computedAt,
+			ViewportWidth:  viewport.Width,
+			ViewportHeight: viewport.Height,
+		}
+
+		for i, t := range BatchSampleTimes(record) {
+			event := record.Data.EyeEvents[i]
+			result.Eye = append(result.Eye, projector.Project(t, float64(event.X), float64(event.Y)))
+		}
+		for _, event := range record.Data.ClickEvents {
+			t := float64(event.Timestamp.UnixNano()) / 1e6
+			result.Clicks = append(result.Clicks, projector.Project(t, float64(event.X), float64(event.Y)))
+		}
+		for _, event := range record.Data.ScrollEvents {
+			t := float64(event.Timestamp.UnixNano()) / 1e6
+			result.Scrolls = append(result.Scrolls, ScrollPosition{T: t, ScrollY: projector.scroll.OffsetAt(t)})
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// batchStartMillis 批次中最早的事件时间（Unix 毫秒），没有事件时为批次时间
+func batchStartMillis(record models.UserTrackingRecord) float64 {
+	start := RecordTimeMillis(record)
+	for _, t := range BatchSampleTimes(record) {
+		start = math.Min(start, t)
+	}
+	for _, event := range record.Data.ClickEvents {
+		start = math.Min(start, float64(event.Timestamp.UnixNano())/1e6)
+	}
+	for _, event := range record.Data.ScrollEvents {
+		start = math.Min(start, float64(event.Timestamp.UnixNano())/1e6)
+	}
+	return start
+}
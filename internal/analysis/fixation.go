@@ -0,0 +1,328 @@
+package analysis
+
+// 注视点（fixation）与眼跳（saccade）检测
+// 提供两种经典算法：
+//   - I-VT：按相邻采样的速度阈值区分注视和眼跳
+//   - I-DT：按滑动窗口内的离散度阈值识别注视
+//
+// 坐标单位为像素、时间单位为毫秒，速度阈值因此为 像素/毫秒。
+// 在约 60cm 观看距离下 1° 视角约为 35 像素，30°/s 的经典阈值约等于 1 像素/毫秒。
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AlgorithmIVT = "ivt"
+	AlgorithmIDT = "idt"
+)
+
+// IVTConfig I-VT 算法参数
+type IVTConfig struct {
+	VelocityThreshold   float64 // 速度阈值（像素/毫秒），低于该值视为注视
+	MinFixationDuration float64 // 最短注视时长（毫秒）
+	MaxGap              float64 // 相邻有效采样的最大间隔（毫秒），超过则切断注视
+	MaxSaccadeDuration  float64 // 两个注视之间间隔不超过该值时记为一次眼跳（毫秒）
+}
+
+// IDTConfig I-DT 算法参数
+type IDTConfig struct {
+	DispersionThreshold float64 // 离散度阈值（像素），(maxX-minX)+(maxY-minY)
+	MinFixationDuration float64 // 最短注视时长（毫秒），也是初始窗口长度
+	MaxGap              float64 // 相邻有效采样的最大间隔（毫秒）
+	MaxSaccadeDuration  float64 // 两个注视之间间隔不超过该值时记为一次眼跳（毫秒）
+}
+
+// DefaultIVTConfig I-VT 默认参数，可通过 FIXATION_IVT_VELOCITY / FIXATION_MIN_DURATION 覆盖
+func DefaultIVTConfig() IVTConfig {
+	return IVTConfig{
+		VelocityThreshold:   envFloat("FIXATION_IVT_VELOCITY", 1.0),
+		MinFixationDuration: envFloat("FIXATION_MIN_DURATION", 60),
+		MaxGap:              100,
+		MaxSaccadeDuration:  200,
+	}
+}
+
+// DefaultIDTConfig I-DT 默认参数，可通过 FIXATION_IDT_DISPERSION / FIXATION_MIN_DURATION 覆盖
+func DefaultIDTConfig() IDTConfig {
+	return IDTConfig{
+		DispersionThreshold: envFloat("FIXATION_IDT_DISPERSION", 35),
+		MinFixationDuration: envFloat("FIXATION_MIN_DURATION", 100),
+		MaxGap:              100,
+		MaxSaccadeDuration:  200,
+	}
+}
+
+// Fixation 注视点
+type Fixation struct {
+	Start       float64 `json:"start"`    // 开始时间（Unix 毫秒）
+	End         float64 `json:"end"`      // 结束时间（Unix 毫秒）
+	Duration    float64 `json:"duration"` // 持续时间（毫秒）
+	X           float64 `json:"x"`        // 质心坐标
+	Y           float64 `json:"y"`
+	TargetID    string  `json:"target_id,omitempty"` // 注视目标元素ID（采样中出现次数最多的ID）
+	SampleCount int     `json:"samples"`
+}
+
+// Saccade 眼跳，连接两个相邻的注视点
+type Saccade struct {
+	Start        float64 `json:"start"`
+	End          float64 `json:"end"`
+	Duration     float64 `json:"duration"`
+	FromX        float64 `json:"from_x"`
+	FromY        float64 `json:"from_y"`
+	ToX          float64 `json:"to_x"`
+	ToY          float64 `json:"to_y"`
+	Amplitude    float64 `json:"amplitude"`     // 幅度（像素）
+	PeakVelocity float64 `json:"peak_velocity"` // 峰值速度（像素/毫秒）
+	FromID       string  `json:"from_id,omitempty"`
+	ToID         string  `json:"to_id,omitempty"`
+}
+
+// Result 一次检测的结果
+type Result struct {
+	Algorithm string     `json:"algorithm"`
+	Fixations []Fixation `json:"fixations"`
+	Saccades  []Saccade  `json:"saccades"`
+}
+
+// Detect 按算法名称使用默认参数检测
+func Detect(algorithm string, samples []GazeSample) (*Result, error) {
+	switch strings.ToLower(algorithm) {
+	case "", AlgorithmIVT:
+		return DetectIVT(samples, DefaultIVTConfig()), nil
+	case AlgorithmIDT:
+		return DetectIDT(samples, DefaultIDTConfig()), nil
+	default:
+		return nil, fmt.Errorf("不支持的注视检测算法: %s", algorithm)
+	}
+}
+
+// DetectIVT 速度阈值算法（Velocity-Threshold Identification）
+func DetectIVT(samples []GazeSample, cfg IVTConfig) *Result {
+	valid := validSamples(samples)
+	result := &Result{Algorithm: AlgorithmIVT, Fixations: []Fixation{}, Saccades: []Saccade{}}
+	if len(valid) == 0 {
+		return result
+	}
+
+	var run []GazeSample
+	flush := func() {
+		if fixation, ok := buildFixation(run, cfg.MinFixationDuration); ok {
+			result.Fixations = append(result.Fixations, fixation)
+		}
+		run = run[:0]
+	}
+
+	for i, sample := range valid {
+		if i == 0 {
+			run = append(run, sample)
+			continue
+		}
+
+		prev := valid[i-1]
+		dt := sample.T - prev.T
+		if dt > cfg.MaxGap {
+			flush()
+			run = append(run, sample)
+			continue
+		}
+
+		if velocity(prev, sample) < cfg.VelocityThreshold {
+			if len(run) == 0 {
+				// 眼跳结束后的第一个低速采样，上一个采样作为注视起点
+				run = append(run, prev)
+			}
+			run = append(run, sample)
+		} else {
+			flush()
+		}
+	}
+	flush()
+
+	result.Saccades = saccadesBetween(result.Fixations, valid, cfg.MaxSaccadeDuration)
+	return result
+}
+
+// DetectIDT 离散度阈值算法（Dispersion-Threshold Identification）
+func DetectIDT(samples []GazeSample, cfg IDTConfig) *Result {
+	valid := validSamples(samples)
+	result := &Result{Algorithm: AlgorithmIDT, Fixations: []Fixation{}, Saccades: []Saccade{}}
+
+	i := 0
+	for i < len(valid) {
+		// 初始窗口：覆盖最短注视时长，中途出现采样缺口则从缺口后重新开始
+		j := i
+		broken := false
+		for j+1 < len(valid) && valid[j].T-valid[i].T < cfg.MinFixationDuration {
+			if valid[j+1].T-valid[j].T > cfg.MaxGap {
+				broken = true
+				break
+			}
+			j++
+		}
+		if broken {
+			i = j + 1
+			continue
+		}
+		if valid[j].T-valid[i].T < cfg.MinFixationDuration {
+			break // 剩余采样不足一个窗口
+		}
+
+		if dispersion(valid[i:j+1]) > cfg.DispersionThreshold {
+			i++
+			continue
+		}
+
+		// 扩展窗口直到离散度超过阈值或出现缺口
+		for j+1 < len(valid) &&
+			valid[j+1].T-valid[j].T <= cfg.MaxGap &&
+			dispersion(valid[i:j+2]) <= cfg.DispersionThreshold {
+			j++
+		}
+
+		if fixation, ok := buildFixation(valid[i:j+1], cfg.MinFixationDuration); ok {
+			result.Fixations = append(result.Fixations, fixation)
+		}
+		i = j + 1
+	}
+
+	result.Saccades = saccadesBetween(result.Fixations, valid, cfg.MaxSaccadeDuration)
+	return result
+}
+
+// validSamples 过滤无效采样
+func validSamples(samples []GazeSample) []GazeSample {
+	valid := make([]GazeSample, 0, len(samples))
+	for _, sample := range samples {
+		if sample.Valid {
+			valid = append(valid, sample)
+		}
+	}
+	return valid
+}
+
+// buildFixation 由一组连续采样生成注视点，时长不足时返回 false
+func buildFixation(run []GazeSample, minDuration float64) (Fixation, bool) {
+	if len(run) < 2 {
+		return Fixation{}, false
+	}
+
+	start, end := run[0].T, run[len(run)-1].T
+	if end-start < minDuration {
+		return Fixation{}, false
+	}
+
+	var sumX, sumY float64
+	idCount := make(map[string]int)
+	targetID, best := "", 0
+	for _, sample := range run {
+		sumX += sample.X
+		sumY += sample.Y
+		if sample.ID == "" {
+			continue
+		}
+		idCount[sample.ID]++
+		if idCount[sample.ID] > best {
+			targetID, best = sample.ID, idCount[sample.ID]
+		}
+	}
+
+	n := float64(len(run))
+	return Fixation{
+		Start:       start,
+		End:         end,
+		Duration:    end - start,
+		X:           sumX / n,
+		Y:           sumY / n,
+		TargetID:    targetID,
+		SampleCount: len(run),
+	}, true
+}
+
+// saccadesBetween 在相邻注视点之间生成眼跳
+func saccadesBetween(fixations []Fixation, samples []GazeSample, maxDuration float64) []Saccade {
+	saccades := []Saccade{}
+	k := 0
+	for i := 1; i < len(fixations); i++ {
+		from, to := fixations[i-1], fixations[i]
+		if to.Start-from.End > maxDuration {
+			continue
+		}
+
+		// 峰值速度取两次注视之间（含边界采样）的最大相邻速度
+		peak := 0.0
+		for k < len(samples) && samples[k].T < from.End {
+			k++
+		}
+		for m := k; m+1 < len(samples) && samples[m].T < to.Start; m++ {
+			if v := velocity(samples[m], samples[m+1]); v > peak {
+				peak = v
+			}
+		}
+
+		saccades = append(saccades, Saccade{
+			Start:        from.End,
+			End:          to.Start,
+			Duration:     to.Start - from.End,
+			FromX:        from.X,
+			FromY:        from.Y,
+			ToX:          to.X,
+			ToY:          to.Y,
+			Amplitude:    math.Hypot(to.X-from.X, to.Y-from.Y),
+			PeakVelocity: peak,
+			FromID:       from.TargetID,
+			ToID:         to.TargetID,
+		})
+	}
+	return saccades
+}
+
+// velocity 相邻采样之间的速度（像素/毫秒）
+func velocity(a, b GazeSample) float64 {
+	dt := b.T - a.T
+	if dt <= 0 {
+		return 0
+	}
+	return math.Hypot(b.X-a.X, b.Y-a.Y) / dt
+}
+
+// dispersion 采样窗口的离散度
+func dispersion(window []GazeSample) float64 {
+	minX, maxX := window[0].X, window[0].X
+	minY, maxY := window[0].Y, window[0].Y
+	for _, sample := range window[1:] {
+		minX = math.Min(minX, sample.X)
+		maxX = math.Max(maxX, sample.X)
+		minY = math.Min(minY, sample.Y)
+		maxY = math.Max(maxY, sample.Y)
+	}
+	return (maxX - minX) + (maxY - minY)
+}
+
+func envFloat(name string, fallback float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// 持久化范围
+const (
+	ScopeBatch   = "batch"   // 刷新时按批次计算，批次边界处的注视可能被截断
+	ScopeSession = "session" // 对整个会话重新计算
+)
+
+// FixationRecord 持久化的注视检测结果（fixations 文件中的一行）
+type FixationRecord struct {
+	SessionID  uuid.UUID `json:"session_id"`
+	Scope      string    `json:"scope"`
+	ComputedAt time.Time `json:"computed_at"`
+	Result
+}
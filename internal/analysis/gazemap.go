@@ -0,0 +1,70 @@
+package analysis
+
+// 文档空间中的注视点，用于跨会话汇总（热力图、扫视路径）
+// 不同被试的视口大小不同，坐标投影到文档坐标（见 document.go）后统一除以视口宽度：x 落在 0~1，y 以视口宽度为单位向下延伸。
+// 文章按视口宽度自适应排版，同一段文字在不同设备上的归一化位置大致相同。
+import (
+	"NewsEyeTracking/internal/models"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// 注视数据来源
+const (
+	GazeSourceFixations = "fixations" // 注视检测结果，权重为注视持续时间
+	GazeSourceSamples   = "samples"   // 原始眼动采样，权重为 1
+)
+
+// GazePoint 文档空间中的注视点
+type GazePoint struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Weight float64 `json:"weight"`
+}
+
+// GazePath 一个会话按时间排列的注视点
+type GazePath struct {
+	SessionID uuid.UUID   `json:"session_id"`
+	Points    []GazePoint `json:"points"`
+}
+
+// DocumentGazePath 把会话的注视（或有效的原始采样）投影到文档坐标后按视口宽度归一化
+// 落在视口左右两侧之外的点、以及视口未知时的点被丢弃
+func DocumentGazePath(projector *Projector, records []models.UserTrackingRecord, sessionID uuid.UUID, source, algorithm string) (*GazePath, error) {
+	if !projector.HasViewport() {
+		return nil, fmt.Errorf("会话 %s 没有视口宽度", sessionID)
+	}
+	samples := SessionSamples(records, sessionID)
+	path := &GazePath{SessionID: sessionID}
+
+	add := func(t, x, y, weight float64) {
+		if projector.ViewportAt(t).Width <= 0 {
+			return
+		}
+		projected := projector.Project(t, x, y)
+		point := GazePoint{X: projected.NX, Y: projected.NY, Weight: weight}
+		if point.X < 0 || point.X > 1 || point.Y < 0 {
+			return
+		}
+		path.Points = append(path.Points, point)
+	}
+
+	switch source {
+	case "", GazeSourceFixations:
+		result, err := Detect(algorithm, samples)
+		if err != nil {
+			return nil, err
+		}
+		for _, fixation := range result.Fixations {
+			add(fixation.Start, fixation.X, fixation.Y, fixation.Duration)
+		}
+	case GazeSourceSamples:
+		for _, sample := range validSamples(samples) {
+			add(sample.T, sample.X, sample.Y, 1)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的注视数据来源: %s", source)
+	}
+	return path, nil
+}
@@ -0,0 +1,118 @@
+package analysis
+
+// 把追踪记录展开为带绝对时间的注视采样序列，供各类分析算法使用
+import (
+	"NewsEyeTracking/internal/models"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSamplingRate 批次未提供采样率且采样不带时间戳时假定的采样率（Hz）
+const DefaultSamplingRate = 60
+
+// GazeSample 单个注视采样
+type GazeSample struct {
+	T     float64 `json:"t"` // 绝对时间（Unix 毫秒）
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	ID    string  `json:"id"`
+	Valid bool    `json:"valid"`
+}
+
+// SessionSamples 展开属于指定会话的所有眼动采样并按时间排序
+// sessionID 为 uuid.Nil 时不过滤会话
+//
+// 采样时间的换算规则：
+//   - 带逐点时间戳且批次提供 client_clock：绝对时间 = 批次时间 + (t - client_clock)
+//   - 带逐点时间戳但没有 client_clock：以批次时间对齐最后一个采样
+//   - 不带时间戳：按采样率（缺省 DefaultSamplingRate）向前均匀排布，最后一个采样对齐批次时间
+func SessionSamples(records []models.UserTrackingRecord, sessionID uuid.UUID) []GazeSample {
+	var samples []GazeSample
+
+	for _, record := range records {
+		if sessionID != uuid.Nil && record.SessionID != sessionID {
+			continue
+		}
+		samples = append(samples, batchSamples(record)...)
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].T < samples[j].T
+	})
+	return samples
+}
+
+// RecordTimeMillis 批次时间（Unix 毫秒）
+func RecordTimeMillis(record models.UserTrackingRecord) float64 {
+	return float64(record.StartTime.UnixNano()) / 1e6
+}
+
+// BatchSampleTimes 按 SessionSamples 的规则换算一个批次中每个眼动采样的绝对时间（Unix 毫秒），顺序与 EyeEvents 相同
+func BatchSampleTimes(record models.UserTrackingRecord) []float64 {
+	samples := batchSamples(record)
+	times := make([]float64, len(samples))
+	for i, sample := range samples {
+		times[i] = sample.T
+	}
+	return times
+}
+
+func batchSamples(record models.UserTrackingRecord) []GazeSample {
+	events := record.Data.EyeEvents
+	if len(events) == 0 {
+		return nil
+	}
+
+	batchTime := RecordTimeMillis(record)
+	samples := make([]GazeSample, 0, len(events))
+
+	if anchor, ok := sampleAnchor(&record.Data); ok {
+		for _, event := range events {
+			t := batchTime
+			if event.Timestamp != nil {
+				t = batchTime + (*event.Timestamp - anchor)
+			}
+			samples = append(samples, newSample(event, t))
+		}
+		return samples
+	}
+
+	rate := float64(record.Data.SamplingRate)
+	if rate <= 0 {
+		rate = DefaultSamplingRate
+	}
+	interval := 1000 / rate
+	last := len(events) - 1
+	for i, event := range events {
+		samples = append(samples, newSample(event, batchTime-float64(last-i)*interval))
+	}
+	return samples
+}
+
+// sampleAnchor 与批次时间对齐的客户端时间：优先 client_clock，否则取最后一个带时间戳的采样
+// 旧数据或重放的数据可能只有部分采样带时间戳，这些批次不保证通过了当前的验证；没有带时间戳的采样时返回 false
+func sampleAnchor(data *models.TrackingData) (float64, bool) {
+	if !data.HasSampleTimestamps() {
+		return 0, false
+	}
+	if data.ClientClock != nil {
+		return *data.ClientClock, true
+	}
+	for i := len(data.EyeEvents) - 1; i >= 0; i-- {
+		if data.EyeEvents[i].Timestamp != nil {
+			return *data.EyeEvents[i].Timestamp, true
+		}
+	}
+	return 0, false
+}
+
+func newSample(event models.EyeEvent, t float64) GazeSample {
+	return GazeSample{
+		T:     t,
+		X:     float64(event.X),
+		Y:     float64(event.Y),
+		ID:    event.ID,
+		Valid: event.IsValid(),
+	}
+}
@@ -0,0 +1,93 @@
+package analysis
+
+// 由滚动事件还原页面的滚动偏移
+// 注视坐标相对于浏览器视口，叠加采样时刻的滚动偏移后才是文档中的位置。
+import (
+	"NewsEyeTracking/internal/models"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// ScrollTimeline 滚动偏移时间线，会话开始时偏移为 0，之后按滚动事件的 DeltaY 累加（不小于 0）
+type ScrollTimeline struct {
+	times   []float64 // 滚动事件时间（Unix 毫秒），升序
+	offsets []float64 // 该事件之后的偏移（像素）
+}
+
+// SessionScrollTimeline 用会话的全部滚动事件建立时间线，sessionID 为 uuid.Nil 时不过滤会话
+func SessionScrollTimeline(records []models.UserTrackingRecord, sessionID uuid.UUID) *ScrollTimeline {
+	return ViewportScrollTimeline(records, sessionID, nil)
+}
+
+// ViewportScrollTimeline 同 SessionScrollTimeline，另外在视口宽度变化时按新旧宽度之比缩放偏移
+// 文章按视口宽度自适应排版，宽度变化后同一段文字的像素位置随之缩放，
+// 缩放偏移使它仍然指向变化前看到的内容（与浏览器的滚动锚定一致）
+func ViewportScrollTimeline(records []models.UserTrackingRecord, sessionID uuid.UUID, viewports []ViewportChange) *ScrollTimeline {
+	var events []models.ScrollEvent
+	for _, record := range records {
+		if sessionID != uuid.Nil && record.SessionID != sessionID {
+			continue
+		}
+		events = append(events, record.Data.ScrollEvents...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	timeline := &ScrollTimeline{
+		times:   make([]float64, 0, len(events)),
+		offsets: make([]float64, 0, len(events)),
+	}
+	offset, width, next := 0.0, 0, 0
+	for _, event := range events {
+		t := float64(event.Timestamp.UnixNano()) / 1e6
+		// 同一时刻的视口变化先于滚动事件生效
+		for ; next < len(viewports) && viewports[next].T <= t; next++ {
+			offset, width = timeline.resize(offset, width, viewports[next])
+		}
+		offset += float64(event.DeltaY)
+		if offset < 0 {
+			offset = 0
+		}
+		timeline.times = append(timeline.times, t)
+		timeline.offsets = append(timeline.offsets, offset)
+	}
+	for ; next < len(viewports); next++ {
+		offset, width = timeline.resize(offset, width, viewports[next])
+	}
+	return timeline
+}
+
+// resize 应用一次视口变化，宽度改变且偏移不为 0 时在时间线上增加一个缩放后的点
+func (s *ScrollTimeline) resize(offset float64, width int, change ViewportChange) (float64, int) {
+	if change.Width <= 0 {
+		return offset, width
+	}
+	if width > 0 && change.Width != width && offset > 0 {
+		offset *= float64(change.Width) / float64(width)
+		s.times = append(s.times, change.T)
+		s.offsets = append(s.offsets, offset)
+	}
+	return offset, change.Width
+}
+
+// OffsetAt t 时刻（Unix 毫秒）的滚动偏移
+func (s *ScrollTimeline) OffsetAt(t float64) float64 {
+	i := sort.Search(len(s.times), func(i int) bool { return s.times[i] > t })
+	if i == 0 {
+		return 0
+	}
+	return s.offsets[i-1]
+}
+
+// MaxOffset 会话中到达的最大滚动偏移
+func (s *ScrollTimeline) MaxOffset() float64 {
+	max := 0.0
+	for _, offset := range s.offsets {
+		if offset > max {
+			max = offset
+		}
+	}
+	return max
+}
@@ -0,0 +1,87 @@
+package analysis
+
+// 阅读会话的概要指标
+// 停留时间按会话的开始和结束时间计算；相邻两次活动（有效注视采样、点击、滚动）间隔超过
+// 空闲阈值时整段间隔记为空闲，其余为活跃时间。会话开始到第一次活动、最后一次活动到会话结束同样按此规则处理。
+import (
+	"NewsEyeTracking/internal/models"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultIdleGap 空闲阈值（毫秒），可通过 SESSION_IDLE_GAP_MS 覆盖
+const DefaultIdleGap = 5000
+
+// SessionSummary 会话的概要指标（时间单位：毫秒）
+type SessionSummary struct {
+	DwellTime          float64 `json:"dwell_time"`
+	ActiveTime         float64 `json:"active_time"`
+	IdleTime           float64 `json:"idle_time"`
+	GazeSamples        int     `json:"gaze_samples"`
+	ValidSamples       int     `json:"valid_samples"`
+	ValidSamplePercent float64 `json:"valid_sample_percent"` // 0~100，没有采样时为 0
+	MaxScrollDepth     float64 `json:"max_scroll_depth"`     // 像素
+	Clicks             int     `json:"clicks"`
+}
+
+// IdleGap 当前配置的空闲阈值（毫秒）
+func IdleGap() float64 {
+	return envFloat("SESSION_IDLE_GAP_MS", DefaultIdleGap)
+}
+
+// SummarizeSession 计算会话在 [start, end] 内的概要指标，projector 提供滚动偏移（含视口变化的处理）
+func SummarizeSession(records []models.UserTrackingRecord, sessionID uuid.UUID, start, end time.Time, projector *Projector, idleGap float64) SessionSummary {
+	summary := SessionSummary{MaxScrollDepth: projector.scroll.MaxOffset()}
+	from := float64(start.UnixNano()) / 1e6
+	to := float64(end.UnixNano()) / 1e6
+	if to > from {
+		summary.DwellTime = to - from
+	}
+
+	var activity []float64
+	for _, sample := range SessionSamples(records, sessionID) {
+		summary.GazeSamples++
+		if sample.Valid {
+			summary.ValidSamples++
+			activity = append(activity, sample.T)
+		}
+	}
+	for _, record := range records {
+		if record.SessionID != sessionID {
+			continue
+		}
+		summary.Clicks += len(record.Data.ClickEvents)
+		for _, event := range record.Data.ClickEvents {
+			activity = append(activity, float64(event.Timestamp.UnixNano())/1e6)
+		}
+		for _, event := range record.Data.ScrollEvents {
+			activity = append(activity, float64(event.Timestamp.UnixNano())/1e6)
+		}
+	}
+	if summary.GazeSamples > 0 {
+		summary.ValidSamplePercent = 100 * float64(summary.ValidSamples) / float64(summary.GazeSamples)
+	}
+
+	// 只统计落在会话时间范围内的活动，首尾两端也按间隔计算空闲
+	sort.Float64s(activity)
+	prev := from
+	for _, t := range activity {
+		if t < from || t > to {
+			continue
+		}
+		if gap := t - prev; gap > idleGap {
+			summary.IdleTime += gap
+		}
+		prev = t
+	}
+	if gap := to - prev; gap > idleGap {
+		summary.IdleTime += gap
+	}
+	if summary.IdleTime > summary.DwellTime {
+		summary.IdleTime = summary.DwellTime
+	}
+	summary.ActiveTime = summary.DwellTime - summary.IdleTime
+	return summary
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/service"
+	"NewsEyeTracking/internal/utils"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DetectSessionFixations 对阅读会话重新做注视/眼跳检测
+// POST /api/v1/admin/sessions/:id/fixations?algorithm=ivt|idt
+func (h *Handlers) DetectSessionFixations(c *gin.Context) {
+	sessionID, ok := parseSessionIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	// 会话还有数据在缓存中时先刷新到文件，保证检测覆盖会话的全部数据
+	if h.sessionFlushPending(sessionID) {
+		h.flushTrackingCache()
+	}
+
+	ctx, cancel := utils.WithComplexQueryTimeout(c.Request.Context())
+	defer cancel()
+
+	result, err := h.services.Analysis.DetectSessionFixations(ctx, sessionID, c.Query("algorithm"))
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(result))
+}
+
+// ProjectSessionDocument 重建阅读会话的滚动偏移和文档坐标，会话结束后会自动执行一次
+// POST /api/v1/admin/sessions/:id/document
+func (h *Handlers) ProjectSessionDocument(c *gin.Context) {
+	sessionID, ok := parseSessionIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if h.sessionFlushPending(sessionID) {
+		h.flushTrackingCache()
+	}
+
+	ctx, cancel := utils.WithComplexQueryTimeout(c.Request.Context())
+	defer cancel()
+
+	result, err := h.services.Analysis.ProjectSessionDocument(ctx, sessionID)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(result))
+}
+
+// GetSessionAOIMetrics 获取文章页会话的逐词兴趣区指标
+// GET /api/v1/admin/sessions/:id/aoi-metrics?algorithm=ivt|idt
+func (h *Handlers) GetSessionAOIMetrics(c *gin.Context) {
+	sessionID, ok := parseSessionIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if h.sessionFlushPending(sessionID) {
+		h.flushTrackingCache()
+	}
+
+	ctx, cancel := utils.WithComplexQueryTimeout(c.Request.Context())
+	defer cancel()
+
+	report, err := h.services.Analysis.SessionAOIMetrics(ctx, sessionID, c.Query("algorithm"))
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(report))
+}
+
+// GetSessionCompleteness 获取阅读会话追踪数据的完整性报告（收到/缺失/重复的批次）
+// GET /api/v1/admin/sessions/:id/completeness
+func (h *Handlers) GetSessionCompleteness(c *gin.Context) {
+	sessionID, ok := parseSessionIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := utils.WithDatabaseTimeout(c.Request.Context())
+	defer cancel()
+
+	if _, err := h.services.Session.GetSessionByID(ctx, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			err = service.ErrSessionNotFound
+		}
+		respondAnalysisError(c, err)
+		return
+	}
+
+	report, err := h.services.BatchLedger.Completeness(ctx, sessionID)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(report))
+}
+
+// parseSessionIDParam 解析路径中的会话ID，失败时直接写入错误响应
+func parseSessionIDParam(c *gin.Context, name string) (uuid.UUID, bool) {
+	sessionID, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.ErrorCodeInvalidRequest,
+			"会话ID格式不正确",
+			err.Error(),
+		))
+		return uuid.Nil, false
+	}
+	return sessionID, true
+}
+
+// respondAnalysisError 把分析服务的错误映射为HTTP响应
+func respondAnalysisError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			models.ErrorCodeSessionNotFound,
+			"阅读会话不存在",
+			err.Error(),
+		))
+	case errors.Is(err, service.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			models.ErrorCodeNotFound,
+			"文章不存在",
+			err.Error(),
+		))
+	case errors.Is(err, service.ErrNotArticleSession):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.ErrorCodeInvalidRequest,
+			"该会话不支持此分析",
+			err.Error(),
+		))
+	case errors.Is(err, service.ErrNoTrackingData):
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			models.ErrorCodeNotFound,
+			"没有可分析的追踪数据",
+			err.Error(),
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.ErrorCodeInternalError,
+			"分析会话数据失败",
+			err.Error(),
+		))
+	}
+}
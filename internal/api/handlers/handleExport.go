@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"NewsEyeTracking/internal/export"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/service"
+	"NewsEyeTracking/internal/utils"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxExportDays 一次导出的最大天数
+const maxExportDays = 31
+
+// ExportParquet 把一段日期内的追踪数据和新闻浏览记录导出为 Parquet 文件
+// POST /api/v1/admin/exports/parquet?from=2026-10-01&to=2026-10-07
+// 返回的 files 可以通过 GET /api/v1/admin/exports/:id/:file 下载
+func (h *Handlers) ExportParquet(c *gin.Context) {
+	from, err := time.ParseInLocation("2006-01-02", c.Query("from"), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "from 参数无效", "日期格式为 YYYY-MM-DD"))
+		return
+	}
+	to := from
+	if value := c.Query("to"); value != "" {
+		if to, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "to 参数无效", "日期格式为 YYYY-MM-DD"))
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) >= maxExportDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.ErrorCodeInvalidRequest,
+			"日期范围无效",
+			fmt.Sprintf("to 不能早于 from，且一次最多导出 %d 天", maxExportDays),
+		))
+		return
+	}
+
+	result, err := h.services.Export.ExportParquet(c.Request.Context(), from, to)
+	if err != nil {
+		if errors.Is(err, service.ErrExportInProgress) {
+			c.JSON(http.StatusConflict, models.ErrorResponse(models.ErrorCodeConflict, "已有导出任务在进行", err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "导出失败", err.Error()))
+		return
+	}
+
+	urls := make(map[string]string, len(result.Files))
+	for _, file := range result.Files {
+		urls[file.Name] = fmt.Sprintf("/api/v1/admin/exports/%s/%s", result.ID, file.Name)
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse(gin.H{
+		"export":    result,
+		"downloads": urls,
+	}))
+}
+
+// DownloadExportFile 下载导出的文件
+// GET /api/v1/admin/exports/:id/:file
+func (h *Handlers) DownloadExportFile(c *gin.Context) {
+	path, err := h.services.Export.ExportFilePath(c.Param("id"), c.Param("file"))
+	if err != nil {
+		if errors.Is(err, service.ErrExportNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(models.ErrorCodeNotFound, "导出文件不存在", err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "读取导出文件失败", err.Error()))
+		return
+	}
+
+	c.FileAttachment(path, c.Param("id")+"_"+c.Param("file"))
+}
+
+// ExportSessionBIDS 按 BIDS 眼动数据规范导出单个阅读会话（zip：采样 TSV、点击/滚动事件 TSV 和 JSON 说明文件）
+// GET /api/v1/admin/sessions/:id/export
+func (h *Handlers) ExportSessionBIDS(c *gin.Context) {
+	sessionID, ok := parseSessionIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := utils.WithComplexQueryTimeout(c.Request.Context())
+	defer cancel()
+
+	session, err := h.services.Export.SessionBIDS(ctx, sessionID)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	// 先写入内存，出错时还能返回 JSON 错误
+	var buf bytes.Buffer
+	if err := export.WriteBIDS(&buf, session); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "导出会话失败", err.Error()))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.BIDSFileName(sessionID)))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// GetCTRReport 按推荐算法统计列表页新闻的展示和点击率
+// GET /api/v1/admin/reports/ctr?from=2026-10-01&to=2026-10-07（含两端，默认最近 7 天）
+func (h *Handlers) GetCTRReport(c *gin.Context) {
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	from, to := today.AddDate(0, 0, -6), today
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "from 参数无效", "日期格式为 YYYY-MM-DD"))
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "to 参数无效", "日期格式为 YYYY-MM-DD"))
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) >= maxExportDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.ErrorCodeInvalidRequest,
+			"日期范围无效",
+			fmt.Sprintf("to 不能早于 from，且一次最多统计 %d 天", maxExportDays),
+		))
+		return
+	}
+
+	// 统计前写入缓存中的展示和点击
+	h.flushNewsCache()
+
+	report, err := h.services.Exposure.CTRReport(c.Request.Context(), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "统计点击率失败", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse(report))
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"NewsEyeTracking/internal/analysis"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/render"
+	"NewsEyeTracking/internal/service"
+	"NewsEyeTracking/internal/utils"
+	"bytes"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultGazeDays 未指定日期范围时汇总最近多少天的会话
+const defaultGazeDays = 30
+
+// GetArticleHeatmap 文章的注视热力图（PNG，透明背景，宽度与 width 参数一致）
+// GET /api/v1/admin/articles/:guid/heatmap?from=&to=&source=fixations|samples&algorithm=ivt|idt&has_recommend=&has_more_information=&width=800
+func (h *Handlers) GetArticleHeatmap(c *gin.Context) {
+	gaze, width, ok := h.articleGaze(c)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, render.Heatmap(gaze.Paths, width)); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "绘制热力图失败", err.Error()))
+		return
+	}
+	setGazeHeaders(c, gaze)
+	c.Data(http.StatusOK, "image/png", buf.Bytes())
+}
+
+// GetArticleScanpath 文章的扫视路径（SVG），每个会话一种颜色，与热力图使用同一坐标系
+// GET /api/v1/admin/articles/:guid/scanpath，参数同热力图
+func (h *Handlers) GetArticleScanpath(c *gin.Context) {
+	gaze, width, ok := h.articleGaze(c)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := render.Scanpath(&buf, gaze.Paths, width, gaze.Source == analysis.GazeSourceFixations); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "绘制扫视路径失败", err.Error()))
+		return
+	}
+	setGazeHeaders(c, gaze)
+	c.Data(http.StatusOK, "image/svg+xml", buf.Bytes())
+}
+
+// articleGaze 解析筛选参数并汇总注视数据，失败时直接写入错误响应
+func (h *Handlers) articleGaze(c *gin.Context) (*service.ArticleGaze, int, bool) {
+	query := service.ArticleGazeQuery{
+		ArticleID: c.Param("guid"),
+		Source:    strings.ToLower(c.DefaultQuery("source", analysis.GazeSourceFixations)),
+		Algorithm: strings.ToLower(c.Query("algorithm")),
+	}
+	switch query.Source {
+	case analysis.GazeSourceFixations, analysis.GazeSourceSamples:
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "source 参数无效", "可选 fixations、samples"))
+		return nil, 0, false
+	}
+	switch query.Algorithm {
+	case "", analysis.AlgorithmIVT, analysis.AlgorithmIDT:
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "algorithm 参数无效", "可选 ivt、idt"))
+		return nil, 0, false
+	}
+
+	// 日期为本地日期，含两端；默认最近 30 天
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	from, to := today.AddDate(0, 0, 1-defaultGazeDays), today
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "from 参数无效", "日期格式为 YYYY-MM-DD"))
+			return nil, 0, false
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "to 参数无效", "日期格式为 YYYY-MM-DD"))
+			return nil, 0, false
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "日期范围无效", "to 不能早于 from"))
+		return nil, 0, false
+	}
+	query.From, query.To = from, to.AddDate(0, 0, 1)
+
+	for name, target := range map[string]**bool{
+		"has_recommend":        &query.HasRecommend,
+		"has_more_information": &query.HasMoreInformation,
+	} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, name+" 参数无效", err.Error()))
+			return nil, 0, false
+		}
+		*target = &parsed
+	}
+
+	width, err := strconv.Atoi(c.DefaultQuery("width", strconv.Itoa(render.DefaultWidth)))
+	if err != nil || width < 100 || width > render.MaxWidth {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "width 参数无效", fmt.Sprintf("宽度范围为 100~%d", render.MaxWidth)))
+		return nil, 0, false
+	}
+	if value := c.Query("max_sessions"); value != "" {
+		if query.MaxSessions, err = strconv.Atoi(value); err != nil || query.MaxSessions <= 0 || query.MaxSessions > service.MaxArticleGazeSessions {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "max_sessions 参数无效", fmt.Sprintf("范围为 1~%d", service.MaxArticleGazeSessions)))
+			return nil, 0, false
+		}
+	}
+
+	// 不在这里刷新缓存：汇总的是已经落地的数据，正在进行的会话最多滞后一个刷新周期
+	ctx, cancel := utils.WithComplexQueryTimeout(c.Request.Context())
+	defer cancel()
+
+	gaze, err := h.services.Analysis.ArticleGaze(ctx, query)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return nil, 0, false
+	}
+	return gaze, width, true
+}
+
+// setGazeHeaders 在响应头中返回参与汇总的会话数
+func setGazeHeaders(c *gin.Context, gaze *service.ArticleGaze) {
+	c.Header("X-Gaze-Sessions", strconv.Itoa(len(gaze.Paths)))
+	c.Header("X-Gaze-Matched-Sessions", strconv.Itoa(gaze.Matched))
+	c.Header("X-Gaze-No-Viewport", strconv.Itoa(gaze.NoViewport))
+	c.Header("X-Gaze-No-Data", strconv.Itoa(gaze.NoData))
+	c.Header("X-Gaze-Truncated", strconv.FormatBool(gaze.Truncated))
+}
@@ -3,8 +3,10 @@ package handlers
 import (
 	"NewsEyeTracking/internal/models"
 	"NewsEyeTracking/internal/utils"
+	"context"
+	"fmt"
 	"net/http"
-
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -58,7 +60,7 @@ func (h *Handlers) GetNews(c *gin.Context) {
 	ctx, cancel := utils.WithDatabaseTimeout(c.Request.Context())
 	defer cancel()
 	// 这里是返回的新闻，也许 gjc 那边返回的就是 guid 呢
-	newsList, err := h.services.News.GetNews(ctx, userID, req.Limit, h.AddToNewsCache)
+	newsList, err := h.services.News.GetNews(ctx, userID, req.Limit)
 	//统计 guid 并保存,直接记录保存也行？
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
@@ -97,6 +99,13 @@ func (h *Handlers) GetNews(c *gin.Context) {
 		return
 	}
 	
+	// 记录本次展示，并给每条新闻分配展示ID
+	listSessionID := uuid.Nil
+	if sessionForList != nil {
+		listSessionID = sessionForList.SessionID
+	}
+	h.recordNewsExposure(userID, newsList, listSessionID)
+
 	// 构建响应，包含会话信息
 	response := map[string]interface{}{
 		"news_list": newsList,
@@ -112,7 +121,8 @@ func (h *Handlers) GetNews(c *gin.Context) {
 }
 
 // GetNewsDetail 获取新闻详情
-// GET /api/v1/news/:id
+// GET /api/v1/news/:id?impression_id=
+// 从列表页打开时带上列表项的 impression_id，没有时按该用户最近一次展示这篇新闻关联点击
 func (h *Handlers) GetNewsDetail(c *gin.Context) {
 	// 从JWT中间件获取用户ID（用于A/B测试判断）
 	userIDRaw, exists := c.Get("userID")
@@ -191,6 +201,10 @@ func (h *Handlers) GetNewsDetail(c *gin.Context) {
 		return
 	}
 
+	if sessionForFeed != nil {
+		h.recordNewsClick(ctx, userID, newsIDStr, c.Query("impression_id"), sessionForFeed.SessionID)
+	}
+
 	response := map[string]interface{}{
 		"news_detail": newsDetail,
 	}
@@ -203,3 +217,173 @@ func (h *Handlers) GetNewsDetail(c *gin.Context) {
 	// 返回新闻详情和会话信息
 	c.JSON(http.StatusOK, models.SuccessResponse(response))
 }
+
+// newsImpressionTTL 展示在内存中保留多久，超过后打开详情不再关联到这次展示
+const newsImpressionTTL = 24 * time.Hour
+
+// newsImpressionRef 内存中保留的一次展示，用于关联之后的详情页点击
+type newsImpressionRef struct {
+	shownAt       time.Time
+	impressionID  string
+	guid          string
+	position      int
+	strategy      string
+	listSessionID string
+}
+
+// recordNewsExposure 给列表中的每条新闻分配展示ID，把本次展示加入新闻缓存，并记住展示用于关联点击
+func (h *Handlers) recordNewsExposure(userID string, newsList *models.NewsListResponse, listSessionID uuid.UUID) {
+	if newsList == nil {
+		return
+	}
+	exposure := newsList.Exposure
+	if exposure == nil {
+		exposure = &models.NewsExposure{}
+	}
+
+	record := models.UserNewsRecord{
+		StartTime: time.Now(),
+		NewsGUIDs: make([]string, 0, len(newsList.Articles)),
+		Strategy:  exposure.Strategy,
+		UserID:    exposure.RecommendUserID,
+	}
+	if listSessionID != uuid.Nil {
+		record.ListSessionID = listSessionID.String()
+	}
+
+	refs := make([]newsImpressionRef, 0, len(newsList.Articles))
+	for i := range newsList.Articles {
+		article := &newsList.Articles[i]
+		article.ImpressionID = uuid.New().String()
+
+		impression := models.NewsImpression{
+			ImpressionID: article.ImpressionID,
+			GUID:         article.GUID,
+			Position:     i,
+		}
+		if score, ok := exposure.Scores[article.GUID]; ok {
+			impression.Score = &score
+		}
+		record.NewsGUIDs = append(record.NewsGUIDs, article.GUID)
+		record.Impressions = append(record.Impressions, impression)
+		refs = append(refs, newsImpressionRef{
+			shownAt:       record.StartTime,
+			impressionID:  impression.ImpressionID,
+			guid:          impression.GUID,
+			position:      impression.Position,
+			strategy:      record.Strategy,
+			listSessionID: record.ListSessionID,
+		})
+	}
+
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+	h.newsCache[userID] = append(h.newsCache[userID], record)
+	h.impressions[userID] = append(h.impressions[userID], refs...)
+}
+
+// recordNewsClick 记录一次打开新闻详情，优先按 impressionID 关联展示（内存中没有时查询 news_impressions），
+// 否则取该用户最近一次展示这篇新闻；找不到展示时仍然记录点击，展示相关字段为空
+func (h *Handlers) recordNewsClick(ctx context.Context, userID, guid, impressionID string, detailSessionID uuid.UUID) {
+	click := models.NewsClickRecord{
+		ClickID:         uuid.New().String(),
+		ClickedAt:       time.Now(),
+		GUID:            guid,
+		DetailSessionID: detailSessionID.String(),
+	}
+
+	h.cacheMutex.RLock()
+	ref, ok := h.findNewsImpression(userID, guid, impressionID)
+	h.cacheMutex.RUnlock()
+	if !ok && impressionID != "" {
+		ref, ok = h.loadNewsImpression(ctx, userID, guid, impressionID)
+	}
+
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+	if !ok {
+		ref, ok = h.latestNewsImpression(userID, guid)
+	}
+	if ok {
+		position := ref.position
+		click.ImpressionID = ref.impressionID
+		click.Position = &position
+		click.Strategy = ref.strategy
+		click.ListSessionID = ref.listSessionID
+	}
+	h.clickCache[userID] = append(h.clickCache[userID], click)
+}
+
+// findNewsImpression 按 impressionID 在内存中查找展示，调用方需持有 cacheMutex
+// 展示必须属于该用户且是同一篇新闻
+func (h *Handlers) findNewsImpression(userID, guid, impressionID string) (newsImpressionRef, bool) {
+	if impressionID == "" {
+		return newsImpressionRef{}, false
+	}
+	refs := h.impressions[userID]
+	for i := len(refs) - 1; i >= 0; i-- {
+		if refs[i].impressionID == impressionID && refs[i].guid == guid {
+			return refs[i], true
+		}
+	}
+	return newsImpressionRef{}, false
+}
+
+// latestNewsImpression 内存中该用户最近一次展示这篇新闻，调用方需持有 cacheMutex
+func (h *Handlers) latestNewsImpression(userID, guid string) (newsImpressionRef, bool) {
+	refs := h.impressions[userID]
+	for i := len(refs) - 1; i >= 0; i-- {
+		if refs[i].guid == guid {
+			return refs[i], true
+		}
+	}
+	return newsImpressionRef{}, false
+}
+
+// loadNewsImpression 从 news_impressions 查询服务重启前或已经过期的展示，查询失败时按找不到处理
+func (h *Handlers) loadNewsImpression(ctx context.Context, userID, guid, impressionID string) (newsImpressionRef, bool) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return newsImpressionRef{}, false
+	}
+	impressionUUID, err := uuid.Parse(impressionID)
+	if err != nil {
+		return newsImpressionRef{}, false
+	}
+
+	impression, err := h.services.Exposure.FindImpression(ctx, userUUID, guid, impressionUUID)
+	if err != nil {
+		fmt.Printf("警告: %v\n", err)
+		return newsImpressionRef{}, false
+	}
+	if impression == nil {
+		return newsImpressionRef{}, false
+	}
+
+	ref := newsImpressionRef{
+		shownAt:      impression.ShownAt,
+		impressionID: impression.ImpressionID.String(),
+		guid:         impression.Guid,
+		position:     int(impression.Position),
+		strategy:     impression.Strategy,
+	}
+	if impression.ListSessionID.Valid {
+		ref.listSessionID = impression.ListSessionID.UUID.String()
+	}
+	return ref, true
+}
+
+// pruneNewsImpressions 丢弃 before 之前的展示，调用方需持有 cacheMutex
+func (h *Handlers) pruneNewsImpressions(before time.Time) {
+	for userID, refs := range h.impressions {
+		// 展示按时间追加，找到第一个未过期的即可
+		i := sort.Search(len(refs), func(i int) bool { return !refs[i].shownAt.Before(before) })
+		if i == len(refs) {
+			delete(h.impressions, userID)
+			continue
+		}
+		if i > 0 {
+			h.impressions[userID] = append([]newsImpressionRef(nil), refs[i:]...)
+		}
+	}
+}
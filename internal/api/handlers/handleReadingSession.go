@@ -5,10 +5,15 @@ package handlers
 // 两个会话， 用户会话和阅读会话中的内容
 // 以及我们需要考虑每次接收到数据就更新 redis 中的 TTl
 import (
+	"NewsEyeTracking/internal/codec"
 	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/service"
 	"NewsEyeTracking/internal/utils"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -52,9 +57,9 @@ func (h *Handlers) EndReadingSession(c *gin.Context) {
 	}
 
 
-	// 解析请求体
+	// 解析请求体，支持 JSON 和 CBOR 两种编码
 	var req models.EndSessionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindEndSessionRequest(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse(
 			models.ErrorCodeInvalidRequest,
 			"请求参数不正确",
@@ -64,6 +69,16 @@ func (h *Handlers) EndReadingSession(c *gin.Context) {
 	}
 	//sessionID := req.SessionID.String()
 
+	// 最后一批追踪数据与普通数据上传使用同样的验证规则
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.ErrorCodeInvalidRequest,
+			"请求数据验证失败",
+			err.Error(),
+		))
+		return
+	}
+
 	// 如果没有提供结束时间，使用当前时间
 	if req.EndTime.IsZero() {
 		req.EndTime = time.Now()
@@ -84,6 +99,18 @@ func (h *Handlers) EndReadingSession(c *gin.Context) {
 		return
 	}
 
+	// 同一会话仍在使用 WebSocket 上传的连接不再接受新数据
+	if sessionUUID, err := uuid.Parse(sessionID); err == nil {
+		h.markIngestEnded(sessionUUID)
+
+		// 记录客户端声明的最后批次序号，完整性报告据此发现末尾丢失的批次
+		if req.FinalSeq > 0 {
+			if err := h.services.BatchLedger.SetFinalSeq(ctx, sessionUUID, req.FinalSeq); err != nil {
+				fmt.Printf("警告: %v\n", err)
+			}
+		}
+	}
+
 	// 会话验证成功后，才保存最后一批追踪数据
 	if req.Data != nil && !req.Data.IsEmpty() {
 		// 构造一个临时的SessionDataRequest来复用缓存逻辑
@@ -102,9 +129,11 @@ func (h *Handlers) EndReadingSession(c *gin.Context) {
 			SessionID: &sessionUUID,
 			Data:      req.Data,
 			Timestamp: req.EndTime,
+			Seq:       req.Seq,
+			BatchID:   req.BatchID,
 		}
 		
-		if err := h.addToTrackingCache(userID, &tempReq); err != nil {
+		if _, err := h.ingestTrackingBatch(c.Request.Context(), userID, &tempReq); err != nil {
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse(
 				models.ErrorCodeInternalError,
 				"保存最后一批追踪数据失败",
@@ -114,6 +143,14 @@ func (h *Handlers) EndReadingSession(c *gin.Context) {
 		}
 	}
 
+	// 下次刷新写入最后一批数据后重建会话的文档坐标、计算概要指标
+	if sessionUUID, err := uuid.Parse(sessionID); err == nil {
+		h.requestSessionProcessing(sessionUUID)
+	}
+
+	// 该用户当天的追踪文件在下次刷新后封存，之后即可上传
+	h.requestSeal(userID)
+
 	// 返回成功响应
 	response := gin.H{
 		"message":    "会话已成功结束",
@@ -125,6 +162,73 @@ func (h *Handlers) EndReadingSession(c *gin.Context) {
 }
 
 
+// GetSessionSummary 获取已结束阅读会话的概要指标
+// GET /api/v1/sessions/:session_id/summary
+func (h *Handlers) GetSessionSummary(c *gin.Context) {
+	userIDRaw, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			models.ErrorCodeUnauthorized,
+			"未找到用户信息",
+			"用户未认证",
+		))
+		return
+	}
+	userID, err := uuid.Parse(fmt.Sprint(userIDRaw))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.ErrorCodeInternalError,
+			"用户ID类型错误",
+			"无法解析用户ID",
+		))
+		return
+	}
+
+	sessionID, ok := parseSessionIDParam(c, "session_id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := utils.WithComplexQueryTimeout(c.Request.Context())
+	defer cancel()
+
+	// 先确认会话属于当前用户，其他用户的会话按不存在处理
+	session, err := h.services.Session.GetSessionByID(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		if err == nil || err == sql.ErrNoRows {
+			err = service.ErrSessionNotFound
+		}
+		respondAnalysisError(c, err)
+		return
+	}
+
+	// 会话刚结束、最后一批数据还在缓存中，由定时刷新写入后计算指标
+	if h.sessionProcessingPending(sessionID) {
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusAccepted, models.SuccessResponse(gin.H{
+			"message":    "概要指标计算中，请稍后再试",
+			"session_id": sessionID,
+		}))
+		return
+	}
+
+	summary, err := h.services.Analysis.SessionSummary(ctx, userID, sessionID)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotEnded) {
+			c.JSON(http.StatusConflict, models.ErrorResponse(
+				models.ErrorCodeConflict,
+				"阅读会话尚未结束",
+				err.Error(),
+			))
+			return
+		}
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(summary))
+}
+
 // POST /api/v1/sessions/:session_id/data
 func (h *Handlers) ProcessSessionData(c *gin.Context) {
 	// 从JWT中间件获取用户ID
@@ -199,9 +303,9 @@ func (h *Handlers) ProcessSessionData(c *gin.Context) {
 		return
 	}
 
-	// 解析请求体
+	// 解析请求体，支持 JSON 和 CBOR 两种编码
 	var req models.SessionDataRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindSessionDataRequest(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse(
 			models.ErrorCodeInvalidRequest,
 			"请求参数不正确",
@@ -227,13 +331,15 @@ func (h *Handlers) ProcessSessionData(c *gin.Context) {
 
 	// 处理心跳包（可能携带数据）
 	if req.IsHeartbeat() {
+		duplicate := false
 		// 1. 如果心跳包携带追踪数据，先保存数据
 		if req.HasTrackingData() {
 			// 设置会话ID（从 URL 参数）
 			req.SessionID = &sessionID
 			
-			// 添加到缓存
-			if err := h.addToTrackingCache(userID, &req); err != nil {
+			// 添加到缓存，重放的批次不会重复保存
+			dup, err := h.ingestTrackingBatch(c.Request.Context(), userID, &req)
+			if err != nil {
 				c.JSON(http.StatusInternalServerError, models.ErrorResponse(
 					models.ErrorCodeInternalError,
 					"心跳包保存追踪数据失败",
@@ -241,6 +347,7 @@ func (h *Handlers) ProcessSessionData(c *gin.Context) {
 				))
 				return
 			}
+			duplicate = dup
 		}
 		
 		// 2. 更新用户会话的 Redis 心跳状态
@@ -250,20 +357,13 @@ func (h *Handlers) ProcessSessionData(c *gin.Context) {
 			activeSession, err := h.services.UserSession.GetActiveUserSessionByUserID(c.Request.Context(), userUUID)
 			if err == nil && activeSession.IsActive.Bool {
 				// 更新用户会话心跳
-				heartbeatReq := &models.HeartbeatRequest{
-					SessionID: activeSession.ID,
-					Timestamp: req.Timestamp,
-				}
-				_, heartbeatErr := h.services.UserSession.Heartbeat(c.Request.Context(), heartbeatReq)
-				if heartbeatErr != nil {
-					// 心跳更新失败不影响主流程，只记录日志
-					fmt.Printf("更新用户会话心跳失败: %v\n", heartbeatErr)
-				}
+				h.heartbeatUserSession(c.Request.Context(), activeSession.ID, req.Timestamp)
 			}
 		}
 		
 		// 3. 返回心跳包响应
 		response := models.NewHeartbeatResponse()
+		response.Duplicate = duplicate
 		c.JSON(http.StatusOK, models.SuccessResponse(response))
 		return
 	}
@@ -273,8 +373,9 @@ func (h *Handlers) ProcessSessionData(c *gin.Context) {
 		// 设置会话ID（从URL参数）
 		req.SessionID = &sessionID
 
-		// 添加到缓存
-		if err := h.addToTrackingCache(userID, &req); err != nil {
+		// 添加到缓存，客户端重试造成的重放批次直接返回成功
+		duplicate, err := h.ingestTrackingBatch(c.Request.Context(), userID, &req)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse(
 				models.ErrorCodeInternalError,
 				"保存追踪数据失败",
@@ -285,6 +386,7 @@ func (h *Handlers) ProcessSessionData(c *gin.Context) {
 
 		// 返回数据处理成功响应
 		response := models.NewDataResponse(sessionID)
+		response.Duplicate = duplicate
 		c.JSON(http.StatusOK, models.SuccessResponse(response))
 		return
 	}
@@ -297,3 +399,63 @@ func (h *Handlers) ProcessSessionData(c *gin.Context) {
 	))
 }
 
+// heartbeatUserSession 更新用户会话心跳，失败不影响主流程，只记录日志
+func (h *Handlers) heartbeatUserSession(ctx context.Context, userSessionID uuid.UUID, timestamp time.Time) {
+	ctx, cancel := utils.WithWriteTimeout(ctx)
+	defer cancel()
+
+	heartbeatReq := &models.HeartbeatRequest{
+		SessionID: userSessionID,
+		Timestamp: timestamp,
+	}
+	if _, err := h.services.UserSession.Heartbeat(ctx, heartbeatReq); err != nil {
+		fmt.Printf("更新用户会话心跳失败: %v\n", err)
+	}
+}
+
+// maxBinaryPayloadSize CBOR 请求体的大小上限
+const maxBinaryPayloadSize = 8 << 20
+
+// bindSessionDataRequest 按 Content-Type 解析数据上传请求
+func bindSessionDataRequest(c *gin.Context, req *models.SessionDataRequest) error {
+	if c.ContentType() != codec.ContentTypeCBOR {
+		return c.ShouldBindJSON(req)
+	}
+
+	payload, err := readBinaryPayload(c)
+	if err != nil {
+		return err
+	}
+	decoded, err := codec.DecodeSessionData(payload)
+	if err != nil {
+		return err
+	}
+	*req = *decoded
+	return nil
+}
+
+// bindEndSessionRequest 按 Content-Type 解析结束会话请求
+func bindEndSessionRequest(c *gin.Context, req *models.EndSessionRequest) error {
+	if c.ContentType() != codec.ContentTypeCBOR {
+		return c.ShouldBindJSON(req)
+	}
+
+	payload, err := readBinaryPayload(c)
+	if err != nil {
+		return err
+	}
+	decoded, err := codec.DecodeEndSession(payload)
+	if err != nil {
+		return err
+	}
+	*req = *decoded
+	return nil
+}
+
+func readBinaryPayload(c *gin.Context) ([]byte, error) {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBinaryPayloadSize))
+	if err != nil {
+		return nil, fmt.Errorf("读取请求体失败: %w", err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+// 实验者实时观看参与者的眼动数据
+// 使用 Server-Sent Events 推送，浏览器端直接用 EventSource 订阅：
+//   new EventSource("/api/v1/admin/sessions/<id>/stream?access_token=<实验者令牌>")
+// 事件类型：ready（订阅成功）、gaze（一批追踪数据）、dropped（观察端消费过慢丢弃的累计批数）、ping（保活）
+import (
+	"NewsEyeTracking/internal/models"
+	"database/sql"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamKeepAlive 保活事件间隔，避免代理因空闲断开连接
+const streamKeepAlive = 15 * time.Second
+
+// StreamSessionGaze 实时推送阅读会话收到的追踪数据
+// GET /api/v1/admin/sessions/:id/stream
+func (h *Handlers) StreamSessionGaze(c *gin.Context) {
+	sessionID, ok := parseSessionIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	session, err := h.services.Session.GetSessionByID(c.Request.Context(), sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				models.ErrorCodeSessionNotFound,
+				"阅读会话不存在",
+				"会话ID无效",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.ErrorCodeInternalError,
+			"查询阅读会话失败",
+			err.Error(),
+		))
+		return
+	}
+
+	sub := h.gazeHub.Subscribe(sessionID)
+	if sub == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse(
+			models.ErrorCodeInternalError,
+			"服务正在关闭",
+			"无法订阅实时数据",
+		))
+		return
+	}
+	defer h.gazeHub.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 的响应缓冲
+
+	c.SSEvent("ready", gin.H{
+		"session_id": sessionID,
+		"article_id": session.ArticleID,
+		"ended":      session.EndTime.Valid,
+	})
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	var reportedDropped int64
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+			c.SSEvent("gaze", event)
+			if dropped := sub.Dropped(); dropped != reportedDropped {
+				c.SSEvent("dropped", gin.H{"count": dropped})
+				reportedDropped = dropped
+			}
+			return true
+		case <-keepAlive.C:
+			c.SSEvent("ping", time.Now().UnixMilli())
+			return true
+		}
+	})
+}
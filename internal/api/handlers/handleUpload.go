@@ -1,5 +1,124 @@
 package handlers
 
-import(
-	
-)
\ No newline at end of file
+import (
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/service"
+	"NewsEyeTracking/internal/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListUploadBatches 列出上传队列中的批次
+// GET /api/v1/admin/uploads?state=pending|uploading|uploaded|failed
+func (h *Handlers) ListUploadBatches(c *gin.Context) {
+	state := c.Query("state")
+	switch state {
+	case "", utils.BatchStatePending, utils.BatchStateUploading, utils.BatchStateUploaded, utils.BatchStateFailed:
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.ErrorCodeInvalidRequest,
+			"批次状态无效",
+			"state 可选 pending、uploading、uploaded、failed",
+		))
+		return
+	}
+
+	batches, err := h.services.Upload.ListBatches(c.Request.Context(), state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.ErrorCodeInternalError,
+			"读取上传队列失败",
+			err.Error(),
+		))
+		return
+	}
+	if batches == nil {
+		batches = []*utils.UploadBatch{}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(gin.H{
+		"batches": batches,
+		"total":   len(batches),
+	}))
+}
+
+// RetryUploadBatch 把失败的批次移回上传队列并立即重试
+// POST /api/v1/admin/uploads/:id/retry
+func (h *Handlers) RetryUploadBatch(c *gin.Context) {
+	batch, err := h.services.Upload.RetryBatch(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrBatchNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse(models.ErrorCodeNotFound, "上传批次不存在", err.Error()))
+		case errors.Is(err, utils.ErrBatchNotFailed):
+			c.JSON(http.StatusConflict, models.ErrorResponse(models.ErrorCodeConflict, "批次不在失败状态", err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "重试上传批次失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse(batch))
+}
+
+// CleanupRetention 按环境变量中的保留策略立即清理一次过期数据
+// POST /api/v1/admin/retention/cleanup?dry_run=true
+// 默认为试运行，只返回将要删除的内容；dry_run=false 时才真正删除
+func (h *Handlers) CleanupRetention(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "dry_run 参数无效", err.Error()))
+		return
+	}
+
+	ctx, cancel := utils.WithComplexQueryTimeout(c.Request.Context())
+	defer cancel()
+
+	report, err := h.services.Upload.CleanupOldData(ctx, service.RetentionPolicyFromEnv(), dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "数据保留清理失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(report))
+}
+
+// TriggerUpload 立即在后台打包并上传全部文件，进度通过 GET /api/v1/admin/uploads/status 查询
+// POST /api/v1/admin/uploads/run?ignore_quiet_hours=true
+func (h *Handlers) TriggerUpload(c *gin.Context) {
+	ignoreQuietHours, err := strconv.ParseBool(c.DefaultQuery("ignore_quiet_hours", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(models.ErrorCodeInvalidRequest, "ignore_quiet_hours 参数无效", err.Error()))
+		return
+	}
+
+	run, err := h.services.Upload.StartUpload(c.Request.Context(), ignoreQuietHours)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrQuietHours):
+			c.JSON(http.StatusConflict, models.ErrorResponse(models.ErrorCodeConflict, "当前处于上传静默时段", "如需立即上传，请设置 ignore_quiet_hours=true"))
+		case errors.Is(err, utils.ErrUploadInProgress):
+			c.JSON(http.StatusConflict, models.ErrorResponse(models.ErrorCodeConflict, "已有上传任务在进行", err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "启动上传失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse(run))
+}
+
+// GetUploadStatus 获取上传计划和正在进行（或最近一次）的上传进度
+// GET /api/v1/admin/uploads/status
+func (h *Handlers) GetUploadStatus(c *gin.Context) {
+	status, err := h.services.Upload.GetUploadStatus(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(models.ErrorCodeInternalError, "获取上传状态失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(status))
+}
@@ -0,0 +1,285 @@
+package handlers
+
+// WebSocket 追踪数据上传通道
+// 每个阅读会话一条长连接：握手时完成一次 JWT 认证和会话查询，之后的数据帧和心跳帧
+// 不再访问数据库，数据写入与 HTTP 上传接口相同的预写日志和缓存。
+//
+// 客户端帧: {"type":"data","seq":1,"timestamp":"...","data":{...}} 或 {"type":"ping","seq":2,"timestamp":"..."}
+// 服务端帧: {"type":"ack","seq":1,...}、{"type":"pong","seq":2,...}、{"type":"error","seq":1,"code":"...","message":"..."}
+// ack 表示数据已经 fsync 到预写日志；收到 error 或连接断开时，客户端应重发尚未 ack 的帧。
+// 数据帧可以携带 batch_seq/batch_id，重连后重发的批次会被去重，ack 中 duplicate 为 true。
+import (
+	"NewsEyeTracking/internal/api/middleware"
+	"NewsEyeTracking/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsReadLimit         = 4 << 20          // 单帧大小上限
+	wsPongWait          = 60 * time.Second // 超过该时间没有收到任何帧则断开
+	wsPingInterval      = 25 * time.Second // 服务端发送 WebSocket ping 的间隔
+	wsWriteWait         = 10 * time.Second
+	wsHeartbeatInterval = 20 * time.Second // 用户会话心跳写库的最小间隔，心跳 TTL 为 1 分钟
+)
+
+var trackingUpgrader = websocket.Upgrader{
+	ReadBufferSize:  16 << 10,
+	WriteBufferSize: 4 << 10,
+	CheckOrigin:     middleware.WebSocketOriginAllowed,
+}
+
+// ingestConn 一条上传连接
+type ingestConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	ended   atomic.Bool // 会话已通过 HTTP 接口结束
+}
+
+func (ic *ingestConn) reply(reply models.TrackingFrameReply) error {
+	reply.Timestamp = time.Now()
+
+	ic.writeMu.Lock()
+	defer ic.writeMu.Unlock()
+	ic.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return ic.conn.WriteJSON(reply)
+}
+
+func (ic *ingestConn) replyError(seq uint64, code, message string) error {
+	return ic.reply(models.TrackingFrameReply{
+		Type:    models.FrameTypeError,
+		Seq:     seq,
+		Code:    code,
+		Message: message,
+	})
+}
+
+func (ic *ingestConn) control(messageType int, data []byte) error {
+	ic.writeMu.Lock()
+	defer ic.writeMu.Unlock()
+	return ic.conn.WriteControl(messageType, data, time.Now().Add(wsWriteWait))
+}
+
+// TrackingWebSocket 建立追踪数据上传的 WebSocket 连接
+// GET /api/v1/sessions/:session_id/ws
+func (h *Handlers) TrackingWebSocket(c *gin.Context) {
+	userIDRaw, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			models.ErrorCodeUnauthorized,
+			"未找到用户信息",
+			"用户未认证",
+		))
+		return
+	}
+
+	userID, ok := userIDRaw.(string)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.ErrorCodeInternalError,
+			"用户ID类型错误",
+			"无法解析用户ID",
+		))
+		return
+	}
+
+	sessionID, ok := parseSessionIDParam(c, "session_id")
+	if !ok {
+		return
+	}
+
+	// 握手时查询一次会话，之后不再访问数据库
+	session, err := h.services.Session.GetSessionByID(c.Request.Context(), sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				models.ErrorCodeInvalidRequest,
+				"阅读会话不存在",
+				"会话ID无效",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.ErrorCodeInternalError,
+			"查询阅读会话失败",
+			err.Error(),
+		))
+		return
+	}
+
+	if session.UserID.String() != userID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse(
+			models.ErrorCodeForbidden,
+			"无权访问该会话",
+			"会话不属于当前用户",
+		))
+		return
+	}
+
+	if session.EndTime.Valid {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.ErrorCodeInvalidRequest,
+			"会话已结束",
+			"无法向已结束的会话发送数据",
+		))
+		return
+	}
+
+	// 心跳帧需要更新用户会话，活跃的用户会话也只在握手时查询一次
+	var userSessionID uuid.UUID
+	if activeSession, err := h.services.UserSession.GetActiveUserSessionByUserID(c.Request.Context(), session.UserID); err == nil && activeSession.IsActive.Bool {
+		userSessionID = activeSession.ID
+	}
+
+	conn, err := trackingUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 失败时已经向客户端写入了错误响应
+		fmt.Printf("WebSocket 握手失败: %v\n", err)
+		return
+	}
+	defer conn.Close()
+
+	ic := &ingestConn{conn: conn}
+	h.registerIngestConn(sessionID, ic)
+	defer h.unregisterIngestConn(sessionID, ic)
+
+	done := make(chan struct{})
+	defer close(done)
+	go h.pingIngestConn(ic, done)
+
+	conn.SetReadLimit(wsReadLimit)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	var lastHeartbeat time.Time
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				fmt.Printf("用户%s的会话%s上传连接异常断开: %v\n", userID, sessionID, err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var frame models.TrackingFrame
+		if err := json.Unmarshal(message, &frame); err != nil {
+			if ic.replyError(0, models.ErrorCodeInvalidRequest, "帧格式不正确: "+err.Error()) != nil {
+				return
+			}
+			continue
+		}
+
+		if err := h.handleTrackingFrame(c.Request.Context(), ic, userID, sessionID, userSessionID, &frame, &lastHeartbeat); err != nil {
+			return
+		}
+	}
+}
+
+// handleTrackingFrame 处理一帧数据，返回错误表示连接需要关闭
+func (h *Handlers) handleTrackingFrame(ctx context.Context, ic *ingestConn, userID string, sessionID, userSessionID uuid.UUID,
+	frame *models.TrackingFrame, lastHeartbeat *time.Time) error {
+	if frame.Type != models.FrameTypeData && frame.Type != models.FrameTypePing {
+		return ic.replyError(frame.Seq, models.ErrorCodeInvalidRequest, "未知的帧类型: "+frame.Type)
+	}
+
+	if frame.Timestamp.IsZero() {
+		frame.Timestamp = time.Now()
+	}
+	req := frame.ToSessionDataRequest(sessionID)
+
+	if frame.Type == models.FrameTypeData && !req.HasTrackingData() {
+		return ic.replyError(frame.Seq, models.ErrorCodeInvalidRequest, "数据帧必须包含有效的追踪数据")
+	}
+
+	// 与 HTTP 接口一样，心跳帧也可以携带追踪数据；重放的批次只回复 ack 不重复保存
+	duplicate := false
+	if req.HasTrackingData() {
+		if ic.ended.Load() {
+			ic.replyError(frame.Seq, models.ErrorCodeInvalidRequest, "会话已结束")
+			ic.control(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session ended"))
+			return fmt.Errorf("会话已结束")
+		}
+		if err := req.Validate(); err != nil {
+			return ic.replyError(frame.Seq, models.ErrorCodeInvalidRequest, err.Error())
+		}
+		dup, err := h.ingestTrackingBatch(ctx, userID, req)
+		if err != nil {
+			return ic.replyError(frame.Seq, models.ErrorCodeInternalError, err.Error())
+		}
+		duplicate = dup
+	}
+
+	if frame.Type == models.FrameTypePing {
+		if userSessionID != uuid.Nil && time.Since(*lastHeartbeat) >= wsHeartbeatInterval {
+			h.heartbeatUserSession(ctx, userSessionID, req.Timestamp)
+			*lastHeartbeat = time.Now()
+		}
+		return ic.reply(models.TrackingFrameReply{Type: models.FrameTypePong, Seq: frame.Seq, Duplicate: duplicate})
+	}
+
+	return ic.reply(models.TrackingFrameReply{Type: models.FrameTypeAck, Seq: frame.Seq, Duplicate: duplicate})
+}
+
+// pingIngestConn 定期发送 WebSocket ping；服务停止时通知客户端关闭连接
+func (h *Handlers) pingIngestConn(ic *ingestConn, done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-h.closing:
+			ic.control(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+			ic.conn.Close()
+			return
+		case <-ticker.C:
+			if err := ic.control(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *Handlers) registerIngestConn(sessionID uuid.UUID, ic *ingestConn) {
+	h.ingestMutex.Lock()
+	defer h.ingestMutex.Unlock()
+
+	if h.ingestConns[sessionID] == nil {
+		h.ingestConns[sessionID] = make(map[*ingestConn]struct{})
+	}
+	h.ingestConns[sessionID][ic] = struct{}{}
+}
+
+func (h *Handlers) unregisterIngestConn(sessionID uuid.UUID, ic *ingestConn) {
+	h.ingestMutex.Lock()
+	defer h.ingestMutex.Unlock()
+
+	delete(h.ingestConns[sessionID], ic)
+	if len(h.ingestConns[sessionID]) == 0 {
+		delete(h.ingestConns, sessionID)
+	}
+}
+
+// markIngestEnded 会话通过 HTTP 接口结束后，拒绝该会话上传连接中的后续数据
+func (h *Handlers) markIngestEnded(sessionID uuid.UUID) {
+	h.ingestMutex.Lock()
+	defer h.ingestMutex.Unlock()
+
+	for ic := range h.ingestConns[sessionID] {
+		ic.ended.Store(true)
+	}
+}
@@ -2,25 +2,47 @@ package handlers
 
 import (
 	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/realtime"
 	"NewsEyeTracking/internal/service"
-	"encoding/json"
+	"NewsEyeTracking/internal/storage"
+	"NewsEyeTracking/internal/utils"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// walAppendAttempts 刷新失败的记录重新写入预写日志的尝试次数
+const walAppendAttempts = 3
+
 // Handlers 处理程序结构体，持有所有依赖项
 type Handlers struct {
-	services      *service.Services
-	trackingCache map[string][]models.UserTrackingRecord // 用户ID -> 追踪记录列表
-	newsCache     map[string][]models.UserNewsRecord     // 用户ID -> 新闻记录列表
-	cacheMutex    sync.RWMutex
-	lastFlush     time.Time
-	flushTicker   *time.Ticker
+	services         *service.Services
+	trackingCache    map[string][]models.UserTrackingRecord // 用户ID -> 追踪记录列表
+	newsCache        map[string][]models.UserNewsRecord     // 用户ID -> 新闻记录列表
+	clickCache       map[string][]models.NewsClickRecord    // 用户ID -> 新闻点击记录列表
+	impressions      map[string][]newsImpressionRef         // 用户ID -> 最近的新闻展示，用于把详情页点击关联到展示
+	sealPending      map[string]struct{}                    // 会话已结束、下次刷新后需要封存追踪文件的用户
+	endedPending     map[uuid.UUID]struct{}                 // 已结束、下次刷新后需要做会话级处理（文档坐标、概要指标）的会话
+	cacheMutex       sync.RWMutex
+	walMutex         sync.RWMutex // 写预写日志时持有读锁，刷新轮转段文件时持有写锁
+	flushMutex       sync.Mutex   // 串行化追踪数据的刷新，避免并发写同一个文件
+	trackingWAL      *storage.WAL
+	retainedSegments []string      // 失败记录未能全部重新写入预写日志时保留的旧段，之后的刷新全部重新写入后一并删除（由 flushMutex 保护）
+	gazeHub          *realtime.Hub // 实验者实时观看的订阅中心
+	ingestMutex      sync.Mutex
+	ingestConns      map[uuid.UUID]map[*ingestConn]struct{} // 阅读会话ID -> WebSocket 上传连接
+	closing          chan struct{}                          // 服务停止时关闭，通知长连接退出
+	lastFlush        time.Time
+	flushTicker      *time.Ticker
 }
 
 // 一个架构设计，通过 handler 来处理所有定义的服务
@@ -29,10 +51,37 @@ func NewHandlers(services *service.Services) *Handlers {
 		services:      services,
 		trackingCache: make(map[string][]models.UserTrackingRecord),
 		newsCache:     make(map[string][]models.UserNewsRecord),
+		clickCache:    make(map[string][]models.NewsClickRecord),
+		impressions:   make(map[string][]newsImpressionRef),
+		sealPending:   make(map[string]struct{}),
+		endedPending:  make(map[uuid.UUID]struct{}),
+		gazeHub:       realtime.NewHub(0),
+		ingestConns:   make(map[uuid.UUID]map[*ingestConn]struct{}),
+		closing:       make(chan struct{}),
 		lastFlush:     time.Now(),
 		flushTicker:   time.NewTicker(30 * time.Second), // 每30秒刷新一次
 	}
 
+	// 打开预写日志并重放上次未落地的追踪数据，必须在接收新数据之前完成
+	walDir := os.Getenv("TRACKING_WAL_DIR")
+	if walDir == "" {
+		walDir = "data/wal"
+	}
+	walShards, _ := strconv.Atoi(os.Getenv("TRACKING_WAL_SHARDS"))
+	wal, err := storage.OpenWAL(walDir, walShards)
+	if err != nil {
+		log.Fatalf("初始化追踪数据预写日志失败: %v", err)
+	}
+	h.trackingWAL = wal
+
+	replayed, err := wal.Replay(h.replayTrackingEntries)
+	if err != nil {
+		log.Fatalf("重放追踪数据预写日志失败: %v", err)
+	}
+	if replayed > 0 {
+		log.Printf("已从预写日志恢复 %d 条追踪记录", replayed)
+	}
+
 	// 启动后台统一缓存刷新任务
 	go h.flushCacheRoutine()
 
@@ -55,63 +104,119 @@ func (h *Handlers) flushCacheRoutine() {
 	}
 }
 
-// addToTrackingCache 将追踪数据添加到内存缓存
+// addToTrackingCache 将追踪数据写入预写日志后添加到内存缓存
+// 返回 nil 时数据已经 fsync 到预写日志，进程崩溃也不会丢失
 func (h *Handlers) addToTrackingCache(userID string, req *models.SessionDataRequest) error {
 	if req == nil || req.SessionID == nil || req.Data == nil {
 		return fmt.Errorf("追踪数据不完整")
 	}
 
-	h.cacheMutex.Lock()
-	defer h.cacheMutex.Unlock()
-
 	// 创建追踪记录
 	record := models.UserTrackingRecord{
 		SessionID: *req.SessionID,
 		StartTime: req.Timestamp,
+		Seq:       req.Seq,
+		BatchID:   req.BatchID,
 		Data:      *req.Data,
 	}
 
+	h.walMutex.RLock()
+	defer h.walMutex.RUnlock()
+
+	if err := h.trackingWAL.Append(userID, &record); err != nil {
+		return fmt.Errorf("写入追踪数据预写日志失败: %w", err)
+	}
+
+	h.cacheMutex.Lock()
 	h.trackingCache[userID] = append(h.trackingCache[userID], record)
+	h.cacheMutex.Unlock()
+
+	// 推送给正在观看该会话的实验者，不会阻塞；没有人观看时不构造消息
+	if h.gazeHub.HasSubscribers(record.SessionID) {
+		h.gazeHub.Publish(realtime.GazeEvent{
+			SessionID:  record.SessionID,
+			UserID:     userID,
+			ReceivedAt: time.Now(),
+			Timestamp:  record.StartTime,
+			Data:       record.Data,
+		})
+	}
 
 	return nil
 }
 
-// flushTrackingCache 将追踪缓存数据写入文件
+// ingestTrackingBatch 对携带批次信息的数据先去重再保存，返回 true 表示是已经收到过的重放批次
+// 批次台账不可用时不做去重直接保存，宁可重复也不丢数据
+func (h *Handlers) ingestTrackingBatch(ctx context.Context, userID string, req *models.SessionDataRequest) (bool, error) {
+	if !req.HasBatchIdentity() || req.SessionID == nil {
+		return false, h.addToTrackingCache(userID, req)
+	}
+
+	ctx, cancel := utils.WithDatabaseTimeout(ctx)
+	defer cancel()
+
+	duplicate, err := h.services.BatchLedger.RegisterBatch(ctx, *req.SessionID, req.Seq, req.BatchID)
+	if err != nil {
+		fmt.Printf("警告: %v，本批数据不做去重\n", err)
+		return false, h.addToTrackingCache(userID, req)
+	}
+	if duplicate {
+		return true, nil
+	}
+
+	if err := h.addToTrackingCache(userID, req); err != nil {
+		if releaseErr := h.services.BatchLedger.ReleaseBatch(ctx, *req.SessionID, req.Seq, req.BatchID); releaseErr != nil {
+			fmt.Printf("警告: %v\n", releaseErr)
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// flushTrackingCache 将追踪缓存数据写入文件，成功后删除对应的预写日志段
 func (h *Handlers) flushTrackingCache() {
+	h.flushMutex.Lock()
+	defer h.flushMutex.Unlock()
+
+	// 交换缓存并轮转预写日志段，持有写锁保证两者包含的数据完全一致
+	h.walMutex.Lock()
 	h.cacheMutex.Lock()
-	defer h.cacheMutex.Unlock()
+	cache := h.trackingCache
+	h.trackingCache = make(map[string][]models.UserTrackingRecord)
+	sealUsers := h.sealPending
+	h.sealPending = make(map[string]struct{})
+	endedSessions := h.endedPending
+	h.endedPending = make(map[uuid.UUID]struct{})
+	h.cacheMutex.Unlock()
+	segments, rotateErr := h.trackingWAL.Rotate()
+	h.walMutex.Unlock()
+
+	// 本次刷新写入后再封存，会话结束前缓存中的数据也包含在封存文件中
+	defer h.sealUserFiles(sealUsers)
+	// 会话级处理在封存之前执行（defer 后进先出），结果写入即将封存的文件
+	defer h.processEndedSessions(endedSessions)
+
+	if rotateErr != nil {
+		fmt.Printf("警告: 轮转追踪数据预写日志失败: %v\n", rotateErr)
+	}
 
-	if len(h.trackingCache) == 0 {
+	if len(cache) == 0 {
 		return
 	}
 
 	// 创建今天的日期目录 - 统一所有追踪数据（眼动、点击、滚动）
 	today := time.Now().Format("2006-01-02")
-	dateDir := fmt.Sprintf("data/tracking/%s", today)
-	if err := os.MkdirAll(dateDir, 0755); err != nil {
-		fmt.Printf("警告: 无法创建追踪数据目录: %v\n", err)
-		return
-	}
+	failed := make(map[string][]models.UserTrackingRecord)
 
 	// 为每个用户批量写入数据
-	for userID, records := range h.trackingCache {
+	for userID, records := range cache {
 		if len(records) == 0 {
 			continue
 		}
 
-		// 创建批量记录结构
-		batchRecord := models.UserTrackingBatchRecord{
-			FlushTime: time.Now(),
-			Records:   records,
-		}
-
-		// 文件名使用用户ID
-		fileName := fmt.Sprintf("%s.json", userID)
-		filePath := fmt.Sprintf("%s/%s", dateDir, fileName)
-
-		// 如果文件已存在，则追加到现有记录中
-		if err := h.appendToTrackingFile(filePath, batchRecord); err != nil {
+		if err := h.writeTrackingRecords(today, userID, records); err != nil {
 			fmt.Printf("警告: 无法写入用户%s的追踪数据文件: %v\n", userID, err)
+			failed[userID] = records
 			continue
 		}
 
@@ -127,157 +232,239 @@ func (h *Handlers) flushTrackingCache() {
 
 		fmt.Printf("成功写入用户%s的%d条追踪记录（眼动:%d, 点击:%d, 滚动:%d）\n",
 			userID, len(records), totalEyeEvents, totalClickEvents, totalScrollEvents)
+
+		// 原始数据落地后再做注视检测，检测失败不影响原始数据
+		if err := h.services.Analysis.DetectBatchFixations(today, userID, records); err != nil {
+			fmt.Printf("警告: 用户%s的注视检测失败: %v\n", userID, err)
+		}
 	}
 
-	// 通过新创建来手动更新缓存
-	h.trackingCache = make(map[string][]models.UserTrackingRecord)
+	// 写入失败的记录重新放回缓存并写入新的预写日志段，下次刷新时重试
+	// 所有失败记录都重新进入预写日志后才删除旧段；否则保留旧段，宁可崩溃重放时重复写入已落地的记录也不丢数据
+	// （同一批次同时出现在新旧段中时，重放按批次ID只写一次）
+	reappended := true
+	if len(failed) > 0 {
+		h.walMutex.RLock()
+		for userID, records := range failed {
+			for i := range records {
+				if err := h.appendWALWithRetry(userID, &records[i]); err != nil {
+					fmt.Printf("警告: 重新写入用户%s的预写日志失败，保留旧的预写日志段: %v\n", userID, err)
+					reappended = false
+				}
+			}
+			h.cacheMutex.Lock()
+			h.trackingCache[userID] = append(records, h.trackingCache[userID]...)
+			h.cacheMutex.Unlock()
+		}
+		h.walMutex.RUnlock()
+	}
+
+	if reappended {
+		if err := h.trackingWAL.Remove(append(h.retainedSegments, segments...)); err != nil {
+			fmt.Printf("警告: 删除已落地的预写日志段失败: %v\n", err)
+		}
+		h.retainedSegments = nil
+	} else {
+		h.retainedSegments = append(h.retainedSegments, segments...)
+	}
+
+	h.cacheMutex.Lock()
 	h.lastFlush = time.Now()
+	h.cacheMutex.Unlock()
+}
+
+// appendWALWithRetry 重新写入预写日志，失败时短暂等待后重试
+func (h *Handlers) appendWALWithRetry(userID string, record *models.UserTrackingRecord) error {
+	var err error
+	for attempt := 0; attempt < walAppendAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		if err = h.trackingWAL.Append(userID, record); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// writeTrackingRecords 将某个用户的追踪记录写入配置的落地目标（默认以 NDJSON 追加到指定日期的文件）
+func (h *Handlers) writeTrackingRecords(date, userID string, records []models.UserTrackingRecord) error {
+	ctx, cancel := utils.WithComplexQueryTimeout(context.Background())
+	defer cancel()
+	return h.services.TrackingSink.WriteTracking(ctx, date, userID, records)
 }
 
-// appendToTrackingFile 将新的批量记录追加到追踪数据文件中
-func (h *Handlers) appendToTrackingFile(filePath string, newBatchRecord models.UserTrackingBatchRecord) error {
-	var existingData models.UserTrackingBatchRecord
+// requestSeal 记录需要封存追踪文件的用户，在下一次刷新后执行
+func (h *Handlers) requestSeal(userID string) {
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+	h.sealPending[userID] = struct{}{}
+}
+
+// requestSessionProcessing 记录已结束的会话，在下一次刷新写入最后一批数据后做会话级处理
+func (h *Handlers) requestSessionProcessing(sessionID uuid.UUID) {
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+	h.endedPending[sessionID] = struct{}{}
+}
+
+// sessionProcessingPending 会话是否已结束、正在等待下一次刷新后的会话级处理
+func (h *Handlers) sessionProcessingPending(sessionID uuid.UUID) bool {
+	h.cacheMutex.RLock()
+	defer h.cacheMutex.RUnlock()
+	_, ok := h.endedPending[sessionID]
+	return ok
+}
+
+// sessionFlushPending 会话是否还有尚未落地的缓存数据，或已结束、正在等待会话级处理
+// 管理接口只在这种情况下触发刷新，否则直接读取已经落地的数据
+func (h *Handlers) sessionFlushPending(sessionID uuid.UUID) bool {
+	h.cacheMutex.RLock()
+	defer h.cacheMutex.RUnlock()
+	if _, ok := h.endedPending[sessionID]; ok {
+		return true
+	}
+	for _, records := range h.trackingCache {
+		for i := range records {
+			if records[i].SessionID == sessionID {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); err == nil {
-		// 文件存在，读取现有数据
-		existingBytes, err := os.ReadFile(filePath)
-		if err != nil {
-			return fmt.Errorf("无法读取现有文件: %w", err)
+// processEndedSessions 会话结束后重建文档坐标、计算概要指标，失败不影响原始数据
+// 文档坐标可以通过管理接口重新计算，概要指标在查询时补算
+func (h *Handlers) processEndedSessions(sessions map[uuid.UUID]struct{}) {
+	for sessionID := range sessions {
+		ctx, cancel := utils.WithComplexQueryTimeout(context.Background())
+		if _, err := h.services.Analysis.ProjectSessionDocument(ctx, sessionID); err != nil && !errors.Is(err, service.ErrNoTrackingData) {
+			fmt.Printf("警告: 会话%s的文档坐标重建失败: %v\n", sessionID, err)
 		}
+		if _, err := h.services.Analysis.ComputeSessionMetrics(ctx, sessionID); err != nil {
+			fmt.Printf("警告: 会话%s的概要指标计算失败: %v\n", sessionID, err)
+		}
+		cancel()
+	}
+}
 
-		// 解析现有数据
-		if err := json.Unmarshal(existingBytes, &existingData); err != nil {
-			return fmt.Errorf("无法解析现有文件: %w", err)
+// sealUserFiles 封存用户当天的追踪文件和派生数据文件（注视检测结果、文档坐标），之后的数据写入新的打开文件
+// 更早日期的文件由上传器在跨天后封存
+func (h *Handlers) sealUserFiles(users map[string]struct{}) {
+	today := time.Now().Format("2006-01-02")
+	for userID := range users {
+		paths := []string{
+			storage.UserFilePath(storage.TrackingDir(), today, userID),
+			storage.DerivedFilePath(storage.TrackingDir(), today, userID, storage.SchemaFixations),
+			storage.DerivedFilePath(storage.TrackingDir(), today, userID, storage.SchemaDocument),
 		}
+		for _, path := range paths {
+			if _, err := storage.Seal(path); err != nil {
+				fmt.Printf("警告: 封存用户%s的数据文件失败: %v\n", userID, err)
+			}
+		}
+	}
+}
 
-		// 合并记录
-		existingData.Records = append(existingData.Records, newBatchRecord.Records...)
-		existingData.FlushTime = newBatchRecord.FlushTime // 更新最后刷新时间
-	} else {
-		// 文件不存在，使用新数据
-		existingData = newBatchRecord
+// replayTrackingEntries 将预写日志中重放出的记录写入对应日期的追踪文件
+func (h *Handlers) replayTrackingEntries(entries []storage.WALEntry) error {
+	type fileKey struct {
+		date   string
+		userID string
 	}
 
-	// 序列化合并后的数据
-	jsonData, err := json.MarshalIndent(existingData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("无法序列化合并后的数据: %w", err)
+	// 按日期和用户分组，保持记录原有顺序
+	var order []fileKey
+	grouped := make(map[fileKey][]models.UserTrackingRecord)
+	for _, entry := range entries {
+		key := fileKey{date: entry.Date, userID: entry.UserID}
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], entry.Record)
 	}
 
-	// 写入文件
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("无法写入文件: %w", err)
+	for _, key := range order {
+		if err := h.writeTrackingRecords(key.date, key.userID, grouped[key]); err != nil {
+			return fmt.Errorf("恢复用户%s在%s的追踪数据失败: %w", key.userID, key.date, err)
+		}
 	}
 
 	return nil
 }
 
-// flushNewsCache 将新闻缓存数据写入文件
+// flushNewsCache 将新闻浏览记录交给配置的落地目标（默认 Postgres），点击记录写入文件
+// 浏览记录写入失败时放回缓存，下次刷新时重试
 func (h *Handlers) flushNewsCache() {
 	h.cacheMutex.Lock()
-	defer h.cacheMutex.Unlock()
+	h.pruneNewsImpressions(time.Now().Add(-newsImpressionTTL))
+	newsCache := h.newsCache
+	clickCache := h.clickCache
+	h.newsCache = make(map[string][]models.UserNewsRecord)
+	h.clickCache = make(map[string][]models.NewsClickRecord)
+	h.cacheMutex.Unlock()
 
-	if len(h.newsCache) == 0 {
+	if len(newsCache) == 0 && len(clickCache) == 0 {
 		return
 	}
 
-	// 创建今天的日期目录
 	today := time.Now().Format("2006-01-02")
-	dateDir := fmt.Sprintf("data/news/%s", today)
-	if err := os.MkdirAll(dateDir, 0755); err != nil {
-		fmt.Printf("警告: 无法创建新闻数据目录: %v\n", err)
-		return
-	}
+	ctx, cancel := utils.WithComplexQueryTimeout(context.Background())
+	defer cancel()
 
-	// 为每个用户批量写入数据
-	for userID, records := range h.newsCache {
+	// 为每个用户批量写入数据，每条浏览记录为一次列表请求
+	failed := make(map[string][]models.UserNewsRecord)
+	for userID, records := range newsCache {
 		if len(records) == 0 {
 			continue
 		}
 
-		// 创建批量记录结构
-		batchRecord := models.UserNewsBatchRecord{
-			FlushTime: time.Now(),
-			Records:   records,
-		}
-
-		// 文件名使用用户ID
-		fileName := fmt.Sprintf("%s.json", userID)
-		filePath := fmt.Sprintf("%s/%s", dateDir, fileName)
-
-		// 如果文件已存在，则追加到现有记录中
-		if err := h.appendToNewsFile(filePath, batchRecord); err != nil {
-			fmt.Printf("警告: 无法写入用户%s的新闻数据文件: %v\n", userID, err)
+		if err := h.services.NewsSink.WriteNews(ctx, today, userID, records); err != nil {
+			fmt.Printf("警告: 无法写入用户%s的新闻浏览记录（%s）: %v\n", userID, h.services.NewsSink.Name(), err)
+			failed[userID] = records
 			continue
 		}
 
 		fmt.Printf("成功写入用户%s的%d条新闻记录\n", userID, len(records))
 	}
 
-	// 清空缓存
-	h.newsCache = make(map[string][]models.UserNewsRecord)
-	h.lastFlush = time.Now()
-}
-
-// appendToNewsFile 将新的批量记录追加到新闻数据文件中
-func (h *Handlers) appendToNewsFile(filePath string, newBatchRecord models.UserNewsBatchRecord) error {
-	var existingData models.UserNewsBatchRecord
-
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); err == nil {
-		// 文件存在，读取现有数据
-		existingBytes, err := os.ReadFile(filePath)
-		if err != nil {
-			return fmt.Errorf("无法读取现有文件: %w", err)
+	// 点击是点击率的分子，写入失败同样放回缓存重试
+	failedClicks := make(map[string][]models.NewsClickRecord)
+	for userID, clicks := range clickCache {
+		if len(clicks) == 0 {
+			continue
 		}
 
-		// 解析现有数据
-		if err := json.Unmarshal(existingBytes, &existingData); err != nil {
-			return fmt.Errorf("无法解析现有文件: %w", err)
+		if err := h.services.NewsSink.WriteClicks(ctx, today, userID, clicks); err != nil {
+			fmt.Printf("警告: 无法写入用户%s的新闻点击记录（%s）: %v\n", userID, h.services.NewsSink.Name(), err)
+			failedClicks[userID] = clicks
+			continue
 		}
 
-		// 合并记录
-		existingData.Records = append(existingData.Records, newBatchRecord.Records...)
-		existingData.FlushTime = newBatchRecord.FlushTime // 更新最后刷新时间
-	} else {
-		// 文件不存在，使用新数据
-		existingData = newBatchRecord
+		fmt.Printf("成功写入用户%s的%d条新闻点击记录\n", userID, len(clicks))
 	}
 
-	// 序列化合并后的数据
-	jsonData, err := json.MarshalIndent(existingData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("无法序列化合并后的数据: %w", err)
-	}
-
-	// 写入文件
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("无法写入文件: %w", err)
-	}
-
-	return nil
-}
-
-// AddToNewsCache 将新闻数据添加到内存缓存
-func (h *Handlers) AddToNewsCache(userID string, newsGUIDs []string, strategy string, recommendUserID string) {
 	h.cacheMutex.Lock()
-	defer h.cacheMutex.Unlock()
-
-	record := models.UserNewsRecord{
-		StartTime: time.Now(),
-		NewsGUIDs: newsGUIDs,
-		Strategy:  strategy,
-		UserID:    recommendUserID,
+	for userID, records := range failed {
+		h.newsCache[userID] = append(records, h.newsCache[userID]...)
 	}
-
-	h.newsCache[userID] = append(h.newsCache[userID], record)
+	for userID, clicks := range failedClicks {
+		h.clickCache[userID] = append(clicks, h.clickCache[userID]...)
+	}
+	h.lastFlush = time.Now()
+	h.cacheMutex.Unlock()
 }
 
 // Stop 停止后台任务并刷新缓存
 func (h *Handlers) Stop() {
 	h.flushTicker.Stop()
-	h.FlushCaches() // 最后一次刷新
+	close(h.closing)  // 关闭所有 WebSocket 上传连接
+	h.gazeHub.Close() // 结束所有实时观看连接
+	h.FlushCaches()   // 最后一次刷新
+	if err := h.trackingWAL.Close(); err != nil {
+		fmt.Printf("警告: 关闭追踪数据预写日志失败: %v\n", err)
+	}
 }
 
 func (h *Handlers) FlushCaches() {
@@ -123,3 +123,49 @@ func JWTAuth() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// WebSocketJWTAuth WebSocket 握手使用的JWT认证中间件
+// 浏览器的 WebSocket API 不能设置请求头，没有 Authorization 头时从 access_token 查询参数读取令牌
+// 认证只在握手时进行一次，之后的数据帧不再解析令牌
+func WebSocketJWTAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.Query("access_token")
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			tokenParts := strings.Split(authHeader, " ")
+			if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+				c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+					models.ErrorCodeUnauthorized,
+					"无效的Authorization格式",
+					"格式应为: Bearer <token>",
+				))
+				c.Abort()
+				return
+			}
+			tokenString = tokenParts[1]
+		}
+
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+				models.ErrorCodeUnauthorized,
+				"缺少令牌",
+				"请提供 Authorization 头或 access_token 参数",
+			))
+			c.Abort()
+			return
+		}
+
+		userID, err := ValidateJWT(tokenString, os.Getenv("JWT_SECRET"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+				models.ErrorCodeUnauthorized,
+				"无效的token",
+				err.Error(),
+			))
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", userID.String())
+		c.Next()
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -83,3 +84,24 @@ func CORS() gin.HandlerFunc {
 		}
 	}
 }
+
+// WebSocketOriginAllowed WebSocket 握手的来源检查，与 CORS 使用同样的策略
+// 开发环境允许所有来源，生产环境只允许 ALLOWED_ORIGINS 中的来源（逗号分隔）
+func WebSocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true // 非浏览器客户端
+	}
+
+	if os.Getenv("CURRENT_ENV") == "dev" {
+		return true
+	}
+
+	for _, allowed := range strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"NewsEyeTracking/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RoleExperimenter 实验者角色，可以访问 /admin 下的分析和管理接口
+const RoleExperimenter = "experimenter"
+
+// ExperimenterClaims 实验者令牌的声明
+// 使用独立的 ADMIN_JWT_SECRET 签名，参与者的 JWT 无法通过验证
+type ExperimenterClaims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// MakeExperimenterJWT 为实验者签发令牌
+func MakeExperimenterJWT(name, tokenSecret string, expireIn time.Duration) (string, error) {
+	claims := ExperimenterClaims{
+		Role: RoleExperimenter,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "Eyetracking",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expireIn)),
+			Subject:   name,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(tokenSecret))
+}
+
+// ValidateExperimenterJWT 验证实验者令牌，返回实验者名称
+func ValidateExperimenterJWT(tokenString, tokenSecret string) (string, error) {
+	claims := &ExperimenterClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(tokenSecret), nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid {
+		return "", fmt.Errorf("invalid token")
+	}
+	if claims.Role != RoleExperimenter {
+		return "", fmt.Errorf("token does not carry the experimenter role")
+	}
+
+	return claims.Subject, nil
+}
+
+// ExperimenterAuth 实验者认证中间件
+func ExperimenterAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := os.Getenv("ADMIN_JWT_SECRET")
+		if secret == "" {
+			c.JSON(http.StatusForbidden, models.ErrorResponse(
+				models.ErrorCodeForbidden,
+				"管理接口未启用",
+				"服务器未配置 ADMIN_JWT_SECRET",
+			))
+			c.Abort()
+			return
+		}
+
+		token, ok := experimenterToken(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+				models.ErrorCodeUnauthorized,
+				"缺少实验者令牌",
+				"格式应为: Bearer <token>",
+			))
+			c.Abort()
+			return
+		}
+
+		name, err := ValidateExperimenterJWT(token, secret)
+		if err != nil {
+			c.JSON(http.StatusForbidden, models.ErrorResponse(
+				models.ErrorCodeForbidden,
+				"无效的实验者令牌",
+				err.Error(),
+			))
+			c.Abort()
+			return
+		}
+
+		c.Set("experimenter", name)
+		c.Next()
+	}
+}
+
+// experimenterToken 从 Authorization 头读取令牌
+// 浏览器的 EventSource 无法设置请求头，没有该请求头时允许使用 access_token 查询参数
+func experimenterToken(c *gin.Context) (string, bool) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		tokenParts := strings.Split(header, " ")
+		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+			return "", false
+		}
+		return tokenParts[1], true
+	}
+
+	if token := c.Query("access_token"); token != "" {
+		return token, true
+	}
+	return "", false
+}
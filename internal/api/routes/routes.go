@@ -58,6 +58,9 @@ func SetupRoutes(router *gin.Engine, services *service.Services) *handlers.Handl
 			}
 			// 用户登录是就应该有一个新的会话，我是否应该将第一个会话 id 与后面的内容联系起来呢
 		}
+		// WebSocket 上传通道，握手时认证一次，令牌可以通过 access_token 参数传递
+		v1.GET("/sessions/:session_id/ws", middleware.WebSocketJWTAuth(), h.TrackingWebSocket)
+
 		//还需要 session 管理
 		protected := v1.Group("")
 		// 测试研究的时候先禁用 jwt
@@ -82,6 +85,7 @@ func SetupRoutes(router *gin.Engine, services *service.Services) *handlers.Handl
 			protected.POST("/sessions/:session_id/data", h.ProcessSessionData)
 
 			protected.POST("/sessions/:session_id/end", h.EndReadingSession)
+			protected.GET("/sessions/:session_id/summary", h.GetSessionSummary)
 			//新闻相关
 			newsProtected := protected.Group("")
 			//newsProtected.Use(middleware.newsValid()) //这里实现的思路是把对应的 guid 区分开，检测天数，以免看太过时的新闻
@@ -96,6 +100,28 @@ func SetupRoutes(router *gin.Engine, services *service.Services) *handlers.Handl
 
 		}
 
+		// 实验者接口，使用独立的实验者令牌认证
+		admin := v1.Group("/admin")
+		admin.Use(middleware.ExperimenterAuth())
+		{
+			admin.POST("/sessions/:id/fixations", h.DetectSessionFixations)
+			admin.POST("/sessions/:id/document", h.ProjectSessionDocument)
+			admin.GET("/sessions/:id/aoi-metrics", h.GetSessionAOIMetrics)
+			admin.GET("/sessions/:id/stream", h.StreamSessionGaze)
+			admin.GET("/sessions/:id/completeness", h.GetSessionCompleteness)
+			admin.GET("/sessions/:id/export", h.ExportSessionBIDS)
+			admin.GET("/articles/:guid/heatmap", h.GetArticleHeatmap)
+			admin.GET("/articles/:guid/scanpath", h.GetArticleScanpath)
+			admin.GET("/uploads", h.ListUploadBatches)
+			admin.POST("/uploads/:id/retry", h.RetryUploadBatch)
+			admin.POST("/uploads/run", h.TriggerUpload)
+			admin.GET("/uploads/status", h.GetUploadStatus)
+			admin.POST("/retention/cleanup", h.CleanupRetention)
+			admin.POST("/exports/parquet", h.ExportParquet)
+			admin.GET("/exports/:id/:file", h.DownloadExportFile)
+			admin.GET("/reports/ctr", h.GetCTRReport)
+		}
+
 	}
 
 	return h
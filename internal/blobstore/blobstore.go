@@ -0,0 +1,113 @@
+package blobstore
+
+// 对象存储抽象
+// 上传器只依赖 BlobStore 接口，具体后端由 STORAGE_BACKEND 环境变量选择：
+//   - oss：阿里云 OSS（默认，兼容原有部署）
+//   - s3：S3 兼容存储（AWS S3、MinIO 等）
+//   - local：本地目录，开发和 CI 环境无需云端凭证即可跑通完整流程
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	BackendOSS   = "oss"
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// ObjectInfo 对象元信息
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string // 去掉引号的 ETag
+	CRC64        string // CRC-64/ECMA-182，后端不提供时为空
+	LastModified time.Time
+}
+
+// BlobStore 对象存储
+type BlobStore interface {
+	// Backend 后端名称，用于日志
+	Backend() string
+	// Put 把本地文件上传为指定的对象
+	Put(ctx context.Context, key, localPath string) (*ObjectInfo, error)
+	// Stat 查询对象元信息，对象不存在时返回 ErrNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+}
+
+// Backend 当前配置的存储后端
+func Backend() string {
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND")))
+	if backend == "" {
+		return BackendOSS
+	}
+	if backend == "minio" {
+		return BackendS3
+	}
+	return backend
+}
+
+// RequiredEnv 指定后端必须设置的环境变量
+func RequiredEnv(backend string) ([]string, error) {
+	switch backend {
+	case BackendOSS:
+		return []string{"ACCESS_ID", "ACCESS_KEY", "OSS_REGION", "OSS_BUCKET_NAME"}, nil
+	case BackendS3:
+		return []string{"S3_ENDPOINT", "S3_ACCESS_KEY", "S3_SECRET_KEY", "S3_BUCKET"}, nil
+	case BackendLocal:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("不支持的存储后端: %s（可选 oss、s3、local）", backend)
+	}
+}
+
+// FromEnv 按环境变量创建存储后端
+func FromEnv() (BlobStore, error) {
+	backend := Backend()
+	required, err := RequiredEnv(backend)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range required {
+		if os.Getenv(name) == "" {
+			return nil, fmt.Errorf("存储后端 %s 需要设置环境变量 %s", backend, name)
+		}
+	}
+
+	switch backend {
+	case BackendOSS:
+		return NewOSSStore(OSSConfig{
+			AccessKeyID:     os.Getenv("ACCESS_ID"),
+			AccessKeySecret: os.Getenv("ACCESS_KEY"),
+			Region:          os.Getenv("OSS_REGION"),
+			Endpoint:        os.Getenv("OSS_ENDPOINT"),
+			Bucket:          os.Getenv("OSS_BUCKET_NAME"),
+		}), nil
+	case BackendS3:
+		return NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			UseSSL:    os.Getenv("S3_USE_SSL") != "false",
+		})
+	default:
+		dir := os.Getenv("LOCAL_STORAGE_DIR")
+		if dir == "" {
+			dir = "data/archive"
+		}
+		return NewLocalStore(dir)
+	}
+}
+
+func trimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
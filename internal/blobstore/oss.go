@@ -0,0 +1,93 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
+	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss/credentials"
+)
+
+// OSSConfig 阿里云 OSS 配置
+type OSSConfig struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	Region          string
+	Endpoint        string // 可选，为空时按 Region 推导
+	Bucket          string
+}
+
+// OSSStore 阿里云 OSS 存储
+type OSSStore struct {
+	client *oss.Client
+	bucket string
+}
+
+// NewOSSStore 创建 OSS 存储
+func NewOSSStore(cfg OSSConfig) *OSSStore {
+	provider := credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.AccessKeySecret)
+	ossCfg := oss.LoadDefaultConfig().WithCredentialsProvider(provider).WithRegion(cfg.Region)
+	if cfg.Endpoint != "" {
+		ossCfg = ossCfg.WithEndpoint(cfg.Endpoint)
+	}
+
+	return &OSSStore{
+		client: oss.NewClient(ossCfg),
+		bucket: cfg.Bucket,
+	}
+}
+
+func (s *OSSStore) Backend() string {
+	return BackendOSS
+}
+
+func (s *OSSStore) Put(ctx context.Context, key, localPath string) (*ObjectInfo, error) {
+	src, err := openSource(ctx, localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	result, err := s.client.PutObject(ctx, &oss.PutObjectRequest{
+		Bucket:        oss.Ptr(s.bucket),
+		Key:           oss.Ptr(key),
+		ContentLength: oss.Ptr(src.size),
+		Body:          src,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("上传到 OSS 失败: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:   key,
+		ETag:  trimETag(oss.ToString(result.ETag)),
+		CRC64: oss.ToString(result.HashCRC64),
+	}, nil
+}
+
+func (s *OSSStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	result, err := s.client.HeadObject(ctx, &oss.HeadObjectRequest{
+		Bucket: oss.Ptr(s.bucket),
+		Key:    oss.Ptr(key),
+	})
+	if err != nil {
+		var serviceErr *oss.ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.HttpStatusCode() == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("查询 OSS 对象失败: %w", err)
+	}
+
+	info := &ObjectInfo{
+		Key:   key,
+		Size:  result.ContentLength,
+		ETag:  trimETag(oss.ToString(result.ETag)),
+		CRC64: oss.ToString(result.HashCRC64),
+	}
+	if result.LastModified != nil {
+		info.LastModified = *result.LastModified
+	}
+	return info, nil
+}
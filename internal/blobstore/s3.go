@@ -0,0 +1,83 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config S3 兼容存储配置
+type S3Config struct {
+	Endpoint  string // 不带协议的地址，如 minio.lab.local:9000 或 s3.amazonaws.com
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+}
+
+// S3Store S3 兼容存储（AWS S3、MinIO 等）
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Store 创建 S3 兼容存储
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 S3 客户端失败: %w", err)
+	}
+
+	return &S3Store{client: client, bucket: cfg.Bucket}, nil
+}
+
+func (s *S3Store) Backend() string {
+	return BackendS3
+}
+
+func (s *S3Store) Put(ctx context.Context, key, localPath string) (*ObjectInfo, error) {
+	src, err := openSource(ctx, localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	result, err := s.client.PutObject(ctx, s.bucket, key, src, src.size, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("上传到 S3 失败: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         result.Size,
+		ETag:         trimETag(result.ETag),
+		LastModified: result.LastModified,
+	}, nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	result, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("查询 S3 对象失败: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         result.Size,
+		ETag:         trimETag(result.ETag),
+		LastModified: result.LastModified,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"golang.org/x/time/rate"
+)
+
+// maxBurst 限速时单次读取的最大字节数
+const maxBurst = 256 << 10
+
+// Transfer 一次上传的限速器和进度回调，通过 context 传给 Put，两者都可以为空
+type Transfer struct {
+	Limiter  *rate.Limiter                  // 多个上传共享同一个限速器即为总带宽上限
+	Progress func(transferred, total int64) // 每读取一块调用一次
+}
+
+type transferKey struct{}
+
+// WithTransfer 在 context 中附加限速器和进度回调
+func WithTransfer(ctx context.Context, transfer Transfer) context.Context {
+	return context.WithValue(ctx, transferKey{}, transfer)
+}
+
+// NewLimiter 创建每秒 bytesPerSecond 字节的限速器，不大于 0 时返回 nil（不限速）
+func NewLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := bytesPerSecond
+	if burst > maxBurst {
+		burst = maxBurst
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst))
+}
+
+// source 待上传的本地文件，读取时按 context 中的 Transfer 限速并报告进度
+type source struct {
+	file     *os.File
+	ctx      context.Context
+	transfer Transfer
+	read     int64
+	size     int64
+}
+
+// openSource 打开待上传的本地文件，返回读取器和文件大小
+func openSource(ctx context.Context, localPath string) (*source, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	transfer, _ := ctx.Value(transferKey{}).(Transfer)
+	return &source{file: file, ctx: ctx, transfer: transfer, size: info.Size()}, nil
+}
+
+func (s *source) Read(p []byte) (int, error) {
+	if limiter := s.transfer.Limiter; limiter != nil && len(p) > limiter.Burst() {
+		p = p[:limiter.Burst()]
+	}
+
+	n, err := s.file.Read(p)
+	if n > 0 {
+		if s.transfer.Limiter != nil {
+			if waitErr := s.transfer.Limiter.WaitN(s.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+		s.read += int64(n)
+		if s.transfer.Progress != nil {
+			s.transfer.Progress(s.read, s.size)
+		}
+	}
+	return n, err
+}
+
+// Seek 供 SDK 重试时回到开头重新读取
+func (s *source) Seek(offset int64, whence int) (int64, error) {
+	pos, err := s.file.Seek(offset, whence)
+	if err == nil {
+		s.read = pos
+	}
+	return pos, err
+}
+
+func (s *source) Close() error {
+	return s.file.Close()
+}
+
+var _ io.ReadSeekCloser = (*source)(nil)
@@ -0,0 +1,526 @@
+package codec
+
+// 追踪数据的紧凑二进制编码（CBOR）
+// 眼动采样按列存储，坐标和时间做定点量化后差分编码，元素ID放入字符串表后用序号引用。
+// 解码结果与 JSON 请求完全相同（models.SessionDataRequest / models.EndSessionRequest），
+// 之后的验证和存储逻辑不区分编码。格式说明见 docs/tracking-cbor.md。
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"NewsEyeTracking/internal/models"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ContentTypeCBOR 紧凑编码请求使用的 Content-Type
+const ContentTypeCBOR = "application/cbor"
+
+const (
+	// DefaultCoordScale 坐标量化的默认精度（每像素的单位数），1 表示整数像素
+	DefaultCoordScale = 1
+	// DefaultTimeScale 采样时间量化的默认精度（每毫秒的单位数），1000 表示微秒
+	DefaultTimeScale = 1000
+	// MaxEvents 单批次每类事件的最大数量，防止恶意请求占用过多内存
+	MaxEvents = 100000
+)
+
+// Envelope 请求外层，/data 和 /end 共用
+type Envelope struct {
+	Time     int64        `cbor:"1,keyasint,omitempty"` // /data 为 timestamp，/end 为 end_time（Unix 毫秒）
+	Ping     bool         `cbor:"2,keyasint,omitempty"`
+	Seq      uint64       `cbor:"3,keyasint,omitempty"`
+	BatchID  string       `cbor:"4,keyasint,omitempty"`
+	Data     *CompactData `cbor:"5,keyasint,omitempty"`
+	FinalSeq uint64       `cbor:"6,keyasint,omitempty"`
+}
+
+// CompactData 对应 models.TrackingData
+type CompactData struct {
+	SamplingRate float32        `cbor:"1,keyasint,omitempty"`
+	ClientClock  *float64       `cbor:"2,keyasint,omitempty"`
+	Strings      []string       `cbor:"3,keyasint,omitempty"` // 元素ID字符串表
+	Eye          *EyeColumns    `cbor:"4,keyasint,omitempty"`
+	Clicks       *ClickColumns  `cbor:"5,keyasint,omitempty"`
+	Scrolls      *ScrollColumns `cbor:"6,keyasint,omitempty"`
+	Device       *CompactDevice `cbor:"7,keyasint,omitempty"` // 视口变化后的设备信息
+}
+
+// EyeColumns 眼动采样列
+// 可选列的长度为 0（整列缺省）或与采样数相同
+type EyeColumns struct {
+	Count     int           `cbor:"1,keyasint"`
+	Scale     uint32        `cbor:"2,keyasint,omitempty"`  // 坐标量化精度，缺省为 1
+	X         []int64       `cbor:"3,keyasint"`            // 差分后的量化坐标
+	Y         []int64       `cbor:"4,keyasint"`            //
+	ID        []uint32      `cbor:"5,keyasint,omitempty"`  // 字符串表序号 + 1，0 表示空ID
+	TimeScale uint32        `cbor:"6,keyasint,omitempty"`  // 时间量化精度，缺省为 1000
+	T         []int64       `cbor:"7,keyasint,omitempty"`  // 差分后的量化采样时间
+	Pupil     []float32     `cbor:"8,keyasint,omitempty"`  // NaN 表示缺省
+	Valid     []uint8       `cbor:"9,keyasint,omitempty"`  // 0 缺省，1 有效，2 无效
+	Left      []*CompactEye `cbor:"10,keyasint,omitempty"` // 元素可以为 null
+	Right     []*CompactEye `cbor:"11,keyasint,omitempty"` //
+}
+
+// CompactEye 对应 models.EyeSample，双眼数据不常见，不做差分
+type CompactEye struct {
+	X     float32  `cbor:"1,keyasint"`
+	Y     float32  `cbor:"2,keyasint"`
+	Pupil *float32 `cbor:"3,keyasint,omitempty"`
+	Valid *bool    `cbor:"4,keyasint,omitempty"`
+}
+
+// ClickColumns 点击事件列
+type ClickColumns struct {
+	T  []int64   `cbor:"1,keyasint"` // 差分后的 Unix 毫秒
+	ID []uint32  `cbor:"2,keyasint,omitempty"`
+	X  []float32 `cbor:"3,keyasint"`
+	Y  []float32 `cbor:"4,keyasint"`
+}
+
+// ScrollColumns 滚动事件列
+type ScrollColumns struct {
+	T      []int64   `cbor:"1,keyasint"` // 差分后的 Unix 毫秒
+	DeltaY []float32 `cbor:"2,keyasint"`
+}
+
+// CompactDevice 对应 models.DeviceInfo
+type CompactDevice struct {
+	ViewportWidth  int    `cbor:"1,keyasint"`
+	ViewportHeight int    `cbor:"2,keyasint"`
+	ScreenWidth    int    `cbor:"3,keyasint,omitempty"`
+	ScreenHeight   int    `cbor:"4,keyasint,omitempty"`
+	UserAgent      string `cbor:"5,keyasint,omitempty"`
+}
+
+// EncodeOptions 编码参数
+type EncodeOptions struct {
+	CoordScale uint32 // 0 使用 DefaultCoordScale
+	TimeScale  uint32 // 0 使用 DefaultTimeScale
+}
+
+var decMode = func() cbor.DecMode {
+	mode, err := cbor.DecOptions{
+		MaxArrayElements: MaxEvents,
+		MaxNestedLevels:  8,
+		DupMapKey:        cbor.DupMapKeyEnforcedAPF,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+// DecodeSessionData 解码 /data 请求
+func DecodeSessionData(payload []byte) (*models.SessionDataRequest, error) {
+	var env Envelope
+	if err := decMode.Unmarshal(payload, &env); err != nil {
+		return nil, fmt.Errorf("invalid CBOR payload: %w", err)
+	}
+
+	req := &models.SessionDataRequest{
+		Seq:     env.Seq,
+		BatchID: env.BatchID,
+	}
+	if env.Time != 0 {
+		req.Timestamp = time.UnixMilli(env.Time)
+	}
+	if env.Ping {
+		ping := true
+		req.Ping = &ping
+	}
+	if env.Data != nil {
+		data, err := env.Data.ToTrackingData()
+		if err != nil {
+			return nil, err
+		}
+		req.Data = data
+	}
+	return req, nil
+}
+
+// DecodeEndSession 解码 /end 请求
+func DecodeEndSession(payload []byte) (*models.EndSessionRequest, error) {
+	var env Envelope
+	if err := decMode.Unmarshal(payload, &env); err != nil {
+		return nil, fmt.Errorf("invalid CBOR payload: %w", err)
+	}
+	if env.Time == 0 {
+		return nil, fmt.Errorf("end_time is required")
+	}
+
+	req := &models.EndSessionRequest{
+		EndTime:  time.UnixMilli(env.Time),
+		Seq:      env.Seq,
+		BatchID:  env.BatchID,
+		FinalSeq: env.FinalSeq,
+	}
+	if env.Data != nil {
+		data, err := env.Data.ToTrackingData()
+		if err != nil {
+			return nil, err
+		}
+		req.Data = data
+	}
+	return req, nil
+}
+
+// EncodeSessionData 编码 /data 请求，供 Go 客户端和测试程序使用
+func EncodeSessionData(req *models.SessionDataRequest, opts EncodeOptions) ([]byte, error) {
+	env := Envelope{
+		Ping:    req.IsHeartbeat(),
+		Seq:     req.Seq,
+		BatchID: req.BatchID,
+	}
+	if !req.Timestamp.IsZero() {
+		env.Time = req.Timestamp.UnixMilli()
+	}
+	if req.Data != nil {
+		data, err := NewCompactData(req.Data, opts)
+		if err != nil {
+			return nil, err
+		}
+		env.Data = data
+	}
+	return cbor.Marshal(env)
+}
+
+// EncodeEndSession 编码 /end 请求
+func EncodeEndSession(req *models.EndSessionRequest, opts EncodeOptions) ([]byte, error) {
+	env := Envelope{
+		Time:     req.EndTime.UnixMilli(),
+		Seq:      req.Seq,
+		BatchID:  req.BatchID,
+		FinalSeq: req.FinalSeq,
+	}
+	if req.Data != nil {
+		data, err := NewCompactData(req.Data, opts)
+		if err != nil {
+			return nil, err
+		}
+		env.Data = data
+	}
+	return cbor.Marshal(env)
+}
+
+// NewCompactData 把追踪数据转换为列式紧凑格式
+func NewCompactData(td *models.TrackingData, opts EncodeOptions) (*CompactData, error) {
+	if opts.CoordScale == 0 {
+		opts.CoordScale = DefaultCoordScale
+	}
+	if opts.TimeScale == 0 {
+		opts.TimeScale = DefaultTimeScale
+	}
+
+	cd := &CompactData{
+		SamplingRate: td.SamplingRate,
+		ClientClock:  td.ClientClock,
+	}
+	table := newStringTable()
+
+	if n := len(td.EyeEvents); n > 0 {
+		eye := &EyeColumns{Count: n, X: make([]int64, n), Y: make([]int64, n)}
+		if opts.CoordScale != DefaultCoordScale {
+			eye.Scale = opts.CoordScale
+		}
+		if opts.TimeScale != DefaultTimeScale {
+			eye.TimeScale = opts.TimeScale
+		}
+
+		ids := make([]uint32, n)
+		hasID := false
+		var prevX, prevY, prevT int64
+		for i, event := range td.EyeEvents {
+			x := quantize(float64(event.X), opts.CoordScale)
+			y := quantize(float64(event.Y), opts.CoordScale)
+			eye.X[i], eye.Y[i] = x-prevX, y-prevY
+			prevX, prevY = x, y
+
+			if event.ID != "" {
+				ids[i] = table.index(event.ID)
+				hasID = true
+			}
+
+			if (event.Timestamp != nil) != (td.EyeEvents[0].Timestamp != nil) {
+				return nil, fmt.Errorf("eye_event[%d]: t must be set on all samples or on none", i)
+			}
+			if event.Timestamp != nil {
+				t := quantize(*event.Timestamp, opts.TimeScale)
+				eye.T = append(eye.T, t-prevT)
+				prevT = t
+			}
+
+			if event.Pupil != nil {
+				if eye.Pupil == nil {
+					eye.Pupil = filledNaN(n)
+				}
+				eye.Pupil[i] = *event.Pupil
+			}
+			if event.Valid != nil {
+				if eye.Valid == nil {
+					eye.Valid = make([]uint8, n)
+				}
+				eye.Valid[i] = encodeBool(*event.Valid)
+			}
+			if event.Left != nil {
+				if eye.Left == nil {
+					eye.Left = make([]*CompactEye, n)
+				}
+				eye.Left[i] = newCompactEye(event.Left)
+			}
+			if event.Right != nil {
+				if eye.Right == nil {
+					eye.Right = make([]*CompactEye, n)
+				}
+				eye.Right[i] = newCompactEye(event.Right)
+			}
+		}
+		if hasID {
+			eye.ID = ids
+		}
+		cd.Eye = eye
+	}
+
+	if n := len(td.ClickEvents); n > 0 {
+		clicks := &ClickColumns{T: make([]int64, n), X: make([]float32, n), Y: make([]float32, n)}
+		ids := make([]uint32, n)
+		hasID := false
+		var prevT int64
+		for i, event := range td.ClickEvents {
+			t := event.Timestamp.UnixMilli()
+			clicks.T[i] = t - prevT
+			prevT = t
+			clicks.X[i], clicks.Y[i] = event.X, event.Y
+			if event.ID != "" {
+				ids[i] = table.index(event.ID)
+				hasID = true
+			}
+		}
+		if hasID {
+			clicks.ID = ids
+		}
+		cd.Clicks = clicks
+	}
+
+	if n := len(td.ScrollEvents); n > 0 {
+		scrolls := &ScrollColumns{T: make([]int64, n), DeltaY: make([]float32, n)}
+		var prevT int64
+		for i, event := range td.ScrollEvents {
+			t := event.Timestamp.UnixMilli()
+			scrolls.T[i] = t - prevT
+			prevT = t
+			scrolls.DeltaY[i] = event.DeltaY
+		}
+		cd.Scrolls = scrolls
+	}
+
+	if device := td.DeviceInfo; device != nil {
+		cd.Device = &CompactDevice{
+			ViewportWidth:  device.ViewportWidth,
+			ViewportHeight: device.ViewportHeight,
+			ScreenWidth:    device.ScreenWidth,
+			ScreenHeight:   device.ScreenHeight,
+			UserAgent:      device.UserAgent,
+		}
+	}
+
+	cd.Strings = table.values
+	return cd, nil
+}
+
+// ToTrackingData 还原为追踪数据，同时检查各列长度是否一致
+func (cd *CompactData) ToTrackingData() (*models.TrackingData, error) {
+	td := &models.TrackingData{
+		SamplingRate: cd.SamplingRate,
+		ClientClock:  cd.ClientClock,
+	}
+	if device := cd.Device; device != nil {
+		td.DeviceInfo = &models.DeviceInfo{
+			UserAgent:      device.UserAgent,
+			ScreenWidth:    device.ScreenWidth,
+			ScreenHeight:   device.ScreenHeight,
+			ViewportWidth:  device.ViewportWidth,
+			ViewportHeight: device.ViewportHeight,
+		}
+	}
+
+	lookup := func(index uint32) (string, error) {
+		if index == 0 {
+			return "", nil
+		}
+		if int(index) > len(cd.Strings) {
+			return "", fmt.Errorf("string index %d out of range", index)
+		}
+		return cd.Strings[index-1], nil
+	}
+
+	if eye := cd.Eye; eye != nil {
+		n := eye.Count
+		if n < 0 || n > MaxEvents {
+			return nil, fmt.Errorf("eye column count %d out of range", n)
+		}
+		if len(eye.X) != n || len(eye.Y) != n {
+			return nil, fmt.Errorf("eye x/y columns must have %d entries", n)
+		}
+		for name, length := range map[string]int{
+			"id": len(eye.ID), "t": len(eye.T), "pupil": len(eye.Pupil),
+			"valid": len(eye.Valid), "left": len(eye.Left), "right": len(eye.Right),
+		} {
+			if length != 0 && length != n {
+				return nil, fmt.Errorf("eye %s column must have 0 or %d entries", name, n)
+			}
+		}
+
+		scale := float64(eye.Scale)
+		if scale == 0 {
+			scale = DefaultCoordScale
+		}
+		timeScale := float64(eye.TimeScale)
+		if timeScale == 0 {
+			timeScale = DefaultTimeScale
+		}
+
+		td.EyeEvents = make([]models.EyeEvent, n)
+		var x, y, t int64
+		for i := 0; i < n; i++ {
+			event := &td.EyeEvents[i]
+			x += eye.X[i]
+			y += eye.Y[i]
+			event.X = float32(float64(x) / scale)
+			event.Y = float32(float64(y) / scale)
+
+			if len(eye.ID) > 0 {
+				id, err := lookup(eye.ID[i])
+				if err != nil {
+					return nil, fmt.Errorf("eye id: %w", err)
+				}
+				event.ID = id
+			}
+			if len(eye.T) > 0 {
+				t += eye.T[i]
+				ms := float64(t) / timeScale
+				event.Timestamp = &ms
+			}
+			if len(eye.Pupil) > 0 && !math.IsNaN(float64(eye.Pupil[i])) {
+				pupil := eye.Pupil[i]
+				event.Pupil = &pupil
+			}
+			if len(eye.Valid) > 0 {
+				valid, err := decodeBool(eye.Valid[i])
+				if err != nil {
+					return nil, fmt.Errorf("eye valid: %w", err)
+				}
+				event.Valid = valid
+			}
+			if len(eye.Left) > 0 {
+				event.Left = eye.Left[i].toEyeSample()
+			}
+			if len(eye.Right) > 0 {
+				event.Right = eye.Right[i].toEyeSample()
+			}
+		}
+	}
+
+	if clicks := cd.Clicks; clicks != nil {
+		n := len(clicks.T)
+		if len(clicks.X) != n || len(clicks.Y) != n || (len(clicks.ID) != 0 && len(clicks.ID) != n) {
+			return nil, fmt.Errorf("click columns must have %d entries", n)
+		}
+		td.ClickEvents = make([]models.ClickEvent, n)
+		var t int64
+		for i := 0; i < n; i++ {
+			t += clicks.T[i]
+			event := &td.ClickEvents[i]
+			event.Timestamp = time.UnixMilli(t)
+			event.X, event.Y = clicks.X[i], clicks.Y[i]
+			if len(clicks.ID) > 0 {
+				id, err := lookup(clicks.ID[i])
+				if err != nil {
+					return nil, fmt.Errorf("click id: %w", err)
+				}
+				event.ID = id
+			}
+		}
+	}
+
+	if scrolls := cd.Scrolls; scrolls != nil {
+		n := len(scrolls.T)
+		if len(scrolls.DeltaY) != n {
+			return nil, fmt.Errorf("scroll columns must have %d entries", n)
+		}
+		td.ScrollEvents = make([]models.ScrollEvent, n)
+		var t int64
+		for i := 0; i < n; i++ {
+			t += scrolls.T[i]
+			td.ScrollEvents[i] = models.ScrollEvent{
+				Timestamp: time.UnixMilli(t),
+				DeltaY:    scrolls.DeltaY[i],
+			}
+		}
+	}
+
+	return td, nil
+}
+
+type stringTable struct {
+	values []string
+	lookup map[string]uint32
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{lookup: make(map[string]uint32)}
+}
+
+// index 返回字符串的序号 + 1
+func (st *stringTable) index(s string) uint32 {
+	if i, ok := st.lookup[s]; ok {
+		return i
+	}
+	st.values = append(st.values, s)
+	i := uint32(len(st.values))
+	st.lookup[s] = i
+	return i
+}
+
+func quantize(v float64, scale uint32) int64 {
+	return int64(math.Round(v * float64(scale)))
+}
+
+func filledNaN(n int) []float32 {
+	values := make([]float32, n)
+	for i := range values {
+		values[i] = float32(math.NaN())
+	}
+	return values
+}
+
+func encodeBool(v bool) uint8 {
+	if v {
+		return 1
+	}
+	return 2
+}
+
+func decodeBool(v uint8) (*bool, error) {
+	switch v {
+	case 0:
+		return nil, nil
+	case 1, 2:
+		b := v == 1
+		return &b, nil
+	default:
+		return nil, fmt.Errorf("invalid flag %d", v)
+	}
+}
+
+func newCompactEye(s *models.EyeSample) *CompactEye {
+	return &CompactEye{X: s.X, Y: s.Y, Pupil: s.Pupil, Valid: s.Valid}
+}
+
+func (ce *CompactEye) toEyeSample() *models.EyeSample {
+	if ce == nil {
+		return nil
+	}
+	return &models.EyeSample{X: ce.X, Y: ce.Y, Pupil: ce.Pupil, Valid: ce.Valid}
+}
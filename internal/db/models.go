@@ -6,11 +6,51 @@ package db
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sqlc-dev/pqtype"
 )
 
+type Click struct {
+	SessionID  uuid.UUID `json:"session_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	BatchTime  time.Time `json:"batch_time"`
+	BatchSeq   int64     `json:"batch_seq"`
+	BatchID    string    `json:"batch_id"`
+	EventIndex int32     `json:"event_index"`
+	ClickedAt  time.Time `json:"clicked_at"`
+	ElementID  string    `json:"element_id"`
+	X          float32   `json:"x"`
+	Y          float32   `json:"y"`
+}
+
+type EyeSample struct {
+	SessionID    uuid.UUID       `json:"session_id"`
+	UserID       uuid.UUID       `json:"user_id"`
+	BatchTime    time.Time       `json:"batch_time"`
+	BatchSeq     int64           `json:"batch_seq"`
+	BatchID      string          `json:"batch_id"`
+	SampleIndex  int32           `json:"sample_index"`
+	SampleTime   time.Time       `json:"sample_time"`
+	ClientT      sql.NullFloat64 `json:"client_t"`
+	ClientClock  sql.NullFloat64 `json:"client_clock"`
+	SamplingRate float32         `json:"sampling_rate"`
+	ElementID    string          `json:"element_id"`
+	X            float32         `json:"x"`
+	Y            float32         `json:"y"`
+	Pupil        sql.NullFloat64 `json:"pupil"`
+	Valid        sql.NullBool    `json:"valid"`
+	LeftX        sql.NullFloat64 `json:"left_x"`
+	LeftY        sql.NullFloat64 `json:"left_y"`
+	LeftPupil    sql.NullFloat64 `json:"left_pupil"`
+	LeftValid    sql.NullBool    `json:"left_valid"`
+	RightX       sql.NullFloat64 `json:"right_x"`
+	RightY       sql.NullFloat64 `json:"right_y"`
+	RightPupil   sql.NullFloat64 `json:"right_pupil"`
+	RightValid   sql.NullBool    `json:"right_valid"`
+}
+
 type Feed struct {
 	ID          int32          `json:"id"`
 	Title       string         `json:"title"`
@@ -58,6 +98,34 @@ type InviteCode struct {
 	Count              sql.NullInt32 `json:"count"`
 }
 
+// 新闻详情打开记录，与 news_impressions 一起计算点击率
+// 点击ID由服务端生成，重复写入时忽略，刷新失败重试不会重复
+type NewsClick struct {
+	ClickID         uuid.UUID     `json:"click_id"`
+	UserID          uuid.UUID     `json:"user_id"`
+	Guid            string        `json:"guid"`
+	ImpressionID    uuid.NullUUID `json:"impression_id"`
+	Position        sql.NullInt32 `json:"position"`
+	Strategy        string        `json:"strategy"`
+	ListSessionID   uuid.NullUUID `json:"list_session_id"`
+	DetailSessionID uuid.NullUUID `json:"detail_session_id"`
+	ClickedAt       time.Time     `json:"clicked_at"`
+}
+
+// 列表页新闻展示，每次返回新闻列表时每篇新闻一行
+// 推荐服务通过它排除或降权用户已经看过的新闻；重复写入同一个展示ID时忽略，刷新失败重试不会重复
+type NewsImpression struct {
+	ImpressionID    uuid.UUID       `json:"impression_id"`
+	UserID          uuid.UUID       `json:"user_id"`
+	ListSessionID   uuid.NullUUID   `json:"list_session_id"`
+	Guid            string          `json:"guid"`
+	Position        int32           `json:"position"`
+	Score           sql.NullFloat64 `json:"score"`
+	Strategy        string          `json:"strategy"`
+	RecommendUserID string          `json:"recommend_user_id"`
+	ShownAt         time.Time       `json:"shown_at"`
+}
+
 type ReadingSession struct {
 	ID         uuid.UUID             `json:"id"`
 	UserID     uuid.UUID             `json:"user_id"`
@@ -67,6 +135,38 @@ type ReadingSession struct {
 	DeviceInfo pqtype.NullRawMessage `json:"device_info"`
 }
 
+// 阅读会话的概要指标，会话结束、最后一批追踪数据落地后计算，重新计算时覆盖
+// 时间单位为毫秒；分词相关的指标只对文章页会话有意义，列表页会话为空
+type ReadingSessionMetric struct {
+	SessionID          uuid.UUID       `json:"session_id"`
+	UserID             uuid.UUID       `json:"user_id"`
+	ArticleID          string          `json:"article_id"`
+	DwellTimeMs        int64           `json:"dwell_time_ms"`
+	ActiveTimeMs       int64           `json:"active_time_ms"`
+	IdleTimeMs         int64           `json:"idle_time_ms"`
+	GazeSamples        int32           `json:"gaze_samples"`
+	ValidSamplePercent float64         `json:"valid_sample_percent"`
+	MaxScrollDepth     float64         `json:"max_scroll_depth"`
+	ClickCount         int32           `json:"click_count"`
+	TokenCount         sql.NullInt32   `json:"token_count"`
+	FixatedTokens      sql.NullInt32   `json:"fixated_tokens"`
+	FixatedTokenRatio  sql.NullFloat64 `json:"fixated_token_ratio"`
+	ReadingSpeed       sql.NullFloat64 `json:"reading_speed"`
+	Algorithm          string          `json:"algorithm"`
+	ComputedAt         time.Time       `json:"computed_at"`
+}
+
+type Scroll struct {
+	SessionID  uuid.UUID `json:"session_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	BatchTime  time.Time `json:"batch_time"`
+	BatchSeq   int64     `json:"batch_seq"`
+	BatchID    string    `json:"batch_id"`
+	EventIndex int32     `json:"event_index"`
+	ScrolledAt time.Time `json:"scrolled_at"`
+	DeltaY     float32   `json:"delta_y"`
+}
+
 type User struct {
 	ID                  uuid.UUID      `json:"id"`
 	Email               string         `json:"email"`
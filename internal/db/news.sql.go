@@ -8,7 +8,9 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/sqlc-dev/pqtype"
 )
@@ -245,6 +247,35 @@ func (q *Queries) GetNewArticles(ctx context.Context, arg GetNewArticlesParams)
 	return items, nil
 }
 
+const getNewsImpression = `-- name: GetNewsImpression :one
+SELECT impression_id, user_id, list_session_id, guid, position, score, strategy, recommend_user_id, shown_at FROM news_impressions
+WHERE impression_id = $1 AND user_id = $2 AND guid = $3
+`
+
+type GetNewsImpressionParams struct {
+	ImpressionID uuid.UUID `json:"impression_id"`
+	UserID       uuid.UUID `json:"user_id"`
+	Guid         string    `json:"guid"`
+}
+
+// 点击关联展示：按展示ID查询，展示必须属于该用户且是同一篇新闻
+func (q *Queries) GetNewsImpression(ctx context.Context, arg GetNewsImpressionParams) (NewsImpression, error) {
+	row := q.db.QueryRowContext(ctx, getNewsImpression, arg.ImpressionID, arg.UserID, arg.Guid)
+	var i NewsImpression
+	err := row.Scan(
+		&i.ImpressionID,
+		&i.UserID,
+		&i.ListSessionID,
+		&i.Guid,
+		&i.Position,
+		&i.Score,
+		&i.Strategy,
+		&i.RecommendUserID,
+		&i.ShownAt,
+	)
+	return i, err
+}
+
 const getRandomArticles = `-- name: GetRandomArticles :many
 SELECT id, feed_id, title, description, content, link, guid, author, keywords, published_at, created_at, like_count, share_count, save_count, comments, comment_count, content_hash FROM feed_items
 WHERE published_at > $1  -- 最近一段时间的新闻
@@ -376,3 +407,218 @@ func (q *Queries) UpsertFeedItem(ctx context.Context, arg UpsertFeedItemParams)
 	)
 	return i, err
 }
+
+const insertNewsImpressions = `-- name: InsertNewsImpressions :exec
+INSERT INTO news_impressions (
+    impression_id, user_id, list_session_id, guid, position, score, strategy, recommend_user_id, shown_at
+)
+SELECT t.impression_id, $1, $2, t.guid, t.position, NULLIF(t.score, 'NaN'::float8),
+       $3, $4, $5
+FROM unnest(
+    $6::uuid[], $7::text[], $8::int[], $9::float8[]
+) AS t(impression_id, guid, position, score)
+ON CONFLICT (impression_id) DO NOTHING
+`
+
+type InsertNewsImpressionsParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	ListSessionID   uuid.NullUUID `json:"list_session_id"`
+	Strategy        string        `json:"strategy"`
+	RecommendUserID string        `json:"recommend_user_id"`
+	ShownAt         time.Time     `json:"shown_at"`
+	ImpressionIds   []uuid.UUID   `json:"impression_ids"`
+	Guids           []string      `json:"guids"`
+	Positions       []int32       `json:"positions"`
+	Scores          []float64     `json:"scores"`
+}
+
+// 新闻展示：一次列表请求的全部展示用一条语句写入，展示ID已存在时忽略（刷新失败重试）
+// 没有推荐分数的展示在 scores 中传 NaN，写入 NULL
+func (q *Queries) InsertNewsImpressions(ctx context.Context, arg InsertNewsImpressionsParams) error {
+	_, err := q.db.ExecContext(ctx, insertNewsImpressions,
+		arg.UserID,
+		arg.ListSessionID,
+		arg.Strategy,
+		arg.RecommendUserID,
+		arg.ShownAt,
+		pq.Array(arg.ImpressionIds),
+		pq.Array(arg.Guids),
+		pq.Array(arg.Positions),
+		pq.Array(arg.Scores),
+	)
+	return err
+}
+
+const listShownNews = `-- name: ListShownNews :many
+SELECT guid, COUNT(*)::int AS show_count, MAX(shown_at)::timestamptz AS last_shown_at
+FROM news_impressions
+WHERE user_id = $1 AND shown_at >= $2::timestamptz
+GROUP BY guid
+ORDER BY last_shown_at DESC
+`
+
+type ListShownNewsParams struct {
+	UserID     uuid.UUID `json:"user_id"`
+	ShownAfter time.Time `json:"shown_after"`
+}
+
+type ListShownNewsRow struct {
+	Guid        string    `json:"guid"`
+	ShowCount   int32     `json:"show_count"`
+	LastShownAt time.Time `json:"last_shown_at"`
+}
+
+// 推荐：用户在某个时间之后看过的新闻及展示次数，用于排除或降权
+func (q *Queries) ListShownNews(ctx context.Context, arg ListShownNewsParams) ([]ListShownNewsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listShownNews, arg.UserID, arg.ShownAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListShownNewsRow
+	for rows.Next() {
+		var i ListShownNewsRow
+		if err := rows.Scan(
+			&i.Guid,
+			&i.ShowCount,
+			&i.LastShownAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNewsImpressionsBetween = `-- name: ListNewsImpressionsBetween :many
+SELECT impression_id, user_id, list_session_id, guid, position, score, strategy, recommend_user_id, shown_at FROM news_impressions
+WHERE shown_at >= $1::timestamptz AND shown_at < $2::timestamptz
+ORDER BY shown_at
+`
+
+type ListNewsImpressionsBetweenParams struct {
+	ShownFrom time.Time `json:"shown_from"`
+	ShownTo   time.Time `json:"shown_to"`
+}
+
+// 点击率报告：查询展示时间在时间范围内的新闻展示
+func (q *Queries) ListNewsImpressionsBetween(ctx context.Context, arg ListNewsImpressionsBetweenParams) ([]NewsImpression, error) {
+	rows, err := q.db.QueryContext(ctx, listNewsImpressionsBetween, arg.ShownFrom, arg.ShownTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NewsImpression
+	for rows.Next() {
+		var i NewsImpression
+		if err := rows.Scan(
+			&i.ImpressionID,
+			&i.UserID,
+			&i.ListSessionID,
+			&i.Guid,
+			&i.Position,
+			&i.Score,
+			&i.Strategy,
+			&i.RecommendUserID,
+			&i.ShownAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertNewsClick = `-- name: InsertNewsClick :exec
+INSERT INTO news_clicks (
+    click_id, user_id, guid, impression_id, position, strategy, list_session_id, detail_session_id, clicked_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+ON CONFLICT (click_id) DO NOTHING
+`
+
+type InsertNewsClickParams struct {
+	ClickID         uuid.UUID     `json:"click_id"`
+	UserID          uuid.UUID     `json:"user_id"`
+	Guid            string        `json:"guid"`
+	ImpressionID    uuid.NullUUID `json:"impression_id"`
+	Position        sql.NullInt32 `json:"position"`
+	Strategy        string        `json:"strategy"`
+	ListSessionID   uuid.NullUUID `json:"list_session_id"`
+	DetailSessionID uuid.NullUUID `json:"detail_session_id"`
+	ClickedAt       time.Time     `json:"clicked_at"`
+}
+
+// 新闻点击：点击ID已存在时忽略（刷新失败重试）
+func (q *Queries) InsertNewsClick(ctx context.Context, arg InsertNewsClickParams) error {
+	_, err := q.db.ExecContext(ctx, insertNewsClick,
+		arg.ClickID,
+		arg.UserID,
+		arg.Guid,
+		arg.ImpressionID,
+		arg.Position,
+		arg.Strategy,
+		arg.ListSessionID,
+		arg.DetailSessionID,
+		arg.ClickedAt,
+	)
+	return err
+}
+
+const listNewsClicksBetween = `-- name: ListNewsClicksBetween :many
+SELECT click_id, user_id, guid, impression_id, position, strategy, list_session_id, detail_session_id, clicked_at FROM news_clicks
+WHERE clicked_at >= $1::timestamptz AND clicked_at < $2::timestamptz
+ORDER BY clicked_at
+`
+
+type ListNewsClicksBetweenParams struct {
+	ClickedFrom time.Time `json:"clicked_from"`
+	ClickedTo   time.Time `json:"clicked_to"`
+}
+
+// 点击率报告：查询点击时间在时间范围内的新闻点击
+func (q *Queries) ListNewsClicksBetween(ctx context.Context, arg ListNewsClicksBetweenParams) ([]NewsClick, error) {
+	rows, err := q.db.QueryContext(ctx, listNewsClicksBetween, arg.ClickedFrom, arg.ClickedTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NewsClick
+	for rows.Next() {
+		var i NewsClick
+		if err := rows.Scan(
+			&i.ClickID,
+			&i.UserID,
+			&i.Guid,
+			&i.ImpressionID,
+			&i.Position,
+			&i.Strategy,
+			&i.ListSessionID,
+			&i.DetailSessionID,
+			&i.ClickedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -7,6 +7,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,6 +15,10 @@ import (
 type Querier interface {
 	// 批量清理所有过期会话（定时任务使用）
 	CleanupExpiredSessions(ctx context.Context, dollar_1 int32) error
+	// 数据保留：统计结束时间早于截止时间的阅读会话（试运行使用）
+	CountEndedSessionsBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	// 数据保留：统计早于截止时间结束的非活跃用户会话（试运行使用）
+	CountInactiveUserSessionsBefore(ctx context.Context, cutoff time.Time) (int64, error)
 	// 创建一个新的会话，相当于打开了一篇新的网页，开启了新的事件
 	// 初始的 endtime 应该是为空的, oss 存储路径应该暂定, 这里的 starttime 应该是有的
 	// 但是 endtime 应该是待定的，由前端发回来的信息， sessionid 作为打包区分，
@@ -24,6 +29,10 @@ type Querier interface {
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
 	// 创建新的用户会话
 	CreateUserSession(ctx context.Context, arg CreateUserSessionParams) error
+	// 数据保留：删除结束时间早于截止时间的阅读会话，未结束的会话不删除
+	DeleteEndedSessionsBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	// 数据保留：删除早于截止时间结束的非活跃用户会话
+	DeleteInactiveUserSessionsBefore(ctx context.Context, cutoff time.Time) (int64, error)
 	// 手动结束会话（用于登出等场景）
 	EndUserSession(ctx context.Context, arg EndUserSessionParams) error
 	// 验证邀请码并自动增加使用次数计数, 这里就算没注册也应该算使用了
@@ -47,10 +56,14 @@ type Querier interface {
 	GetMoreInfoMation(ctx context.Context, guid string) (GetMoreInfoMationRow, error)
 	// 获取新的文章， 这里需要根据推荐算法，所以这里筛选出来的接口还应该需要接到推荐算法上
 	GetNewArticles(ctx context.Context, arg GetNewArticlesParams) ([]FeedItem, error)
+	// 点击关联展示：按展示ID查询，展示必须属于该用户且是同一篇新闻
+	GetNewsImpression(ctx context.Context, arg GetNewsImpressionParams) (NewsImpression, error)
 	// 随机获取新闻文章
 	GetRandomArticles(ctx context.Context, arg GetRandomArticlesParams) ([]FeedItem, error)
 	// 会话查询
 	GetSessionByID(ctx context.Context, id uuid.UUID) (ReadingSession, error)
+	// 查询会话的概要指标
+	GetSessionMetrics(ctx context.Context, sessionID uuid.UUID) (ReadingSessionMetric, error)
 	GetUserABTestConfig(ctx context.Context, id uuid.UUID) (GetUserABTestConfigRow, error)
 	GetUserActiveSessions(ctx context.Context, userID uuid.UUID) ([]ReadingSession, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
@@ -61,7 +74,36 @@ type Querier interface {
 	GetUserSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]GetUserSessionsByUserIDRow, error)
 	// A/B 测试相关查询
 	GetUserWithInviteCode(ctx context.Context, id uuid.UUID) (GetUserWithInviteCodeRow, error)
+	// 新闻点击：点击ID已存在时忽略（刷新失败重试）
+	InsertNewsClick(ctx context.Context, arg InsertNewsClickParams) error
+	// 新闻展示：一次列表请求的全部展示用一条语句写入，展示ID已存在时忽略（刷新失败重试）
+	// 没有推荐分数的展示在 scores 中传 NaN，写入 NULL
+	InsertNewsImpressions(ctx context.Context, arg InsertNewsImpressionsParams) error
 	IsInviteCodeUsed(ctx context.Context, code string) (sql.NullBool, error)
+	// 热力图：查询某篇文章在时间范围内的阅读会话及用户所在的实验分组（用户ID即邀请码ID）
+	ListArticleSessions(ctx context.Context, arg ListArticleSessionsParams) ([]ListArticleSessionsRow, error)
+	// 点击率报告：查询点击时间在时间范围内的新闻点击
+	ListNewsClicksBetween(ctx context.Context, arg ListNewsClicksBetweenParams) ([]NewsClick, error)
+	// 点击率报告：查询展示时间在时间范围内的新闻展示
+	ListNewsImpressionsBetween(ctx context.Context, arg ListNewsImpressionsBetweenParams) ([]NewsImpression, error)
+	// 按批次和批次内顺序读取会话的全部点击事件
+	ListSessionClicks(ctx context.Context, sessionID uuid.UUID) ([]Click, error)
+	// 导出：按批次和批次内顺序读取会话在批次时间范围内的点击事件
+	ListSessionClicksBetween(ctx context.Context, arg ListSessionClicksBetweenParams) ([]Click, error)
+	// 按批次和批次内顺序读取会话的全部眼动采样，用于还原追踪记录
+	ListSessionEyeSamples(ctx context.Context, sessionID uuid.UUID) ([]EyeSample, error)
+	// 导出：按批次和批次内顺序读取会话在批次时间范围内的眼动采样
+	ListSessionEyeSamplesBetween(ctx context.Context, arg ListSessionEyeSamplesBetweenParams) ([]EyeSample, error)
+	// 按批次和批次内顺序读取会话的全部滚动事件
+	ListSessionScrolls(ctx context.Context, sessionID uuid.UUID) ([]Scroll, error)
+	// 导出：按批次和批次内顺序读取会话在批次时间范围内的滚动事件
+	ListSessionScrollsBetween(ctx context.Context, arg ListSessionScrollsBetweenParams) ([]Scroll, error)
+	// 推荐：用户在某个时间之后看过的新闻及展示次数，用于排除或降权
+	ListShownNews(ctx context.Context, arg ListShownNewsParams) ([]ListShownNewsRow, error)
+	// 数据导出：查询开始时间在时间范围内的阅读会话，用于补全会话的文章和设备信息
+	ListSessionsStartedBetween(ctx context.Context, arg ListSessionsStartedBetweenParams) ([]ReadingSession, error)
+	// 导出：批次时间在范围内、有追踪数据的阅读会话
+	ListTrackingSessionsBetween(ctx context.Context, arg ListTrackingSessionsBetweenParams) ([]ListTrackingSessionsBetweenRow, error)
 	// 只查询邀请码信息（不增加计数，用于纯查询场景）
 	MarkInviteCodeAsUsed(ctx context.Context, code string) error
 	// 更新心跳时间并自动检查过期（主要使用的心跳更新方法）
@@ -71,6 +113,8 @@ type Querier interface {
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
 	// 插入或更新文章（现在包含content_hash字段）
 	UpsertFeedItem(ctx context.Context, arg UpsertFeedItemParams) (FeedItem, error)
+	// 会话概要指标：会话结束后计算，重新计算时覆盖之前的结果
+	UpsertSessionMetrics(ctx context.Context, arg UpsertSessionMetricsParams) (ReadingSessionMetric, error)
 }
 
 var _ Querier = (*Queries)(nil)
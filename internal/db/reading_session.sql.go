@@ -8,6 +8,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sqlc-dev/pqtype"
@@ -119,3 +120,245 @@ func (q *Queries) UpdateSessionEndTime(ctx context.Context, arg UpdateSessionEnd
 	_, err := q.db.ExecContext(ctx, updateSessionEndTime, arg.ID, arg.EndTime)
 	return err
 }
+
+const countEndedSessionsBefore = `-- name: CountEndedSessionsBefore :one
+SELECT COUNT(*) FROM reading_sessions
+WHERE end_time IS NOT NULL AND end_time < $1::timestamptz
+`
+
+// 数据保留：统计结束时间早于截止时间的阅读会话（试运行使用）
+func (q *Queries) CountEndedSessionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countEndedSessionsBefore, cutoff)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteEndedSessionsBefore = `-- name: DeleteEndedSessionsBefore :execrows
+DELETE FROM reading_sessions
+WHERE end_time IS NOT NULL AND end_time < $1::timestamptz
+`
+
+// 数据保留：删除结束时间早于截止时间的阅读会话，未结束的会话不删除
+func (q *Queries) DeleteEndedSessionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteEndedSessionsBefore, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listSessionsStartedBetween = `-- name: ListSessionsStartedBetween :many
+SELECT id, user_id, article_id, start_time, end_time, device_info FROM reading_sessions
+WHERE start_time >= $1::timestamptz AND start_time < $2::timestamptz
+ORDER BY start_time
+`
+
+type ListSessionsStartedBetweenParams struct {
+	StartFrom time.Time `json:"start_from"`
+	StartTo   time.Time `json:"start_to"`
+}
+
+// 数据导出：查询开始时间在时间范围内的阅读会话，用于补全会话的文章和设备信息
+func (q *Queries) ListSessionsStartedBetween(ctx context.Context, arg ListSessionsStartedBetweenParams) ([]ReadingSession, error) {
+	rows, err := q.db.QueryContext(ctx, listSessionsStartedBetween, arg.StartFrom, arg.StartTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReadingSession
+	for rows.Next() {
+		var i ReadingSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ArticleID,
+			&i.StartTime,
+			&i.EndTime,
+			&i.DeviceInfo,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listArticleSessions = `-- name: ListArticleSessions :many
+SELECT rs.id, rs.user_id, rs.article_id, rs.start_time, rs.end_time, rs.device_info,
+       ic.has_recommend, ic.has_more_information
+FROM reading_sessions rs
+LEFT JOIN invite_codes ic ON ic.id = rs.user_id
+WHERE rs.article_id = $1
+  AND rs.start_time >= $2::timestamptz AND rs.start_time < $3::timestamptz
+ORDER BY rs.start_time DESC
+`
+
+type ListArticleSessionsParams struct {
+	ArticleID string    `json:"article_id"`
+	StartFrom time.Time `json:"start_from"`
+	StartTo   time.Time `json:"start_to"`
+}
+
+type ListArticleSessionsRow struct {
+	ID                 uuid.UUID             `json:"id"`
+	UserID             uuid.UUID             `json:"user_id"`
+	ArticleID          string                `json:"article_id"`
+	StartTime          sql.NullTime          `json:"start_time"`
+	EndTime            sql.NullTime          `json:"end_time"`
+	DeviceInfo         pqtype.NullRawMessage `json:"device_info"`
+	HasRecommend       sql.NullBool          `json:"has_recommend"`
+	HasMoreInformation sql.NullBool          `json:"has_more_information"`
+}
+
+// 热力图：查询某篇文章在时间范围内的阅读会话及用户所在的实验分组（用户ID即邀请码ID）
+func (q *Queries) ListArticleSessions(ctx context.Context, arg ListArticleSessionsParams) ([]ListArticleSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listArticleSessions, arg.ArticleID, arg.StartFrom, arg.StartTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListArticleSessionsRow
+	for rows.Next() {
+		var i ListArticleSessionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ArticleID,
+			&i.StartTime,
+			&i.EndTime,
+			&i.DeviceInfo,
+			&i.HasRecommend,
+			&i.HasMoreInformation,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertSessionMetrics = `-- name: UpsertSessionMetrics :one
+INSERT INTO reading_session_metrics (
+    session_id, user_id, article_id, dwell_time_ms, active_time_ms, idle_time_ms,
+    gaze_samples, valid_sample_percent, max_scroll_depth, click_count,
+    token_count, fixated_tokens, fixated_token_ratio, reading_speed, algorithm
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+)
+ON CONFLICT (session_id) DO UPDATE SET
+    dwell_time_ms = EXCLUDED.dwell_time_ms,
+    active_time_ms = EXCLUDED.active_time_ms,
+    idle_time_ms = EXCLUDED.idle_time_ms,
+    gaze_samples = EXCLUDED.gaze_samples,
+    valid_sample_percent = EXCLUDED.valid_sample_percent,
+    max_scroll_depth = EXCLUDED.max_scroll_depth,
+    click_count = EXCLUDED.click_count,
+    token_count = EXCLUDED.token_count,
+    fixated_tokens = EXCLUDED.fixated_tokens,
+    fixated_token_ratio = EXCLUDED.fixated_token_ratio,
+    reading_speed = EXCLUDED.reading_speed,
+    algorithm = EXCLUDED.algorithm,
+    computed_at = NOW()
+RETURNING session_id, user_id, article_id, dwell_time_ms, active_time_ms, idle_time_ms, gaze_samples, valid_sample_percent, max_scroll_depth, click_count, token_count, fixated_tokens, fixated_token_ratio, reading_speed, algorithm, computed_at
+`
+
+type UpsertSessionMetricsParams struct {
+	SessionID          uuid.UUID       `json:"session_id"`
+	UserID             uuid.UUID       `json:"user_id"`
+	ArticleID          string          `json:"article_id"`
+	DwellTimeMs        int64           `json:"dwell_time_ms"`
+	ActiveTimeMs       int64           `json:"active_time_ms"`
+	IdleTimeMs         int64           `json:"idle_time_ms"`
+	GazeSamples        int32           `json:"gaze_samples"`
+	ValidSamplePercent float64         `json:"valid_sample_percent"`
+	MaxScrollDepth     float64         `json:"max_scroll_depth"`
+	ClickCount         int32           `json:"click_count"`
+	TokenCount         sql.NullInt32   `json:"token_count"`
+	FixatedTokens      sql.NullInt32   `json:"fixated_tokens"`
+	FixatedTokenRatio  sql.NullFloat64 `json:"fixated_token_ratio"`
+	ReadingSpeed       sql.NullFloat64 `json:"reading_speed"`
+	Algorithm          string          `json:"algorithm"`
+}
+
+// 会话概要指标：会话结束后计算，重新计算时覆盖之前的结果
+func (q *Queries) UpsertSessionMetrics(ctx context.Context, arg UpsertSessionMetricsParams) (ReadingSessionMetric, error) {
+	row := q.db.QueryRowContext(ctx, upsertSessionMetrics,
+		arg.SessionID,
+		arg.UserID,
+		arg.ArticleID,
+		arg.DwellTimeMs,
+		arg.ActiveTimeMs,
+		arg.IdleTimeMs,
+		arg.GazeSamples,
+		arg.ValidSamplePercent,
+		arg.MaxScrollDepth,
+		arg.ClickCount,
+		arg.TokenCount,
+		arg.FixatedTokens,
+		arg.FixatedTokenRatio,
+		arg.ReadingSpeed,
+		arg.Algorithm,
+	)
+	var i ReadingSessionMetric
+	err := row.Scan(
+		&i.SessionID,
+		&i.UserID,
+		&i.ArticleID,
+		&i.DwellTimeMs,
+		&i.ActiveTimeMs,
+		&i.IdleTimeMs,
+		&i.GazeSamples,
+		&i.ValidSamplePercent,
+		&i.MaxScrollDepth,
+		&i.ClickCount,
+		&i.TokenCount,
+		&i.FixatedTokens,
+		&i.FixatedTokenRatio,
+		&i.ReadingSpeed,
+		&i.Algorithm,
+		&i.ComputedAt,
+	)
+	return i, err
+}
+
+const getSessionMetrics = `-- name: GetSessionMetrics :one
+SELECT session_id, user_id, article_id, dwell_time_ms, active_time_ms, idle_time_ms, gaze_samples, valid_sample_percent, max_scroll_depth, click_count, token_count, fixated_tokens, fixated_token_ratio, reading_speed, algorithm, computed_at FROM reading_session_metrics WHERE session_id = $1
+`
+
+// 查询会话的概要指标
+func (q *Queries) GetSessionMetrics(ctx context.Context, sessionID uuid.UUID) (ReadingSessionMetric, error) {
+	row := q.db.QueryRowContext(ctx, getSessionMetrics, sessionID)
+	var i ReadingSessionMetric
+	err := row.Scan(
+		&i.SessionID,
+		&i.UserID,
+		&i.ArticleID,
+		&i.DwellTimeMs,
+		&i.ActiveTimeMs,
+		&i.IdleTimeMs,
+		&i.GazeSamples,
+		&i.ValidSamplePercent,
+		&i.MaxScrollDepth,
+		&i.ClickCount,
+		&i.TokenCount,
+		&i.FixatedTokens,
+		&i.FixatedTokenRatio,
+		&i.ReadingSpeed,
+		&i.Algorithm,
+		&i.ComputedAt,
+	)
+	return i, err
+}
@@ -267,3 +267,30 @@ func (q *Queries) UpdateHeartbeatWithExpireCheck(ctx context.Context, arg Update
 	_, err := q.db.ExecContext(ctx, updateHeartbeatWithExpireCheck, arg.Column1, arg.Column2, arg.ID)
 	return err
 }
+
+const countInactiveUserSessionsBefore = `-- name: CountInactiveUserSessionsBefore :one
+SELECT COUNT(*) FROM user_sessions
+WHERE is_active = FALSE AND COALESCE(end_time, last_heartbeat) < $1::timestamptz
+`
+
+// 数据保留：统计早于截止时间结束的非活跃用户会话（试运行使用）
+func (q *Queries) CountInactiveUserSessionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countInactiveUserSessionsBefore, cutoff)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteInactiveUserSessionsBefore = `-- name: DeleteInactiveUserSessionsBefore :execrows
+DELETE FROM user_sessions
+WHERE is_active = FALSE AND COALESCE(end_time, last_heartbeat) < $1::timestamptz
+`
+
+// 数据保留：删除早于截止时间结束的非活跃用户会话
+func (q *Queries) DeleteInactiveUserSessionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteInactiveUserSessionsBefore, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
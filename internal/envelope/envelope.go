@@ -0,0 +1,295 @@
+package envelope
+
+// 归档文件的信封加密
+// 每个归档生成一个随机的 256 位数据密钥，用 AES-256-GCM 分块加密内容；数据密钥由主密钥包装后
+// 写在文件头里，解密时只需要主密钥。主密钥目前来自环境变量，KeyWrapper 接口可以替换为 KMS。
+//
+// 文件格式：
+//
+//	"NEAE" | 版本(1字节) | 文件头长度(uint32 大端) | 文件头 JSON | 块 | 块 | ...
+//	块 = 密文长度(uint32 大端) | AES-GCM 密文（含 16 字节认证标签）
+//
+// 第 i 块的 nonce 为 随机前缀(7字节) | i(uint32 大端) | 是否最后一块(1字节)，附加数据为整个文件头，
+// 因此块被删除、重排、截断或文件头被篡改都会导致解密失败。
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// Algorithm 内容加密算法
+	Algorithm = "AES-256-GCM"
+	// FileExt 加密归档的扩展名后缀
+	FileExt = ".enc"
+
+	formatVersion    = 1
+	defaultChunkSize = 64 << 10
+	maxChunkSize     = 16 << 20
+	maxHeaderSize    = 64 << 10
+	noncePrefixSize  = 7
+)
+
+var magic = []byte("NEAE")
+
+// ErrCorrupted 文件被截断或篡改
+var ErrCorrupted = errors.New("加密归档已损坏或被篡改")
+
+// Header 加密归档的文件头
+type Header struct {
+	Version     int    `json:"version"`
+	Algorithm   string `json:"algorithm"`
+	KeyID       string `json:"key_id"`
+	WrappedKey  []byte `json:"wrapped_key"` // 被主密钥包装的数据密钥
+	ChunkSize   int    `json:"chunk_size"`
+	NoncePrefix []byte `json:"nonce_prefix"`
+}
+
+// Encrypt 加密 r 的全部内容写入 w
+func Encrypt(w io.Writer, r io.Reader, kw KeyWrapper) (*Header, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+	wrapped, err := kw.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("包装数据密钥失败: %w", err)
+	}
+
+	header := &Header{
+		Version:     formatVersion,
+		Algorithm:   Algorithm,
+		KeyID:       kw.KeyID(),
+		WrappedKey:  wrapped,
+		ChunkSize:   defaultChunkSize,
+		NoncePrefix: make([]byte, noncePrefixSize),
+	}
+	if _, err := rand.Read(header.NoncePrefix); err != nil {
+		return nil, fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(magic)
+	bw.WriteByte(formatVersion)
+	binary.Write(bw, binary.BigEndian, uint32(len(headerData)))
+	bw.Write(headerData)
+
+	// 预读一块，判断当前块是否为最后一块
+	current := make([]byte, header.ChunkSize)
+	next := make([]byte, header.ChunkSize)
+	n, err := io.ReadFull(r, current)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	var sealed []byte
+	for index := uint32(0); ; index++ {
+		m, err := io.ReadFull(r, next)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		last := m == 0
+		if index == math.MaxUint32 && !last {
+			return nil, fmt.Errorf("文件过大")
+		}
+
+		sealed = aead.Seal(sealed[:0], chunkNonce(header.NoncePrefix, index, last), current[:n], headerData)
+		binary.Write(bw, binary.BigEndian, uint32(len(sealed)))
+		if _, err := bw.Write(sealed); err != nil {
+			return nil, err
+		}
+		if last {
+			break
+		}
+		current, next, n = next, current, m
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// Decrypt 解密 r 写入 w，只有完整读到最后一块才返回成功
+// 写入 w 的内容在返回错误时可能不完整，调用方应丢弃
+func Decrypt(w io.Writer, r io.Reader, kw KeyWrapper) (*Header, error) {
+	br := bufio.NewReader(r)
+	header, headerData, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := kw.UnwrapKey(header.KeyID, header.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed, plain []byte
+	for index := uint32(0); ; index++ {
+		var length uint32
+		if err := binary.Read(br, binary.BigEndian, &length); err != nil {
+			return nil, ErrCorrupted // 没有读到最后一块
+		}
+		if length > uint32(header.ChunkSize+aead.Overhead()) {
+			return nil, ErrCorrupted
+		}
+		if cap(sealed) < int(length) {
+			sealed = make([]byte, length)
+		}
+		sealed = sealed[:length]
+		if _, err := io.ReadFull(br, sealed); err != nil {
+			return nil, ErrCorrupted
+		}
+
+		// 先按非最后一块解密，失败再按最后一块解密
+		last := false
+		plain, err = aead.Open(plain[:0], chunkNonce(header.NoncePrefix, index, false), sealed, headerData)
+		if err != nil {
+			last = true
+			plain, err = aead.Open(plain[:0], chunkNonce(header.NoncePrefix, index, true), sealed, headerData)
+			if err != nil {
+				return nil, ErrCorrupted
+			}
+		}
+		if _, err := w.Write(plain); err != nil {
+			return nil, err
+		}
+		if last {
+			break
+		}
+	}
+
+	// 最后一块之后不应再有数据
+	if _, err := br.ReadByte(); err != io.EOF {
+		return nil, ErrCorrupted
+	}
+	return header, nil
+}
+
+// ReadHeader 读取加密归档的文件头，不需要密钥
+func ReadHeader(r io.Reader) (*Header, error) {
+	header, _, err := readHeader(bufio.NewReader(r))
+	return header, err
+}
+
+func readHeader(br *bufio.Reader) (*Header, []byte, error) {
+	prefix := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, prefix); err != nil || !bytes.Equal(prefix[:len(magic)], magic) {
+		return nil, nil, fmt.Errorf("不是加密归档文件")
+	}
+	if prefix[len(magic)] != formatVersion {
+		return nil, nil, fmt.Errorf("不支持的加密归档版本: %d", prefix[len(magic)])
+	}
+
+	var length uint32
+	if err := binary.Read(br, binary.BigEndian, &length); err != nil || length > maxHeaderSize {
+		return nil, nil, ErrCorrupted
+	}
+	headerData := make([]byte, length)
+	if _, err := io.ReadFull(br, headerData); err != nil {
+		return nil, nil, ErrCorrupted
+	}
+
+	var header Header
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return nil, nil, ErrCorrupted
+	}
+	if header.Algorithm != Algorithm || len(header.NoncePrefix) != noncePrefixSize ||
+		header.ChunkSize <= 0 || header.ChunkSize > maxChunkSize {
+		return nil, nil, fmt.Errorf("不支持的加密参数: %s", header.Algorithm)
+	}
+	return &header, headerData, nil
+}
+
+// EncryptFile 加密文件，先写临时文件再重命名
+func EncryptFile(src, dst string, kw KeyWrapper) (*Header, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var header *Header
+	err = writeFileAtomic(dst, func(out io.Writer) error {
+		header, err = Encrypt(out, in, kw)
+		return err
+	})
+	return header, err
+}
+
+// DecryptFile 解密文件，校验失败时不会留下不完整的输出文件
+func DecryptFile(src, dst string, kw KeyWrapper) (*Header, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var header *Header
+	err = writeFileAtomic(dst, func(out io.Writer) error {
+		header, err = Decrypt(out, in, kw)
+		return err
+	})
+	return header, err
+}
+
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
@@ -0,0 +1,114 @@
+package envelope
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNoMasterKey 没有配置主密钥
+var ErrNoMasterKey = errors.New("未配置归档主密钥 ARCHIVE_MASTER_KEY")
+
+// KeyWrapper 用主密钥包装/解包数据密钥，可以替换为 KMS 实现
+type KeyWrapper interface {
+	// KeyID 当前用于包装的主密钥标识，写入加密归档的文件头
+	KeyID() string
+	// WrapKey 包装数据密钥
+	WrapKey(dataKey []byte) ([]byte, error)
+	// UnwrapKey 用 keyID 对应的主密钥解包数据密钥
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyWrapper 使用本地保存的 256 位主密钥（AES-256-GCM 包装）
+type LocalKeyWrapper struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// NewLocalKeyWrapper 创建本地主密钥包装器
+func NewLocalKeyWrapper(keyID string, masterKey []byte) (*LocalKeyWrapper, error) {
+	if len(masterKey) != 32 {
+		return nil, fmt.Errorf("主密钥长度必须为 32 字节，实际为 %d", len(masterKey))
+	}
+	if keyID == "" {
+		return nil, fmt.Errorf("主密钥标识不能为空")
+	}
+	aead, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKeyWrapper{keyID: keyID, aead: aead}, nil
+}
+
+func (k *LocalKeyWrapper) KeyID() string {
+	return k.keyID
+}
+
+// WrapKey 返回 nonce | 密文，附加数据为主密钥标识
+func (k *LocalKeyWrapper) WrapKey(dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, dataKey, []byte(k.keyID)), nil
+}
+
+func (k *LocalKeyWrapper) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != k.keyID {
+		return nil, fmt.Errorf("归档使用主密钥 %s 加密，当前配置的是 %s", keyID, k.keyID)
+	}
+	if len(wrapped) < k.aead.NonceSize() {
+		return nil, ErrCorrupted
+	}
+	nonce, sealed := wrapped[:k.aead.NonceSize()], wrapped[k.aead.NonceSize():]
+	dataKey, err := k.aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("解包数据密钥失败，主密钥不正确: %w", err)
+	}
+	return dataKey, nil
+}
+
+// ParseMasterKey 解析 base64（标准或 URL 编码）或 64 位十六进制表示的主密钥
+func ParseMasterKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 64 {
+		if key, err := hex.DecodeString(value); err == nil {
+			return key, nil
+		}
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if key, err := encoding.DecodeString(value); err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("主密钥格式不正确，应为 32 字节的 base64 或十六进制")
+}
+
+// Required 是否要求归档必须加密（ARCHIVE_ENCRYPTION=required）
+func Required() bool {
+	return strings.EqualFold(os.Getenv("ARCHIVE_ENCRYPTION"), "required")
+}
+
+// FromEnv 按环境变量创建主密钥包装器
+// ARCHIVE_MASTER_KEY 为主密钥，ARCHIVE_KEY_ID 为标识（默认 local-1）；没有配置主密钥时返回 ErrNoMasterKey
+func FromEnv() (KeyWrapper, error) {
+	value := os.Getenv("ARCHIVE_MASTER_KEY")
+	if value == "" {
+		return nil, ErrNoMasterKey
+	}
+	key, err := ParseMasterKey(value)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := os.Getenv("ARCHIVE_KEY_ID")
+	if keyID == "" {
+		keyID = "local-1"
+	}
+	return NewLocalKeyWrapper(keyID, key)
+}
@@ -0,0 +1,375 @@
+package export
+
+// 按 BIDS 眼动数据规范（BIDS 1.10 eye-tracking）导出单个阅读会话
+// 目录结构（打包为 zip）:
+//
+//	dataset_description.json
+//	sub-<用户>/ses-<会话>/beh/sub-<用户>_ses-<会话>_task-<任务>_recording-eye1_physio.tsv.gz   采样，无表头，列见 JSON
+//	sub-<用户>/ses-<会话>/beh/sub-<用户>_ses-<会话>_task-<任务>_recording-eye1_physio.json     采样率、屏幕、设备、文章、实验分组
+//	sub-<用户>/ses-<会话>/beh/sub-<用户>_ses-<会话>_task-<任务>_events.tsv                     点击和滚动事件
+//	sub-<用户>/ses-<会话>/beh/sub-<用户>_ses-<会话>_task-<任务>_events.json
+//
+// BIDS 标签只允许字母和数字，用户和会话标签取 UUID 去掉连字符；时间零点为会话开始时间。
+import (
+	"NewsEyeTracking/internal/analysis"
+	"NewsEyeTracking/internal/models"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	bidsVersion = "1.10.0"
+	// BIDS 任务标签：文章页阅读、列表页浏览
+	taskReading  = "reading"
+	taskNewsList = "newslist"
+	na           = "n/a"
+)
+
+// physioColumns 采样文件的列，顺序与 physioRow 一致
+var physioColumns = []string{
+	"timestamp", "x_coordinate", "y_coordinate", "pupil_size", "valid", "element_id",
+	"left_x_coordinate", "left_y_coordinate", "left_pupil_size",
+	"right_x_coordinate", "right_y_coordinate", "right_pupil_size",
+}
+
+// BIDSSession 一个阅读会话及其追踪记录
+type BIDSSession struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	ArticleID  string
+	StartTime  time.Time
+	EndTime    time.Time       // 未结束时为零值
+	DeviceInfo json.RawMessage // reading_sessions.device_info，原样写入 JSON
+	Arm        *models.ExperimentConfig
+	Records    []models.UserTrackingRecord
+}
+
+// BIDSFileName 会话导出包的文件名
+func BIDSFileName(sessionID uuid.UUID) string {
+	return fmt.Sprintf("session_%s_bids.zip", sessionID)
+}
+
+// WriteBIDS 把会话导出为 BIDS 目录结构的 zip
+func WriteBIDS(w io.Writer, session *BIDSSession) error {
+	origin := session.StartTime
+	if origin.IsZero() && len(session.Records) > 0 {
+		origin = session.Records[0].StartTime
+	}
+
+	sub, ses := bidsLabel(session.UserID), bidsLabel(session.ID)
+	task := taskReading
+	if models.IsListPageArticleID(session.ArticleID) {
+		task = taskNewsList
+	}
+	dir := path.Join("sub-"+sub, "ses-"+ses, "beh")
+	prefix := fmt.Sprintf("sub-%s_ses-%s_task-%s", sub, ses, task)
+
+	samples := sessionPhysio(session)
+	events := sessionEvents(session, origin)
+
+	archive := zip.NewWriter(w)
+	if err := writeJSON(archive, "dataset_description.json", map[string]interface{}{
+		"Name":        "NewsEyeTracking reading session " + session.ID.String(),
+		"BIDSVersion": bidsVersion,
+		"DatasetType": "raw",
+		"GeneratedBy": []map[string]string{{"Name": "NewsEyeTracking"}},
+	}); err != nil {
+		return err
+	}
+	if err := writePhysio(archive, path.Join(dir, prefix+"_recording-eye1_physio.tsv.gz"), samples); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, path.Join(dir, prefix+"_recording-eye1_physio.json"), physioSidecar(session, task, origin, samples)); err != nil {
+		return err
+	}
+	if err := writeEvents(archive, path.Join(dir, prefix+"_events.tsv"), events); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, path.Join(dir, prefix+"_events.json"), eventsSidecar(task)); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func bidsLabel(id uuid.UUID) string {
+	return strings.ReplaceAll(id.String(), "-", "")
+}
+
+// physioRow 一个采样
+type physioRow struct {
+	t     float64 // Unix 毫秒
+	event models.EyeEvent
+	rate  float32
+}
+
+// sessionPhysio 展开会话的全部采样并按时间排序，时间换算与注视检测一致
+func sessionPhysio(session *BIDSSession) []physioRow {
+	var rows []physioRow
+	for _, record := range session.Records {
+		if record.SessionID != session.ID {
+			continue
+		}
+		times := analysis.BatchSampleTimes(record)
+		for i, event := range record.Data.EyeEvents {
+			rows = append(rows, physioRow{t: times[i], event: event, rate: record.Data.SamplingRate})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].t < rows[j].t })
+	return rows
+}
+
+func writePhysio(archive *zip.Writer, name string, rows []physioRow) error {
+	// 已经是 gzip 压缩，zip 中不再压缩
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("创建 %s 失败: %w", name, err)
+	}
+	gz := gzip.NewWriter(file)
+	buf := bufio.NewWriter(gz)
+
+	for _, row := range rows {
+		e := row.event
+		fields := []string{
+			formatFloat(row.t, 3), formatFloat32(e.X), formatFloat32(e.Y),
+			optionalFloat(e.Pupil), boolField(e.IsValid()), textField(e.ID),
+		}
+		fields = append(fields, eyeFields(e.Left)...)
+		fields = append(fields, eyeFields(e.Right)...)
+		buf.WriteString(strings.Join(fields, "\t"))
+		buf.WriteByte('\n')
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+func eyeFields(sample *models.EyeSample) []string {
+	if sample == nil || (sample.Valid != nil && !*sample.Valid) {
+		return []string{na, na, na}
+	}
+	return []string{formatFloat32(sample.X), formatFloat32(sample.Y), optionalFloat(sample.Pupil)}
+}
+
+// physioSidecar 采样文件的 JSON 说明，BIDS 规定的字段之外附加会话、设备和实验分组信息
+func physioSidecar(session *BIDSSession, task string, origin time.Time, rows []physioRow) map[string]interface{} {
+	sidecar := map[string]interface{}{
+		"TaskName":               task,
+		"PhysioType":             "eyetrack",
+		"RecordedEye":            "cyclopean",
+		"SampleCoordinateUnits":  "pixel",
+		"SampleCoordinateSystem": "gaze-on-screen",
+		"EnvironmentCoordinates": "top-left",
+		"Columns":                physioColumns,
+		"StartTime":              0.0,
+		"SamplingFrequency":      na,
+
+		"timestamp":          map[string]string{"Description": "采样的绝对时间（Unix 时间）", "Units": "ms"},
+		"x_coordinate":       map[string]string{"Description": "注视点横坐标（双眼平均或主眼），相对于浏览器视口左上角", "Units": "pixel"},
+		"y_coordinate":       map[string]string{"Description": "注视点纵坐标", "Units": "pixel"},
+		"pupil_size":         map[string]string{"Description": "瞳孔直径", "Units": "mm"},
+		"valid":              map[string]string{"Description": "采样是否有效（1 有效，0 无效，眼动仪未提供时视为有效）"},
+		"element_id":         map[string]string{"Description": "注视点所在的页面元素（分词或组件）ID"},
+		"left_x_coordinate":  map[string]string{"Description": "左眼注视点横坐标，眼动仪未提供或左眼无效时为 n/a", "Units": "pixel"},
+		"left_y_coordinate":  map[string]string{"Description": "左眼注视点纵坐标", "Units": "pixel"},
+		"left_pupil_size":    map[string]string{"Description": "左眼瞳孔直径", "Units": "mm"},
+		"right_x_coordinate": map[string]string{"Description": "右眼注视点横坐标，眼动仪未提供或右眼无效时为 n/a", "Units": "pixel"},
+		"right_y_coordinate": map[string]string{"Description": "右眼注视点纵坐标", "Units": "pixel"},
+		"right_pupil_size":   map[string]string{"Description": "右眼瞳孔直径", "Units": "mm"},
+
+		"SessionID":        session.ID.String(),
+		"UserID":           session.UserID.String(),
+		"ArticleID":        session.ArticleID,
+		"SessionStartTime": origin.Format(time.RFC3339Nano),
+	}
+	if !session.EndTime.IsZero() {
+		sidecar["SessionEndTime"] = session.EndTime.Format(time.RFC3339Nano)
+	}
+
+	if len(rows) > 0 {
+		sidecar["StartTime"] = roundTo((rows[0].t-float64(origin.UnixNano())/1e6)/1000, 6)
+		if rate := nominalRate(rows); rate > 0 {
+			sidecar["SamplingFrequency"] = rate
+		}
+		if len(rows) > 1 {
+			if duration := (rows[len(rows)-1].t - rows[0].t) / 1000; duration > 0 {
+				sidecar["SamplingFrequencyEffective"] = roundTo(float64(len(rows)-1)/duration, 2)
+			}
+		}
+	}
+
+	if len(session.DeviceInfo) > 0 {
+		sidecar["DeviceInfo"] = session.DeviceInfo
+		var device models.DeviceInfo
+		if err := json.Unmarshal(session.DeviceInfo, &device); err == nil {
+			if device.ScreenWidth > 0 && device.ScreenHeight > 0 {
+				sidecar["ScreenResolution"] = []int{device.ScreenWidth, device.ScreenHeight}
+			}
+			if device.ViewportWidth > 0 && device.ViewportHeight > 0 {
+				sidecar["ViewportResolution"] = []int{device.ViewportWidth, device.ViewportHeight}
+			}
+			if device.UserAgent != "" {
+				sidecar["UserAgent"] = device.UserAgent
+			}
+		}
+	}
+	if session.Arm != nil {
+		sidecar["ExperimentArm"] = session.Arm
+	}
+	return sidecar
+}
+
+// nominalRate 批次中出现最多的标称采样率，都未提供时返回 0
+func nominalRate(rows []physioRow) float32 {
+	counts := make(map[float32]int)
+	var best float32
+	for _, row := range rows {
+		if row.rate <= 0 {
+			continue
+		}
+		counts[row.rate]++
+		if counts[row.rate] > counts[best] || (counts[row.rate] == counts[best] && row.rate > best) {
+			best = row.rate
+		}
+	}
+	return best
+}
+
+// eventRow events.tsv 的一行
+type eventRow struct {
+	onset     float64 // 相对会话开始的秒数
+	trialType string
+	elementID string
+	x, y      *float32
+	deltaY    *float32
+	batchID   string
+}
+
+var eventColumns = []string{"onset", "duration", "trial_type", "element_id", "x", "y", "delta_y", "batch_id"}
+
+func sessionEvents(session *BIDSSession, origin time.Time) []eventRow {
+	var rows []eventRow
+	for _, record := range session.Records {
+		if record.SessionID != session.ID {
+			continue
+		}
+		for _, click := range record.Data.ClickEvents {
+			x, y := click.X, click.Y
+			rows = append(rows, eventRow{
+				onset: click.Timestamp.Sub(origin).Seconds(), trialType: "click",
+				elementID: click.ID, x: &x, y: &y, batchID: record.BatchID,
+			})
+		}
+		for _, scroll := range record.Data.ScrollEvents {
+			deltaY := scroll.DeltaY
+			rows = append(rows, eventRow{
+				onset: scroll.Timestamp.Sub(origin).Seconds(), trialType: "scroll",
+				deltaY: &deltaY, batchID: record.BatchID,
+			})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].onset < rows[j].onset })
+	return rows
+}
+
+func writeEvents(archive *zip.Writer, name string, rows []eventRow) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("创建 %s 失败: %w", name, err)
+	}
+	buf := bufio.NewWriter(file)
+	buf.WriteString(strings.Join(eventColumns, "\t"))
+	buf.WriteByte('\n')
+	for _, row := range rows {
+		fields := []string{
+			formatFloat(row.onset, 6), "0", row.trialType, textField(row.elementID),
+			optionalFloat(row.x), optionalFloat(row.y), optionalFloat(row.deltaY), textField(row.batchID),
+		}
+		buf.WriteString(strings.Join(fields, "\t"))
+		buf.WriteByte('\n')
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+func eventsSidecar(task string) map[string]interface{} {
+	return map[string]interface{}{
+		"TaskName":   task,
+		"onset":      map[string]string{"Description": "相对会话开始时间的秒数", "Units": "s"},
+		"duration":   map[string]string{"Description": "点击和滚动都是瞬时事件", "Units": "s"},
+		"trial_type": map[string]interface{}{"Description": "事件类型", "Levels": map[string]string{"click": "点击", "scroll": "滚动"}},
+		"element_id": map[string]string{"Description": "点击目标的元素ID"},
+		"x":          map[string]string{"Description": "点击位置横坐标", "Units": "pixel"},
+		"y":          map[string]string{"Description": "点击位置纵坐标", "Units": "pixel"},
+		"delta_y":    map[string]string{"Description": "垂直滚动距离，正数向下", "Units": "pixel"},
+		"batch_id":   map[string]string{"Description": "事件所在的上报批次ID，旧客户端为 n/a"},
+	}
+}
+
+func writeJSON(archive *zip.Writer, name string, v interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("创建 %s 失败: %w", name, err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+func formatFloat(v float64, precision int) string {
+	return strconv.FormatFloat(v, 'f', precision, 64)
+}
+
+// formatFloat32 按 float32 精度输出最短表示，避免 3.0999999046325684 这样的尾数
+func formatFloat32(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+func optionalFloat(v *float32) string {
+	if v == nil {
+		return na
+	}
+	return formatFloat32(*v)
+}
+
+func boolField(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+// textField 文本列，TSV 中不能出现制表符和换行
+func textField(s string) string {
+	if s == "" {
+		return na
+	}
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+func roundTo(v float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,686 @@
+package export
+
+// 把一段日期内的追踪数据和新闻浏览记录导出为 Parquet 列式文件，供分析人员直接用 pandas / Polars 读取
+// 每个日期目录下的记录逐用户读取、展开后写入，内存占用只与单个用户一天的数据量有关。
+// 追踪数据写入 Postgres 时按日期逐会话从 eye_samples / clicks / scrolls 表读取，表的列与导出文件一一对应。
+import (
+	"NewsEyeTracking/internal/analysis"
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+)
+
+// 导出的文件名
+const (
+	EyeSamplesFile    = "eye_samples.parquet"
+	ClicksFile        = "clicks.parquet"
+	ScrollsFile       = "scrolls.parquet"
+	NewsExposuresFile = "news_exposures.parquet"
+
+	// flushRows 每积累多少行写入一次
+	flushRows = 4096
+)
+
+// Files 导出的全部文件名
+var Files = []string{EyeSamplesFile, ClicksFile, ScrollsFile, NewsExposuresFile}
+
+// SessionInfo 阅读会话的元信息，用于补全导出行中的文章和设备列
+type SessionInfo struct {
+	UserID    string
+	ArticleID string
+	StartTime time.Time
+	EndTime   time.Time // 未结束时为零值
+	Device    *models.DeviceInfo
+}
+
+// Options 导出参数
+type Options struct {
+	From, To    time.Time // 本地日期，含两端
+	TrackingDir string
+	NewsDir     string
+	OutDir      string
+	// Sessions 会话元信息，为空时导出行中只有会话ID和用户ID
+	Sessions map[uuid.UUID]SessionInfo
+	// NewsImpressions 不为 nil 时新闻展示来自 news_impressions 表（见 LoadNewsImpressions），不再读取 NewsDir
+	NewsImpressions []db.NewsImpression
+	// TrackingQueries 不为 nil 时追踪数据来自 eye_samples / clicks / scrolls 表，不再读取 TrackingDir
+	// 只写数据库时文件目录是空的，读取文件只会得到空的导出文件
+	TrackingQueries *db.Queries
+}
+
+// FileResult 一个导出文件
+type FileResult struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+	Size int64  `json:"size"`
+}
+
+// Result 导出结果
+type Result struct {
+	From  string       `json:"from"`
+	To    string       `json:"to"`
+	Files []FileResult `json:"files"`
+	// UnknownSessions 追踪记录中找不到元信息的会话数
+	UnknownSessions int `json:"unknown_sessions"`
+}
+
+// sessionColumns 每一行共有的会话列
+type sessionColumns struct {
+	SessionID      string  `parquet:"session_id,dict"`
+	UserID         string  `parquet:"user_id,dict"`
+	ArticleID      *string `parquet:"article_id,dict"`
+	UserAgent      *string `parquet:"user_agent,dict"`
+	ScreenWidth    *int32  `parquet:"screen_width"`
+	ScreenHeight   *int32  `parquet:"screen_height"`
+	ViewportWidth  *int32  `parquet:"viewport_width"`
+	ViewportHeight *int32  `parquet:"viewport_height"`
+}
+
+// batchColumns 追踪记录所在批次的列，与 eye_samples / clicks / scrolls 表一致
+type batchColumns struct {
+	BatchTime time.Time `parquet:"batch_time,timestamp(microsecond)"`
+	BatchSeq  int64     `parquet:"batch_seq"`
+	BatchID   string    `parquet:"batch_id,dict"`
+}
+
+// EyeSampleRow eye_samples.parquet 的一行
+type EyeSampleRow struct {
+	sessionColumns
+	batchColumns
+	SampleIndex  int32     `parquet:"sample_index"`
+	SampleTime   time.Time `parquet:"sample_time,timestamp(microsecond)"`
+	ClientT      *float64  `parquet:"client_t"`
+	ClientClock  *float64  `parquet:"client_clock"`
+	SamplingRate float32   `parquet:"sampling_rate"`
+	ElementID    string    `parquet:"element_id,dict"`
+	X            float32   `parquet:"x"`
+	Y            float32   `parquet:"y"`
+	Pupil        *float32  `parquet:"pupil"`
+	Valid        *bool     `parquet:"valid"`
+	LeftX        *float32  `parquet:"left_x"`
+	LeftY        *float32  `parquet:"left_y"`
+	LeftPupil    *float32  `parquet:"left_pupil"`
+	LeftValid    *bool     `parquet:"left_valid"`
+	RightX       *float32  `parquet:"right_x"`
+	RightY       *float32  `parquet:"right_y"`
+	RightPupil   *float32  `parquet:"right_pupil"`
+	RightValid   *bool     `parquet:"right_valid"`
+}
+
+// ClickRow clicks.parquet 的一行
+type ClickRow struct {
+	sessionColumns
+	batchColumns
+	EventIndex int32     `parquet:"event_index"`
+	ClickedAt  time.Time `parquet:"clicked_at,timestamp(microsecond)"`
+	ElementID  string    `parquet:"element_id,dict"`
+	X          float32   `parquet:"x"`
+	Y          float32   `parquet:"y"`
+}
+
+// ScrollRow scrolls.parquet 的一行
+type ScrollRow struct {
+	sessionColumns
+	batchColumns
+	EventIndex int32     `parquet:"event_index"`
+	ScrolledAt time.Time `parquet:"scrolled_at,timestamp(microsecond)"`
+	DeltaY     float32   `parquet:"delta_y"`
+}
+
+// NewsExposureRow news_exposures.parquet 的一行，每篇展示给用户的新闻一行
+// 会话列取记录中的列表页会话；旧记录不带会话ID，取展示时间所在的列表页会话，找不到时 session_id 为空
+// 旧记录没有展示ID和推荐分数，对应列为空
+type NewsExposureRow struct {
+	sessionColumns
+	ShownAt      time.Time `parquet:"shown_at,timestamp(microsecond)"`
+	Position     int32     `parquet:"position"` // 在本次返回列表中的位置，从 0 开始
+	NewsGUID     string    `parquet:"news_guid"`
+	Strategy     string    `parquet:"strategy,dict"`
+	ImpressionID *string   `parquet:"impression_id"`
+	Score        *float64  `parquet:"score"`
+}
+
+// LoadSessions 查询 from 到 to 之间开始的阅读会话，前后各多查一天以覆盖跨午夜的会话
+func LoadSessions(ctx context.Context, queries *db.Queries, from, to time.Time) (map[uuid.UUID]SessionInfo, error) {
+	rows, err := queries.ListSessionsStartedBetween(ctx, db.ListSessionsStartedBetweenParams{
+		StartFrom: from.AddDate(0, 0, -1),
+		StartTo:   to.AddDate(0, 0, 2),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询阅读会话失败: %w", err)
+	}
+
+	sessions := make(map[uuid.UUID]SessionInfo, len(rows))
+	for _, row := range rows {
+		info := SessionInfo{UserID: row.UserID.String(), ArticleID: row.ArticleID}
+		if row.StartTime.Valid {
+			info.StartTime = row.StartTime.Time
+		}
+		if row.EndTime.Valid {
+			info.EndTime = row.EndTime.Time
+		}
+		if row.DeviceInfo.Valid {
+			var device models.DeviceInfo
+			if err := json.Unmarshal(row.DeviceInfo.RawMessage, &device); err == nil {
+				info.Device = &device
+			}
+		}
+		sessions[row.ID] = info
+	}
+	return sessions, nil
+}
+
+// LoadNewsImpressions 查询 from 到 to（含）这几天内的新闻展示
+func LoadNewsImpressions(ctx context.Context, queries *db.Queries, from, to time.Time) ([]db.NewsImpression, error) {
+	rows, err := queries.ListNewsImpressionsBetween(ctx, db.ListNewsImpressionsBetweenParams{
+		ShownFrom: from,
+		ShownTo:   to.AddDate(0, 0, 1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询新闻展示失败: %w", err)
+	}
+	if rows == nil {
+		rows = []db.NewsImpression{}
+	}
+	return rows, nil
+}
+
+// Run 执行导出，文件先写入临时文件，全部成功后再改名，失败时不会留下不完整的文件
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建导出目录失败: %w", err)
+	}
+
+	e := &exporter{sessions: opts.Sessions, unknown: make(map[uuid.UUID]bool)}
+	e.listPages = listPageSessions(opts.Sessions)
+
+	var err error
+	if e.eyes, err = newTable[EyeSampleRow](opts.OutDir, EyeSamplesFile); err != nil {
+		return nil, err
+	}
+	defer e.eyes.abort()
+	if e.clicks, err = newTable[ClickRow](opts.OutDir, ClicksFile); err != nil {
+		return nil, err
+	}
+	defer e.clicks.abort()
+	if e.scrolls, err = newTable[ScrollRow](opts.OutDir, ScrollsFile); err != nil {
+		return nil, err
+	}
+	defer e.scrolls.abort()
+	if e.news, err = newTable[NewsExposureRow](opts.OutDir, NewsExposuresFile); err != nil {
+		return nil, err
+	}
+	defer e.news.abort()
+
+	for _, date := range dates(opts.From, opts.To) {
+		if opts.TrackingQueries != nil {
+			err = e.exportTrackingTables(ctx, opts.TrackingQueries, date)
+		} else {
+			err = e.exportTracking(ctx, opts.TrackingDir, date)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if opts.NewsImpressions != nil {
+			continue
+		}
+		if err := e.exportNews(ctx, opts.NewsDir, date); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.exportNewsImpressions(opts.NewsImpressions); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		From:            opts.From.Format("2006-01-02"),
+		To:              opts.To.Format("2006-01-02"),
+		UnknownSessions: len(e.unknown),
+	}
+	for _, commit := range []func() (FileResult, error){e.eyes.commit, e.clicks.commit, e.scrolls.commit, e.news.commit} {
+		file, err := commit()
+		if err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, file)
+	}
+	return result, nil
+}
+
+// dates from 到 to（含）之间的本地日期
+// 与 storage.DatesBetween 不同，不额外包含后一天，连续导出相邻的日期范围时不会重复
+func dates(from, to time.Time) []string {
+	all := storage.DatesBetween(from, to)
+	return all[:len(all)-1]
+}
+
+type exporter struct {
+	sessions  map[uuid.UUID]SessionInfo
+	listPages map[string][]listPageSession
+	unknown   map[uuid.UUID]bool
+
+	eyes    *table[EyeSampleRow]
+	clicks  *table[ClickRow]
+	scrolls *table[ScrollRow]
+	news    *table[NewsExposureRow]
+}
+
+func (e *exporter) exportTracking(ctx context.Context, baseDir, date string) error {
+	users, err := storage.DateUsers(baseDir, date)
+	if err != nil {
+		return fmt.Errorf("列出 %s 的追踪文件失败: %w", date, err)
+	}
+
+	for _, userID := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var records []models.UserTrackingRecord
+		err := storage.ReadUserRecords(baseDir, date, userID, storage.LegacyTrackingRecords, func(record models.UserTrackingRecord) {
+			records = append(records, record)
+		})
+		if err != nil {
+			return fmt.Errorf("读取用户%s在 %s 的追踪数据失败: %w", userID, date, err)
+		}
+
+		for _, record := range records {
+			session := e.sessionColumns(record.SessionID, userID)
+			batch := batchColumns{BatchTime: record.StartTime, BatchSeq: int64(record.Seq), BatchID: record.BatchID}
+			if err := e.addEyeSamples(session, batch, record); err != nil {
+				return err
+			}
+			for i, click := range record.Data.ClickEvents {
+				if err := e.clicks.add(ClickRow{
+					sessionColumns: session, batchColumns: batch,
+					EventIndex: int32(i), ClickedAt: click.Timestamp, ElementID: click.ID, X: click.X, Y: click.Y,
+				}); err != nil {
+					return err
+				}
+			}
+			for i, scroll := range record.Data.ScrollEvents {
+				if err := e.scrolls.add(ScrollRow{
+					sessionColumns: session, batchColumns: batch,
+					EventIndex: int32(i), ScrolledAt: scroll.Timestamp, DeltaY: scroll.DeltaY,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// exportTrackingTables 导出批次时间在 date 这一天（本地时间）的追踪数据表中的记录，逐会话查询
+func (e *exporter) exportTrackingTables(ctx context.Context, queries *db.Queries, date string) error {
+	from, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return err
+	}
+	to := from.AddDate(0, 0, 1)
+
+	sessions, err := queries.ListTrackingSessionsBetween(ctx, db.ListTrackingSessionsBetweenParams{BatchFrom: from, BatchTo: to})
+	if err != nil {
+		return fmt.Errorf("查询 %s 的追踪会话失败: %w", date, err)
+	}
+
+	for _, row := range sessions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		session := e.sessionColumns(row.SessionID, row.UserID.String())
+
+		eyes, err := queries.ListSessionEyeSamplesBetween(ctx, db.ListSessionEyeSamplesBetweenParams{SessionID: row.SessionID, BatchFrom: from, BatchTo: to})
+		if err != nil {
+			return fmt.Errorf("查询会话%s的眼动采样失败: %w", row.SessionID, err)
+		}
+		for _, eye := range eyes {
+			if err := e.eyes.add(eyeSampleRow(session, eye)); err != nil {
+				return err
+			}
+		}
+
+		clicks, err := queries.ListSessionClicksBetween(ctx, db.ListSessionClicksBetweenParams{SessionID: row.SessionID, BatchFrom: from, BatchTo: to})
+		if err != nil {
+			return fmt.Errorf("查询会话%s的点击事件失败: %w", row.SessionID, err)
+		}
+		for _, click := range clicks {
+			if err := e.clicks.add(ClickRow{
+				sessionColumns: session, batchColumns: batchColumns{BatchTime: click.BatchTime, BatchSeq: click.BatchSeq, BatchID: click.BatchID},
+				EventIndex: click.EventIndex, ClickedAt: click.ClickedAt, ElementID: click.ElementID, X: click.X, Y: click.Y,
+			}); err != nil {
+				return err
+			}
+		}
+
+		scrolls, err := queries.ListSessionScrollsBetween(ctx, db.ListSessionScrollsBetweenParams{SessionID: row.SessionID, BatchFrom: from, BatchTo: to})
+		if err != nil {
+			return fmt.Errorf("查询会话%s的滚动事件失败: %w", row.SessionID, err)
+		}
+		for _, scroll := range scrolls {
+			if err := e.scrolls.add(ScrollRow{
+				sessionColumns: session, batchColumns: batchColumns{BatchTime: scroll.BatchTime, BatchSeq: scroll.BatchSeq, BatchID: scroll.BatchID},
+				EventIndex: scroll.EventIndex, ScrolledAt: scroll.ScrolledAt, DeltaY: scroll.DeltaY,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eyeSampleRow 把 eye_samples 表的一行转换为导出行，sample_time 写入时已按同一时间轴换算
+func eyeSampleRow(session sessionColumns, eye db.EyeSample) EyeSampleRow {
+	row := EyeSampleRow{
+		sessionColumns: session,
+		batchColumns:   batchColumns{BatchTime: eye.BatchTime, BatchSeq: eye.BatchSeq, BatchID: eye.BatchID},
+		SampleIndex:    eye.SampleIndex,
+		SampleTime:     eye.SampleTime,
+		ClientT:        nullFloat64(eye.ClientT),
+		ClientClock:    nullFloat64(eye.ClientClock),
+		SamplingRate:   eye.SamplingRate,
+		ElementID:      eye.ElementID,
+		X:              eye.X,
+		Y:              eye.Y,
+		Pupil:          nullFloat32(eye.Pupil),
+		Valid:          nullBool(eye.Valid),
+	}
+	if eye.LeftX.Valid && eye.LeftY.Valid {
+		row.LeftX, row.LeftY = nullFloat32(eye.LeftX), nullFloat32(eye.LeftY)
+		row.LeftPupil, row.LeftValid = nullFloat32(eye.LeftPupil), nullBool(eye.LeftValid)
+	}
+	if eye.RightX.Valid && eye.RightY.Valid {
+		row.RightX, row.RightY = nullFloat32(eye.RightX), nullFloat32(eye.RightY)
+		row.RightPupil, row.RightValid = nullFloat32(eye.RightPupil), nullBool(eye.RightValid)
+	}
+	return row
+}
+
+// addEyeSamples 展开一个批次的眼动采样，sample_time 与注视检测和 eye_samples 表使用同一时间轴
+func (e *exporter) addEyeSamples(session sessionColumns, batch batchColumns, record models.UserTrackingRecord) error {
+	times := analysis.BatchSampleTimes(record)
+	for i, event := range record.Data.EyeEvents {
+		sampleTime := record.StartTime
+		if i < len(times) {
+			sampleTime = time.UnixMicro(int64(math.Round(times[i] * 1000)))
+		}
+		row := EyeSampleRow{
+			sessionColumns: session,
+			batchColumns:   batch,
+			SampleIndex:    int32(i),
+			SampleTime:     sampleTime,
+			ClientT:        event.Timestamp,
+			ClientClock:    record.Data.ClientClock,
+			SamplingRate:   record.Data.SamplingRate,
+			ElementID:      event.ID,
+			X:              event.X,
+			Y:              event.Y,
+			Pupil:          event.Pupil,
+			Valid:          event.Valid,
+		}
+		if left := event.Left; left != nil {
+			row.LeftX, row.LeftY, row.LeftPupil, row.LeftValid = &left.X, &left.Y, left.Pupil, left.Valid
+		}
+		if right := event.Right; right != nil {
+			row.RightX, row.RightY, row.RightPupil, row.RightValid = &right.X, &right.Y, right.Pupil, right.Valid
+		}
+		if err := e.eyes.add(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *exporter) exportNews(ctx context.Context, baseDir, date string) error {
+	users, err := storage.DateUsers(baseDir, date)
+	if err != nil {
+		return fmt.Errorf("列出 %s 的新闻浏览记录失败: %w", date, err)
+	}
+
+	for _, userID := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var rows []NewsExposureRow
+		err := storage.ReadUserRecords(baseDir, date, userID, storage.LegacyNewsRecords, func(record models.UserNewsRecord) {
+			session := sessionColumns{UserID: userID}
+			if id, err := uuid.Parse(record.ListSessionID); err == nil {
+				session = e.sessionColumns(id, userID)
+			} else if id, ok := e.listPageAt(userID, record.StartTime); ok {
+				session = e.sessionColumns(id, userID)
+			}
+			if len(record.Impressions) > 0 {
+				for _, impression := range record.Impressions {
+					impressionID := impression.ImpressionID
+					rows = append(rows, NewsExposureRow{
+						sessionColumns: session,
+						ShownAt:        record.StartTime,
+						Position:       int32(impression.Position),
+						NewsGUID:       impression.GUID,
+						Strategy:       record.Strategy,
+						ImpressionID:   &impressionID,
+						Score:          impression.Score,
+					})
+				}
+				return
+			}
+			for i, guid := range record.NewsGUIDs {
+				rows = append(rows, NewsExposureRow{
+					sessionColumns: session,
+					ShownAt:        record.StartTime,
+					Position:       int32(i),
+					NewsGUID:       guid,
+					Strategy:       record.Strategy,
+				})
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("读取用户%s在 %s 的新闻浏览记录失败: %w", userID, date, err)
+		}
+		for _, row := range rows {
+			if err := e.news.add(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportNewsImpressions 导出从数据库读取的新闻展示
+func (e *exporter) exportNewsImpressions(impressions []db.NewsImpression) error {
+	for _, impression := range impressions {
+		userID := impression.UserID.String()
+		session := sessionColumns{UserID: userID}
+		if impression.ListSessionID.Valid {
+			session = e.sessionColumns(impression.ListSessionID.UUID, userID)
+		}
+		impressionID := impression.ImpressionID.String()
+		row := NewsExposureRow{
+			sessionColumns: session,
+			ShownAt:        impression.ShownAt,
+			Position:       impression.Position,
+			NewsGUID:       impression.Guid,
+			Strategy:       impression.Strategy,
+			ImpressionID:   &impressionID,
+		}
+		if impression.Score.Valid {
+			row.Score = &impression.Score.Float64
+		}
+		if err := e.news.add(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionColumns 会话列，会话元信息缺失时只填会话ID和用户ID
+func (e *exporter) sessionColumns(sessionID uuid.UUID, userID string) sessionColumns {
+	columns := sessionColumns{SessionID: sessionID.String(), UserID: userID}
+	info, ok := e.sessions[sessionID]
+	if !ok {
+		e.unknown[sessionID] = true
+		return columns
+	}
+
+	columns.ArticleID = &info.ArticleID
+	if device := info.Device; device != nil {
+		columns.UserAgent = &device.UserAgent
+		columns.ScreenWidth = int32Ptr(device.ScreenWidth)
+		columns.ScreenHeight = int32Ptr(device.ScreenHeight)
+		columns.ViewportWidth = int32Ptr(device.ViewportWidth)
+		columns.ViewportHeight = int32Ptr(device.ViewportHeight)
+	}
+	return columns
+}
+
+type listPageSession struct {
+	id         uuid.UUID
+	start, end time.Time
+}
+
+// listPageSessions 按用户分组的列表页会话，按开始时间排序
+func listPageSessions(sessions map[uuid.UUID]SessionInfo) map[string][]listPageSession {
+	byUser := make(map[string][]listPageSession)
+	for id, info := range sessions {
+		if !models.IsListPageArticleID(info.ArticleID) || info.StartTime.IsZero() {
+			continue
+		}
+		byUser[info.UserID] = append(byUser[info.UserID], listPageSession{id: id, start: info.StartTime, end: info.EndTime})
+	}
+	for _, list := range byUser {
+		sort.Slice(list, func(i, j int) bool { return list[i].start.Before(list[j].start) })
+	}
+	return byUser
+}
+
+// listPageAt 用户在 t 时刻所在的列表页会话：t 之前最近开始、且尚未结束的那一个
+func (e *exporter) listPageAt(userID string, t time.Time) (uuid.UUID, bool) {
+	list := e.listPages[userID]
+	i := sort.Search(len(list), func(i int) bool { return list[i].start.After(t) })
+	if i == 0 {
+		return uuid.Nil, false
+	}
+	session := list[i-1]
+	if !session.end.IsZero() && session.end.Before(t) {
+		return uuid.Nil, false
+	}
+	return session.id, true
+}
+
+func int32Ptr(v int) *int32 {
+	n := int32(v)
+	return &n
+}
+
+func nullFloat64(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func nullFloat32(v sql.NullFloat64) *float32 {
+	if !v.Valid {
+		return nil
+	}
+	f := float32(v.Float64)
+	return &f
+}
+
+func nullBool(v sql.NullBool) *bool {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Bool
+}
+
+// table 一个正在写入的 Parquet 文件
+type table[T any] struct {
+	name, path string
+	file       *os.File
+	writer     *parquet.GenericWriter[T]
+	buffer     []T
+	rows       int64
+	done       bool
+}
+
+func newTable[T any](dir, name string) (*table[T], error) {
+	path := filepath.Join(dir, name)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("创建 %s 失败: %w", name, err)
+	}
+	return &table[T]{
+		name:   name,
+		path:   path,
+		file:   file,
+		writer: parquet.NewGenericWriter[T](file, parquet.Compression(&parquet.Zstd)),
+		buffer: make([]T, 0, flushRows),
+	}, nil
+}
+
+func (t *table[T]) add(row T) error {
+	t.buffer = append(t.buffer, row)
+	if len(t.buffer) >= flushRows {
+		return t.flush()
+	}
+	return nil
+}
+
+func (t *table[T]) flush() error {
+	if len(t.buffer) == 0 {
+		return nil
+	}
+	n, err := t.writer.Write(t.buffer)
+	t.rows += int64(n)
+	t.buffer = t.buffer[:0]
+	if err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", t.name, err)
+	}
+	return nil
+}
+
+// commit 写入剩余的行和文件尾，然后把临时文件改名为正式文件
+func (t *table[T]) commit() (FileResult, error) {
+	if err := t.flush(); err != nil {
+		return FileResult{}, err
+	}
+	if err := t.writer.Close(); err != nil {
+		return FileResult{}, fmt.Errorf("写入 %s 文件尾失败: %w", t.name, err)
+	}
+	if err := t.file.Close(); err != nil {
+		return FileResult{}, fmt.Errorf("关闭 %s 失败: %w", t.name, err)
+	}
+	if err := os.Rename(t.path+".tmp", t.path); err != nil {
+		return FileResult{}, fmt.Errorf("保存 %s 失败: %w", t.name, err)
+	}
+	t.done = true
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return FileResult{}, err
+	}
+	return FileResult{Name: t.name, Rows: t.rows, Size: info.Size()}, nil
+}
+
+// abort 导出失败时删除临时文件
+func (t *table[T]) abort() {
+	if t.done {
+		return
+	}
+	t.file.Close()
+	os.Remove(t.path + ".tmp")
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SeqRange 一段连续的批次序号（闭区间）
+type SeqRange struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// SeqGap 接收时发现的序号缺口，之后可能被客户端重试补齐
+type SeqGap struct {
+	SeqRange
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// CompletenessReport 阅读会话的追踪数据完整性报告
+type CompletenessReport struct {
+	SessionID        uuid.UUID  `json:"session_id"`
+	Sequenced        bool       `json:"sequenced"`         // 是否收到过带序号的批次，旧客户端不带序号时无法判断缺失
+	ReceivedBatches  int64      `json:"received_batches"`  // 已保存的批次数（不含重复）
+	DuplicateBatches int64      `json:"duplicate_batches"` // 被去重丢弃的重放批次数
+	MaxSeq           uint64     `json:"max_seq"`           // 收到的最大序号
+	FinalSeq         uint64     `json:"final_seq"`         // 结束会话时客户端声明的最后序号，未声明为 0
+	ExpectedBatches  uint64     `json:"expected_batches"`  // max(max_seq, final_seq)
+	MissingBatches   uint64     `json:"missing_batches"`   // 仍然缺失的批次数
+	Missing          []SeqRange `json:"missing"`           // 仍然缺失的序号区间
+	GapsDetected     []SeqGap   `json:"gaps_detected"`     // 接收过程中出现过的缺口（包括后来补齐的）
+	Completeness     float64    `json:"completeness"`      // 已收到的带序号批次 / 期望批次
+	Complete         bool       `json:"complete"`
+	FirstReceivedAt  *time.Time `json:"first_received_at,omitempty"`
+	LastReceivedAt   *time.Time `json:"last_received_at,omitempty"`
+}
@@ -29,12 +29,22 @@ type NewsListItem struct {
 	ShareCount  	int32      `json:"share_count,omitempty"`
 	SaveCount   	int32      `json:"save_count,omitempty"`
 	CommentCount 	int32	   `json:"comment_count,omitempty"`
+	ImpressionID 	string     `json:"impression_id,omitempty"` // 本次展示的ID，打开详情时通过 impression_id 参数带回
 }
 
 // NewsListResponse 新闻列表响应
 type NewsListResponse struct {
 	Articles []NewsListItem `json:"articles"`
 	Limit    int            `json:"limit"`
+	Strategy string         `json:"strategy"` // 实际使用的推荐策略，推荐服务不可用时为兜底策略
+	Exposure *NewsExposure  `json:"-"`        // 生成本次列表的推荐结果，用于记录展示日志
+}
+
+// NewsExposure 一次新闻列表请求实际使用的推荐结果
+type NewsExposure struct {
+	Strategy        string             // 推荐算法名称
+	RecommendUserID string             // 推荐服务返回的用户ID
+	Scores          map[string]float64 // GUID -> 推荐分数，没有分数的新闻不在其中
 }
 
 // AdditionalInfo 额外信息（用于A/B测试）
@@ -74,6 +84,29 @@ type UserNewsRecord struct {
 	NewsGUIDs 			[]string  `json:"news_guids"`
 	Strategy  			string    `json:"strategy"`     // 推荐算法名称
 	UserID    			string    `json:"user_id"`     // 用户ID
+	ListSessionID string           `json:"list_session_id,omitempty"` // 展示这批新闻的列表页阅读会话
+	Impressions   []NewsImpression `json:"impressions,omitempty"`     // 与 NewsGUIDs 一一对应，旧记录没有
+}
+
+// NewsImpression 列表页中一篇新闻的一次展示
+type NewsImpression struct {
+	ImpressionID string   `json:"impression_id"`
+	GUID         string   `json:"guid"`
+	Position     int      `json:"position"`        // 在本次返回列表中的位置，从 0 开始
+	Score        *float64 `json:"score,omitempty"` // 推荐服务给出的分数
+}
+
+// NewsClickRecord 从列表页打开新闻详情的一次点击（news_clicks 表或新闻目录下 <userID>.news_clicks 文件的一行）
+// 能找到对应的展示时记录展示ID、位置、推荐算法和列表页会话，找不到时（如直接打开链接）这些字段为空
+type NewsClickRecord struct {
+	ClickID         string    `json:"click_id,omitempty"` // 服务端生成，写入数据库时用于去重
+	ClickedAt       time.Time `json:"clicked_at"`
+	GUID            string    `json:"guid"`
+	ImpressionID    string    `json:"impression_id,omitempty"`
+	Position        *int      `json:"position,omitempty"`
+	Strategy        string    `json:"strategy,omitempty"`
+	ListSessionID   string    `json:"list_session_id,omitempty"`
+	DetailSessionID string    `json:"detail_session_id"` // 打开详情时创建的文章页阅读会话
 }
 
 // UserNewsBatchRecord 用户新闻批量记录（用于文件存储）
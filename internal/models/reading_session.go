@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +17,24 @@ type DeviceInfo struct {
 	ViewportHeight int    `json:"viewport_height"`
 }
 
+// MaxViewportSize 视口宽高的上限（像素）
+const MaxViewportSize = 20000
+
+// ValidateViewport 检查视口大小，批次中附带的设备信息用于重建文档坐标，必须提供有效的视口
+func (d *DeviceInfo) ValidateViewport() error {
+	if d.ViewportWidth <= 0 || d.ViewportWidth > MaxViewportSize || d.ViewportHeight <= 0 || d.ViewportHeight > MaxViewportSize {
+		return fmt.Errorf("viewport_width and viewport_height must be between 1 and %d", MaxViewportSize)
+	}
+	return nil
+}
+
+// IsListPageArticleID 判断文章ID是否为列表页会话使用的 news<日期>000 格式
+func IsListPageArticleID(articleID string) bool {
+	return len(articleID) == len("news20060102000") &&
+		strings.HasPrefix(articleID, "news") &&
+		strings.HasSuffix(articleID, "000")
+}
+
 // ReadingSession 阅读会话模型
 type ReadingSession struct {
 	ID         uuid.UUID   `json:"id" db:"id"`
@@ -53,3 +73,25 @@ type CreateSessionResponse struct {
 	ArticleID string    `json:"article_id"`
 	StartTime time.Time `json:"start_time"`
 }
+
+// SessionSummaryResponse 阅读会话概要指标响应，时间单位为毫秒
+// 分词相关的指标只有文章页会话才有，列表页会话中省略
+type SessionSummaryResponse struct {
+	SessionID          uuid.UUID `json:"session_id"`
+	ArticleID          string    `json:"article_id"`
+	StartTime          time.Time `json:"start_time"`
+	EndTime            time.Time `json:"end_time"`
+	DwellTimeMs        int64     `json:"dwell_time_ms"`        // 停留时间
+	ActiveTimeMs       int64     `json:"active_time_ms"`       // 活跃时间（有注视、点击或滚动）
+	IdleTimeMs         int64     `json:"idle_time_ms"`         // 空闲时间
+	GazeSamples        int       `json:"gaze_samples"`         // 眼动采样数
+	ValidSamplePercent float64   `json:"valid_sample_percent"` // 有效采样百分比（0~100）
+	MaxScrollDepth     float64   `json:"max_scroll_depth"`     // 最大滚动偏移（像素）
+	ClickCount         int       `json:"click_count"`
+	TokenCount         *int      `json:"token_count,omitempty"`         // 文章分词数
+	FixatedTokens      *int      `json:"fixated_tokens,omitempty"`      // 被注视到的分词数
+	FixatedTokenRatio  *float64  `json:"fixated_token_ratio,omitempty"` // 被注视到的分词比例（0~1）
+	ReadingSpeed       *float64  `json:"reading_speed,omitempty"`       // 每分钟活跃时间注视到的分词数
+	Algorithm          string    `json:"algorithm"`                     // 注视检测算法
+	ComputedAt         time.Time `json:"computed_at"`
+}
@@ -2,16 +2,40 @@ package models
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// MaxSamplingRate 允许的最大标称采样率（Hz）
+const MaxSamplingRate = 2000
+
+// MaxBatchIDLength 批次ID的最大长度
+const MaxBatchIDLength = 128
+
+// MaxBatchSeq 允许的最大批次序号，服务端用位图记录已收到的序号，需要限制位图大小
+const MaxBatchSeq = 1 << 22
+
 // EyeEvent 眼动事件数据
+// 旧客户端只发送 id/x/y，其余字段均为可选
 type EyeEvent struct {
-	ID string `json:"id"` // 元素ID（分词或组件的唯一标识）
-	X  float32 `json:"x"`  // X坐标
-	Y  float32 `json:"y"`  // Y坐标
+	ID        string     `json:"id"`              // 元素ID（分词或组件的唯一标识）
+	X         float32    `json:"x"`               // X坐标（双眼平均或主眼）
+	Y         float32    `json:"y"`               // Y坐标
+	Timestamp *float64   `json:"t,omitempty"`     // 采样时间，客户端单调时钟（performance.now()，毫秒）
+	Pupil     *float32   `json:"pupil,omitempty"` // 瞳孔直径（毫米）
+	Valid     *bool      `json:"valid,omitempty"` // 采样是否有效，缺省视为有效
+	Left      *EyeSample `json:"left,omitempty"`  // 左眼数据（眼动仪提供双眼数据时）
+	Right     *EyeSample `json:"right,omitempty"` // 右眼数据
+}
+
+// EyeSample 单眼采样数据
+type EyeSample struct {
+	X     float32  `json:"x"`
+	Y     float32  `json:"y"`
+	Pupil *float32 `json:"pupil,omitempty"` // 瞳孔直径（毫米）
+	Valid *bool    `json:"valid,omitempty"` // 缺省视为有效
 }
 
 // ClickEvent 点击事件数据
@@ -33,6 +57,11 @@ type TrackingData struct {
 	EyeEvents    []EyeEvent    `json:"eye_event,omitempty"`
 	ClickEvents  []ClickEvent  `json:"click_event,omitempty"`
 	ScrollEvents []ScrollEvent `json:"scroll_event,omitempty"`
+	SamplingRate float32       `json:"sampling_rate,omitempty"` // 眼动仪标称采样率（Hz）
+	ClientClock  *float64      `json:"client_clock,omitempty"`  // 请求 timestamp 对应的客户端单调时钟读数（毫秒），用于把采样时间换算为绝对时间
+	// DeviceInfo 视口大小变化后的设备信息，随变化后的第一批数据发送，从该批次起生效；
+	// 会话开始时的设备信息保存在 reading_sessions.device_info
+	DeviceInfo *DeviceInfo `json:"device_info,omitempty"`
 }
 
 // SessionDataRequest 会话数据请求（支持心跳包和数据传输的混合格式）
@@ -42,19 +71,40 @@ type SessionDataRequest struct {
 	Data      *TrackingData `json:"data,omitempty"`       // 追踪数据（数据传输时包含）
 	Ping      *bool         `json:"ping,omitempty"`       // 心跳包标识（心跳时为 true）
 	Timestamp time.Time     `json:"timestamp"`            // 请求时间戳
+	Seq       uint64        `json:"seq,omitempty"`        // 批次序号，每个阅读会话从 1 开始递增，重试时保持不变
+	BatchID   string        `json:"batch_id,omitempty"`   // 批次ID，客户端生成的唯一标识，重试时保持不变
 }
 
 // EndSessionRequest 结束会话请求
 // session_id 从 URL 参数获取，不需要在请求体中包含
 type EndSessionRequest struct {
-	EndTime time.Time     `json:"end_time" binding:"required"`
-	Data    *TrackingData `json:"data,omitempty"` // 最后一批追踪数据
+	EndTime  time.Time     `json:"end_time" binding:"required"`
+	Data     *TrackingData `json:"data,omitempty"`      // 最后一批追踪数据
+	Seq      uint64        `json:"seq,omitempty"`       // 最后一批数据的批次序号
+	BatchID  string        `json:"batch_id,omitempty"`  // 最后一批数据的批次ID
+	FinalSeq uint64        `json:"final_seq,omitempty"` // 该会话最后一个批次的序号，用于发现末尾丢失的批次
+}
+
+// Validate 验证结束会话请求中的最后一批数据和批次信息
+func (r *EndSessionRequest) Validate() error {
+	if len(r.BatchID) > MaxBatchIDLength {
+		return fmt.Errorf("batch_id must not exceed %d characters", MaxBatchIDLength)
+	}
+	if r.Seq > MaxBatchSeq || r.FinalSeq > MaxBatchSeq {
+		return fmt.Errorf("seq and final_seq must not exceed %d", MaxBatchSeq)
+	}
+	if r.Data != nil {
+		return r.Data.Validate()
+	}
+	return nil
 }
 
 // UserTrackingRecord 用户追踪记录（用于缓存）
 type UserTrackingRecord struct {
 	SessionID uuid.UUID    `json:"session_id"`
 	StartTime time.Time    `json:"start_time"`
+	Seq       uint64       `json:"seq,omitempty"`
+	BatchID   string       `json:"batch_id,omitempty"`
 	Data      TrackingData `json:"data"`
 }
 
@@ -69,6 +119,11 @@ func (r *SessionDataRequest) IsHeartbeat() bool {
 	return r.Ping != nil && *r.Ping
 }
 
+// HasBatchIdentity 是否携带批次序号或批次ID，携带时服务端会做去重和缺失检测
+func (r *SessionDataRequest) HasBatchIdentity() bool {
+	return r.Seq > 0 || r.BatchID != ""
+}
+
 // HasTrackingData 检查是否包含追踪数据
 // SessionID 从 URL 参数设置，只需要检查 Data 字段
 func (r *SessionDataRequest) HasTrackingData() bool {
@@ -82,20 +137,114 @@ func (td *TrackingData) IsEmpty() bool {
 
 // Validate 验证请求数据的有效性
 func (r *SessionDataRequest) Validate() error {
-	// 心跳包验证
-	if r.IsHeartbeat() {
-		return nil // 心跳包不需要其他数据验证
+	if len(r.BatchID) > MaxBatchIDLength {
+		return fmt.Errorf("batch_id must not exceed %d characters", MaxBatchIDLength)
+	}
+	if r.Seq > MaxBatchSeq {
+		return fmt.Errorf("seq must not exceed %d", MaxBatchSeq)
 	}
 
 	// 数据传输验证（session_id 从 URL 参数获取，不需要在请求体中验证）
+	// 心跳包携带的追踪数据同样会写入缓存和预写日志，必须一起验证
 	if r.HasTrackingData() {
-		return nil // 有追踪数据就是有效的
+		return r.Data.Validate()
+	}
+
+	// 不带数据的心跳包
+	if r.IsHeartbeat() {
+		return nil
 	}
 
 	// 如果既不是心跳包也没有追踪数据，则为无效请求
 	return fmt.Errorf("request must be either a heartbeat or contain tracking data")
 }
 
+// Validate 验证追踪数据中的采样字段
+// 只发送 id/x/y 的旧格式数据始终可以通过验证
+func (td *TrackingData) Validate() error {
+	if !isFinite32(td.SamplingRate) || td.SamplingRate < 0 || td.SamplingRate > MaxSamplingRate {
+		return fmt.Errorf("sampling_rate must be between 0 and %d Hz", MaxSamplingRate)
+	}
+	if td.ClientClock != nil && (!isFinite64(*td.ClientClock) || *td.ClientClock < 0) {
+		return fmt.Errorf("client_clock must be a non-negative number")
+	}
+	if td.DeviceInfo != nil {
+		if err := td.DeviceInfo.ValidateViewport(); err != nil {
+			return fmt.Errorf("device_info: %w", err)
+		}
+	}
+
+	withTimestamp := 0
+	lastTimestamp := math.Inf(-1)
+	for i, event := range td.EyeEvents {
+		if !isFinite32(event.X) || !isFinite32(event.Y) {
+			return fmt.Errorf("eye_event[%d]: coordinates must be finite", i)
+		}
+		if event.Timestamp != nil {
+			t := *event.Timestamp
+			if !isFinite64(t) || t < 0 {
+				return fmt.Errorf("eye_event[%d]: t must be a non-negative number", i)
+			}
+			if t < lastTimestamp {
+				return fmt.Errorf("eye_event[%d]: t must be non-decreasing within a batch", i)
+			}
+			lastTimestamp = t
+			withTimestamp++
+		}
+		if err := validatePupil(event.Pupil); err != nil {
+			return fmt.Errorf("eye_event[%d]: %w", i, err)
+		}
+		for side, sample := range map[string]*EyeSample{"left": event.Left, "right": event.Right} {
+			if sample == nil {
+				continue
+			}
+			if !isFinite32(sample.X) || !isFinite32(sample.Y) {
+				return fmt.Errorf("eye_event[%d].%s: coordinates must be finite", i, side)
+			}
+			if err := validatePupil(sample.Pupil); err != nil {
+				return fmt.Errorf("eye_event[%d].%s: %w", i, side, err)
+			}
+		}
+	}
+
+	// 同一批次内要么全部带时间戳，要么全部不带，避免混用导致时间轴无法重建
+	if withTimestamp > 0 && withTimestamp != len(td.EyeEvents) {
+		return fmt.Errorf("eye_event: t must be set on all samples or none")
+	}
+
+	return nil
+}
+
+// HasSampleTimestamps 检查眼动采样是否带有逐点时间戳
+func (td *TrackingData) HasSampleTimestamps() bool {
+	return len(td.EyeEvents) > 0 && td.EyeEvents[0].Timestamp != nil
+}
+
+// IsValid 采样是否有效（未提供 valid 字段时视为有效）
+func (e *EyeEvent) IsValid() bool {
+	return e.Valid == nil || *e.Valid
+}
+
+// IsValid 单眼采样是否有效（未提供 valid 字段时视为有效）
+func (s *EyeSample) IsValid() bool {
+	return s.Valid == nil || *s.Valid
+}
+
+func validatePupil(pupil *float32) error {
+	if pupil != nil && (!isFinite32(*pupil) || *pupil < 0) {
+		return fmt.Errorf("pupil must be a non-negative number")
+	}
+	return nil
+}
+
+func isFinite32(v float32) bool {
+	return isFinite64(float64(v))
+}
+
+func isFinite64(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
 // GetDataSummary 获取数据摘要（用于日志记录）
 func (r *SessionDataRequest) GetDataSummary() string {
 	if r.IsHeartbeat() {
@@ -150,6 +299,7 @@ type SessionDataResponse struct {
 	SessionID *uuid.UUID `json:"session_id,omitempty"` // 数据传输成功时返回会话ID
 	Success   *bool      `json:"success,omitempty"`    // 数据传输成功标识
 	Pong      *bool      `json:"pong,omitempty"`       // 心跳包响应标识
+	Duplicate bool       `json:"duplicate,omitempty"`  // 该批次之前已经收到过，本次没有重复保存
 	Timestamp time.Time  `json:"timestamp"`            // 响应时间戳
 }
 
@@ -181,3 +331,50 @@ func (r *SessionDataResponse) IsDataResponse() bool {
 func (r *SessionDataResponse) IsHeartbeatResponse() bool {
 	return r.Pong != nil && *r.Pong
 }
+
+// WebSocket 上传通道的帧类型
+const (
+	FrameTypeData  = "data"  // 客户端 -> 服务端：一批追踪数据
+	FrameTypePing  = "ping"  // 客户端 -> 服务端：心跳
+	FrameTypeAck   = "ack"   // 服务端 -> 客户端：数据已写入预写日志
+	FrameTypePong  = "pong"  // 服务端 -> 客户端：心跳响应
+	FrameTypeError = "error" // 服务端 -> 客户端：该帧处理失败，客户端可以按 seq 重发
+)
+
+// TrackingFrame WebSocket 上传通道中客户端发送的一帧
+// seq 由客户端单调递增，服务端在 ack/pong/error 中原样返回
+// batch_seq/batch_id 与 HTTP 接口的 seq/batch_id 含义相同，用于跨连接的去重和缺失检测
+type TrackingFrame struct {
+	Type      string        `json:"type"`
+	Seq       uint64        `json:"seq"`
+	Timestamp time.Time     `json:"timestamp"`
+	BatchSeq  uint64        `json:"batch_seq,omitempty"`
+	BatchID   string        `json:"batch_id,omitempty"`
+	Data      *TrackingData `json:"data,omitempty"`
+}
+
+// TrackingFrameReply 服务端对一帧的响应
+type TrackingFrameReply struct {
+	Type      string    `json:"type"`
+	Seq       uint64    `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Duplicate bool      `json:"duplicate,omitempty"`
+	Code      string    `json:"code,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// ToSessionDataRequest 转换为 HTTP 上传接口使用的请求，复用同一套验证和存储逻辑
+func (f *TrackingFrame) ToSessionDataRequest(sessionID uuid.UUID) *SessionDataRequest {
+	req := &SessionDataRequest{
+		SessionID: &sessionID,
+		Data:      f.Data,
+		Timestamp: f.Timestamp,
+		Seq:       f.BatchSeq,
+		BatchID:   f.BatchID,
+	}
+	if f.Type == FrameTypePing {
+		ping := true
+		req.Ping = &ping
+	}
+	return req
+}
@@ -0,0 +1,143 @@
+package realtime
+
+// 实时眼动数据分发
+// 数据接收路径只做一次非阻塞的投递，观察者消费慢时丢弃该观察者的消息而不是阻塞接收，
+// 因此多个实验者同时观看也不会拖慢参与者的数据上传。
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"NewsEyeTracking/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSubscriberBuffer 每个观察者的消息缓冲区大小
+const DefaultSubscriberBuffer = 64
+
+// GazeEvent 推送给观察者的一批追踪数据
+type GazeEvent struct {
+	SessionID  uuid.UUID           `json:"session_id"`
+	UserID     string              `json:"user_id"`
+	ReceivedAt time.Time           `json:"received_at"`
+	Timestamp  time.Time           `json:"timestamp"`
+	Data       models.TrackingData `json:"data"`
+}
+
+// Subscriber 一个观察者的订阅
+type Subscriber struct {
+	sessionID uuid.UUID
+	events    chan GazeEvent
+	dropped   atomic.Int64
+}
+
+// Events 返回消息通道，Hub 关闭或取消订阅后通道会被关闭
+func (s *Subscriber) Events() <-chan GazeEvent {
+	return s.events
+}
+
+// Dropped 因缓冲区已满被丢弃的消息数
+func (s *Subscriber) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Hub 按阅读会话分组的订阅中心
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[uuid.UUID]map[*Subscriber]struct{}
+	buffer int
+	closed bool
+}
+
+// NewHub 创建订阅中心，buffer <= 0 时使用 DefaultSubscriberBuffer
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+	return &Hub{
+		subs:   make(map[uuid.UUID]map[*Subscriber]struct{}),
+		buffer: buffer,
+	}
+}
+
+// Subscribe 订阅某个阅读会话，Hub 已关闭时返回 nil
+func (h *Hub) Subscribe(sessionID uuid.UUID) *Subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+
+	sub := &Subscriber{
+		sessionID: sessionID,
+		events:    make(chan GazeEvent, h.buffer),
+	}
+	if h.subs[sessionID] == nil {
+		h.subs[sessionID] = make(map[*Subscriber]struct{})
+	}
+	h.subs[sessionID][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe 取消订阅并关闭消息通道，可重复调用
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	if sub == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	set, ok := h.subs[sub.sessionID]
+	if !ok {
+		return
+	}
+	if _, ok := set[sub]; !ok {
+		return
+	}
+	delete(set, sub)
+	if len(set) == 0 {
+		delete(h.subs, sub.sessionID)
+	}
+	close(sub.events)
+}
+
+// Publish 把数据投递给该会话的所有观察者，从不阻塞
+func (h *Hub) Publish(event GazeEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs[event.SessionID] {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// HasSubscribers 该会话当前是否有观察者，没有时调用方可以跳过构造消息
+func (h *Hub) HasSubscribers(sessionID uuid.UUID) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs[sessionID]) > 0
+}
+
+// Close 关闭所有订阅，用于服务停止时让长连接尽快退出
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for _, set := range h.subs {
+		for sub := range set {
+			close(sub.events)
+		}
+	}
+	h.subs = make(map[uuid.UUID]map[*Subscriber]struct{})
+}
@@ -0,0 +1,195 @@
+package render
+
+// 注视热力图和扫视路径的绘制
+// 输入为 analysis.GazePath（按视口宽度归一化的文档坐标），画布宽度由调用方指定，
+// 高度取所有注视点中最靠下的位置。热力图背景透明，可以直接叠加在同宽度的文章截图上。
+import (
+	"NewsEyeTracking/internal/analysis"
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// DefaultWidth 默认画布宽度（像素）
+	DefaultWidth = 800
+	// MaxWidth 画布宽度上限
+	MaxWidth = 2000
+	// maxAspect 画布高度最多为宽度的倍数，更靠下的点不绘制
+	maxAspect = 12
+	// MaxPixels 画布像素总数上限，宽画布的高度相应降低（热力图图像约 4 字节/像素）
+	MaxPixels = 8 << 20
+	// maxGridCells 热力图累加和模糊所用网格的格数上限，超过时按整数倍缩小网格，绘制时再插值放大
+	maxGridCells = 1 << 20
+	// sigmaRatio 热力图高斯核的标准差与画布宽度之比
+	sigmaRatio = 0.02
+)
+
+// CanvasHeight 能容纳全部注视点的画布高度
+func CanvasHeight(paths []analysis.GazePath, width int) int {
+	maxY := 0.0
+	for _, path := range paths {
+		for _, point := range path.Points {
+			maxY = math.Max(maxY, point.Y)
+		}
+	}
+	// 留出半个视口高度（按 16:9 估算）的余量，至少一屏
+	height := int(math.Ceil((maxY + 0.28) * float64(width)))
+	if min := width * 9 / 16; height < min {
+		height = min
+	}
+	if max := width * maxAspect; height > max {
+		height = max
+	}
+	if max := MaxPixels / width; height > max {
+		height = max
+	}
+	return height
+}
+
+// Heatmap 把全部会话的注视点按权重累加，高斯平滑后映射为颜色
+// 画布较大时在缩小的网格上累加和模糊（核本身覆盖几十个像素，缩小后看不出差别），再双线性插值到画布
+func Heatmap(paths []analysis.GazePath, width int) *image.NRGBA {
+	height := CanvasHeight(paths, width)
+	factor := int(math.Ceil(math.Sqrt(float64(width*height) / maxGridCells)))
+	if factor < 1 {
+		factor = 1
+	}
+	gridW, gridH := (width+factor-1)/factor, (height+factor-1)/factor
+	cell := float64(width) / float64(factor) // 归一化坐标到网格坐标的比例
+
+	grid := make([]float64, gridW*gridH)
+	for _, path := range paths {
+		for _, point := range path.Points {
+			px, py := point.X*float64(width), point.Y*float64(width)
+			if px < 0 || px >= float64(width) || py < 0 || py >= float64(height) {
+				continue
+			}
+			x, y := int(point.X*cell), int(point.Y*cell)
+			if x >= gridW || y >= gridH {
+				continue
+			}
+			grid[y*gridW+x] += point.Weight
+		}
+	}
+
+	gaussianBlur(grid, gridW, gridH, sigmaRatio*cell)
+
+	maxValue := 0.0
+	for _, v := range grid {
+		maxValue = math.Max(maxValue, v)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if maxValue == 0 {
+		return img
+	}
+	if factor == 1 {
+		for i, v := range grid {
+			img.SetNRGBA(i%width, i/width, heatColor(v/maxValue))
+		}
+		return img
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := sampleGrid(grid, gridW, gridH, (float64(x)+0.5)/float64(factor)-0.5, (float64(y)+0.5)/float64(factor)-0.5)
+			img.SetNRGBA(x, y, heatColor(v/maxValue))
+		}
+	}
+	return img
+}
+
+// sampleGrid 在网格坐标 (x, y) 处双线性插值，网格外取边缘值
+func sampleGrid(grid []float64, width, height int, x, y float64) float64 {
+	clamp := func(v float64, max int) float64 {
+		return math.Min(math.Max(v, 0), float64(max-1))
+	}
+	x, y = clamp(x, width), clamp(y, height)
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, width-1), min(y0+1, height-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+	top := grid[y0*width+x0]*(1-fx) + grid[y0*width+x1]*fx
+	bottom := grid[y1*width+x0]*(1-fx) + grid[y1*width+x1]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// heatStops 由冷到热的颜色
+var heatStops = []color.NRGBA{
+	{0, 0, 255, 0},
+	{0, 255, 255, 140},
+	{0, 255, 0, 170},
+	{255, 255, 0, 200},
+	{255, 0, 0, 220},
+}
+
+// heatColor 把 0~1 的强度映射为颜色，强度很低的区域保持透明
+func heatColor(v float64) color.NRGBA {
+	if v < 0.02 {
+		return color.NRGBA{}
+	}
+	pos := math.Min(v, 1) * float64(len(heatStops)-1)
+	i := int(pos)
+	if i >= len(heatStops)-1 {
+		return heatStops[len(heatStops)-1]
+	}
+	a, b, f := heatStops[i], heatStops[i+1], pos-float64(i)
+	lerp := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f) }
+	return color.NRGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
+
+// gaussianBlur 用三次盒式模糊近似高斯模糊，耗时与核大小无关
+func gaussianBlur(grid []float64, width, height int, sigma float64) {
+	const passes = 3
+	// n 次宽度为 w 的盒式模糊，方差为 n(w²-1)/12
+	radius := int(math.Round((math.Sqrt(12*sigma*sigma/passes+1) - 1) / 2))
+	if radius < 1 {
+		return
+	}
+
+	buf := make([]float64, len(grid))
+	for i := 0; i < passes; i++ {
+		boxBlurH(grid, buf, width, height, radius)
+		boxBlurV(buf, grid, width, height, radius)
+	}
+}
+
+// boxBlurH 水平方向的盒式模糊，画布外视为 0
+func boxBlurH(src, dst []float64, width, height, r int) {
+	scale := 1 / float64(2*r+1)
+	for y := 0; y < height; y++ {
+		row := y * width
+		sum := 0.0
+		for x := 0; x < r && x < width; x++ {
+			sum += src[row+x]
+		}
+		for x := 0; x < width; x++ {
+			if x+r < width {
+				sum += src[row+x+r]
+			}
+			if x-r-1 >= 0 {
+				sum -= src[row+x-r-1]
+			}
+			dst[row+x] = sum * scale
+		}
+	}
+}
+
+// boxBlurV 垂直方向的盒式模糊
+func boxBlurV(src, dst []float64, width, height, r int) {
+	scale := 1 / float64(2*r+1)
+	for x := 0; x < width; x++ {
+		sum := 0.0
+		for y := 0; y < r && y < height; y++ {
+			sum += src[y*width+x]
+		}
+		for y := 0; y < height; y++ {
+			if y+r < height {
+				sum += src[(y+r)*width+x]
+			}
+			if y-r-1 >= 0 {
+				sum -= src[(y-r-1)*width+x]
+			}
+			dst[y*width+x] = sum * scale
+		}
+	}
+}
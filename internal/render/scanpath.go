@@ -0,0 +1,59 @@
+package render
+
+import (
+	"NewsEyeTracking/internal/analysis"
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Scanpath 绘制 SVG 扫视路径：每个会话一种颜色，按时间顺序连线
+// fixations 为 true 时在每个注视点画圆，面积与注视时长成正比；原始采样只画连线
+func Scanpath(w io.Writer, paths []analysis.GazePath, width int, fixations bool) error {
+	height := CanvasHeight(paths, width)
+	scale := float64(width)
+	buf := bufio.NewWriter(w)
+
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	for i, path := range paths {
+		if len(path.Points) == 0 {
+			continue
+		}
+		// 黄金角分隔色相，相邻会话颜色差别明显
+		hue := math.Mod(float64(i)*137.508, 360)
+		stroke := fmt.Sprintf("hsl(%.0f,75%%,45%%)", hue)
+
+		fmt.Fprintf(buf, `<g data-session="%s"><title>%s</title>`+"\n", path.SessionID, path.SessionID)
+		buf.WriteString(`<polyline fill="none" stroke-linejoin="round" stroke-opacity="0.6" `)
+		if fixations {
+			buf.WriteString(`stroke-width="1.5" `)
+		} else {
+			buf.WriteString(`stroke-width="1" `)
+		}
+		fmt.Fprintf(buf, `stroke="%s" points="`, stroke)
+		for j, point := range path.Points {
+			if j > 0 {
+				buf.WriteByte(' ')
+			}
+			fmt.Fprintf(buf, "%.1f,%.1f", point.X*scale, point.Y*scale)
+		}
+		buf.WriteString(`"/>` + "\n")
+
+		if fixations {
+			for j, point := range path.Points {
+				// 100ms 约为半径 4px，1s 约为 12px
+				radius := math.Min(3+math.Sqrt(point.Weight)*0.3, 30)
+				opacity := 0.35
+				if j == 0 {
+					opacity = 0.8 // 第一个注视点加深，标出路径起点
+				}
+				fmt.Fprintf(buf, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s" fill-opacity="%.2f"/>`+"\n",
+					point.X*scale, point.Y*scale, radius, stroke, opacity)
+			}
+		}
+		buf.WriteString("</g>\n")
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Flush()
+}
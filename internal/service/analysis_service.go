@@ -0,0 +1,297 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/analysis"
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnalysisService 眼动数据分析服务接口
+type AnalysisService interface {
+	// DetectBatchFixations 刷新追踪数据时对一批记录做注视检测，结果写在原始数据旁边
+	DetectBatchFixations(date, userID string, records []models.UserTrackingRecord) error
+	// DetectSessionFixations 对整个阅读会话重新做注视检测并持久化
+	DetectSessionFixations(ctx context.Context, sessionID uuid.UUID, algorithm string) (*analysis.FixationRecord, error)
+	// SessionAOIMetrics 计算文章页会话的逐词兴趣区指标
+	SessionAOIMetrics(ctx context.Context, sessionID uuid.UUID, algorithm string) (*SessionAOIReport, error)
+	// ProjectSessionDocument 重建会话的滚动偏移，把眼动和点击坐标投影到文档坐标并持久化
+	ProjectSessionDocument(ctx context.Context, sessionID uuid.UUID) (*SessionDocument, error)
+	// ComputeSessionMetrics 计算已结束会话的概要指标并保存（重复计算时覆盖）
+	ComputeSessionMetrics(ctx context.Context, sessionID uuid.UUID) (*models.SessionSummaryResponse, error)
+	// SessionSummary 查询用户自己的会话的概要指标，尚未计算时立即计算
+	SessionSummary(ctx context.Context, userID, sessionID uuid.UUID) (*models.SessionSummaryResponse, error)
+	// ArticleGaze 汇总一篇文章在多个阅读会话中的注视点（文档坐标），用于绘制热力图和扫视路径
+	ArticleGaze(ctx context.Context, query ArticleGazeQuery) (*ArticleGaze, error)
+}
+
+// SessionAOIReport 会话的逐词兴趣区指标
+type SessionAOIReport struct {
+	SessionID uuid.UUID `json:"session_id"`
+	ArticleID string    `json:"article_id"`
+	Algorithm string    `json:"algorithm"`
+	*analysis.AOIMetrics
+}
+
+// SessionDocument 会话文档坐标的重建结果概要，逐批次的坐标写入 document 文件
+type SessionDocument struct {
+	SessionID  uuid.UUID                 `json:"session_id"`
+	Batches    int                       `json:"batches"`
+	EyeSamples int                       `json:"eye_samples"`
+	Clicks     int                       `json:"clicks"`
+	MaxScrollY float64                   `json:"max_scroll_y"` // 到达的最大滚动偏移（像素）
+	Viewports  []analysis.ViewportChange `json:"viewports"`    // 各批次使用的视口，只在变化时记录
+	NoViewport bool                      `json:"no_viewport"`  // 没有视口宽度，文件中的归一化坐标为 0
+	ComputedAt time.Time                 `json:"computed_at"`
+}
+
+// analysisService 分析服务实现
+type analysisService struct {
+	queries        *db.Queries
+	trackingDir    string
+	flushAlgorithm string  // 刷新时使用的算法，off 表示关闭
+	fromDatabase   bool    // 追踪数据同时写入 Postgres，本地文件中没有时从数据库读取
+	idleGap        float64 // 会话概要指标的空闲阈值（毫秒）
+}
+
+// NewAnalysisService 创建分析服务实例
+func NewAnalysisService(queries *db.Queries) AnalysisService {
+	flushAlgorithm := strings.ToLower(os.Getenv("FIXATION_ALGORITHM"))
+	if flushAlgorithm == "" {
+		flushAlgorithm = analysis.AlgorithmIVT
+	}
+
+	return &analysisService{
+		queries:        queries,
+		trackingDir:    storage.TrackingDir(),
+		flushAlgorithm: flushAlgorithm,
+		fromDatabase:   trackingInDatabase(),
+		idleGap:        analysis.IdleGap(),
+	}
+}
+
+// DetectBatchFixations 刷新时的注视检测，按会话分别计算
+func (s *analysisService) DetectBatchFixations(date, userID string, records []models.UserTrackingRecord) error {
+	if s.flushAlgorithm == "off" || len(records) == 0 {
+		return nil
+	}
+
+	// 按会话分组，保持会话出现的顺序
+	var sessionIDs []uuid.UUID
+	grouped := make(map[uuid.UUID][]models.UserTrackingRecord)
+	for _, record := range records {
+		if _, ok := grouped[record.SessionID]; !ok {
+			sessionIDs = append(sessionIDs, record.SessionID)
+		}
+		grouped[record.SessionID] = append(grouped[record.SessionID], record)
+	}
+
+	var results []analysis.FixationRecord
+	for _, sessionID := range sessionIDs {
+		samples := analysis.SessionSamples(grouped[sessionID], sessionID)
+		if len(samples) == 0 {
+			continue
+		}
+
+		result, err := analysis.Detect(s.flushAlgorithm, samples)
+		if err != nil {
+			return err
+		}
+		results = append(results, analysis.FixationRecord{
+			SessionID:  sessionID,
+			Scope:      analysis.ScopeBatch,
+			ComputedAt: time.Now(),
+			Result:     *result,
+		})
+	}
+
+	path := storage.DerivedFilePath(s.trackingDir, date, userID, storage.SchemaFixations)
+	return storage.AppendRecords(path, storage.FixationsHeader(), results)
+}
+
+// DetectSessionFixations 读取会话的全部原始数据重新检测，结果追加到会话开始日期的 fixations 文件
+func (s *analysisService) DetectSessionFixations(ctx context.Context, sessionID uuid.UUID, algorithm string) (*analysis.FixationRecord, error) {
+	session, records, err := s.loadSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := analysis.Detect(algorithm, analysis.SessionSamples(records, sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	record := analysis.FixationRecord{
+		SessionID:  sessionID,
+		Scope:      analysis.ScopeSession,
+		ComputedAt: time.Now(),
+		Result:     *result,
+	}
+
+	date := session.StartTime.Time.Local().Format("2006-01-02")
+	path := storage.DerivedFilePath(s.trackingDir, date, session.UserID.String(), storage.SchemaFixations)
+	if err := storage.AppendRecords(path, storage.FixationsHeader(), []analysis.FixationRecord{record}); err != nil {
+		return nil, fmt.Errorf("保存注视检测结果失败: %w", err)
+	}
+
+	log.Printf("会话 %s 注视检测完成（%s）：注视 %d 个，眼跳 %d 个",
+		sessionID, result.Algorithm, len(result.Fixations), len(result.Saccades))
+	return &record, nil
+}
+
+// ProjectSessionDocument 读取会话的全部原始数据重建文档坐标，逐批次追加到会话开始日期的 document 文件
+// 重复计算时追加新的记录，读取方按 computed_at 取最新的一次
+func (s *analysisService) ProjectSessionDocument(ctx context.Context, sessionID uuid.UUID) (*SessionDocument, error) {
+	session, records, err := s.loadSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := analysis.ProjectSession(records, sessionID, sessionDevice(session.DeviceInfo))
+
+	summary := &SessionDocument{SessionID: sessionID, Batches: len(results), Viewports: []analysis.ViewportChange{}}
+	lastWidth, lastHeight := 0, 0
+	for _, result := range results {
+		summary.EyeSamples += len(result.Eye)
+		summary.Clicks += len(result.Clicks)
+		summary.ComputedAt = result.ComputedAt
+		for _, point := range result.Eye {
+			summary.MaxScrollY = math.Max(summary.MaxScrollY, point.ScrollY)
+		}
+		for _, position := range result.Scrolls {
+			summary.MaxScrollY = math.Max(summary.MaxScrollY, position.ScrollY)
+		}
+		if result.ViewportWidth != lastWidth || result.ViewportHeight != lastHeight {
+			summary.Viewports = append(summary.Viewports, analysis.ViewportChange{
+				T:      float64(result.BatchTime.UnixNano()) / 1e6,
+				Width:  result.ViewportWidth,
+				Height: result.ViewportHeight,
+			})
+			lastWidth, lastHeight = result.ViewportWidth, result.ViewportHeight
+		}
+	}
+	summary.NoViewport = len(summary.Viewports) == 0
+
+	date := session.StartTime.Time.Local().Format("2006-01-02")
+	path := storage.DerivedFilePath(s.trackingDir, date, session.UserID.String(), storage.SchemaDocument)
+	if err := storage.AppendRecords(path, storage.DocumentHeader(), results); err != nil {
+		return nil, fmt.Errorf("保存文档坐标失败: %w", err)
+	}
+
+	log.Printf("会话 %s 文档坐标重建完成：批次 %d 个，眼动 %d 个，点击 %d 个，最大滚动偏移 %.0f",
+		sessionID, summary.Batches, summary.EyeSamples, summary.Clicks, summary.MaxScrollY)
+	return summary, nil
+}
+
+// SessionAOIMetrics 解析文章的分词 HTML 建立兴趣区索引，再用会话的注视序列计算指标
+func (s *analysisService) SessionAOIMetrics(ctx context.Context, sessionID uuid.UUID, algorithm string) (*SessionAOIReport, error) {
+	session, records, err := s.loadSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if models.IsListPageArticleID(session.ArticleID) {
+		return nil, ErrNotArticleSession
+	}
+
+	article, err := s.queries.GetArticleByGUID(ctx, session.ArticleID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("会话对应的文章 %s 不存在", session.ArticleID)
+		}
+		return nil, fmt.Errorf("查询文章失败: %w", err)
+	}
+
+	index, err := analysis.BuildAOIIndex(article.Title, article.Content.String)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := analysis.Detect(algorithm, analysis.SessionSamples(records, sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionAOIReport{
+		SessionID:  sessionID,
+		ArticleID:  session.ArticleID,
+		Algorithm:  result.Algorithm,
+		AOIMetrics: analysis.ComputeAOIMetrics(index, result.Fixations),
+	}, nil
+}
+
+// loadSession 查询阅读会话并读取它在本地追踪文件中的全部记录
+func (s *analysisService) loadSession(ctx context.Context, sessionID uuid.UUID) (*db.ReadingSession, []models.UserTrackingRecord, error) {
+	return loadSessionWithRecords(ctx, s.queries, s.trackingDir, s.fromDatabase, sessionID)
+}
+
+// loadSessionWithRecords 查询阅读会话并读取它的全部追踪记录
+// fromDatabase 为 true（追踪数据写入了 Postgres）时，本地文件中没有（只写数据库或已上传清理）的会话从数据库读取
+func loadSessionWithRecords(ctx context.Context, queries *db.Queries, trackingDir string, fromDatabase bool, sessionID uuid.UUID) (*db.ReadingSession, []models.UserTrackingRecord, error) {
+	session, err := queries.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrSessionNotFound
+		}
+		return nil, nil, fmt.Errorf("查询阅读会话失败: %w", err)
+	}
+
+	records, err := loadRecords(ctx, queries, trackingDir, fromDatabase, &session)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, ErrNoTrackingData
+	}
+
+	return &session, records, nil
+}
+
+// loadRecords 读取已知会话的全部追踪记录，没有数据时返回空切片
+func loadRecords(ctx context.Context, queries *db.Queries, trackingDir string, fromDatabase bool, session *db.ReadingSession) ([]models.UserTrackingRecord, error) {
+	from, to := sessionTimeRange(session)
+	records, err := storage.LoadSessionRecords(trackingDir, session.UserID.String(), session.ID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("读取会话追踪数据失败: %w", err)
+	}
+	if len(records) == 0 && fromDatabase {
+		records, err = loadSessionRecordsFromDB(ctx, queries, session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("读取会话追踪数据失败: %w", err)
+		}
+	}
+	return records, nil
+}
+
+// trackingInDatabase 追踪数据是否写入了 Postgres
+func trackingInDatabase() bool {
+	mode, _ := storage.SinkMode()
+	return mode == storage.SinkPostgres || mode == storage.SinkBoth
+}
+
+// trackingOnlyInDatabase 追踪数据是否只写入了 Postgres
+// both 模式下文件是主落地目标，Postgres 写入失败只记录日志，整段导出时仍以文件为准
+func trackingOnlyInDatabase() bool {
+	mode, _ := storage.SinkMode()
+	return mode == storage.SinkPostgres
+}
+
+// sessionTimeRange 会话的时间范围，未结束的会话以当前时间为终点
+func sessionTimeRange(session *db.ReadingSession) (time.Time, time.Time) {
+	from := session.StartTime.Time
+	to := time.Now()
+	if session.EndTime.Valid {
+		to = session.EndTime.Time
+	}
+	return from, to
+}
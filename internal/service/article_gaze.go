@@ -0,0 +1,125 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/analysis"
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sqlc-dev/pqtype"
+)
+
+// DefaultArticleGazeSessions 一次汇总的默认会话数上限（取最近的会话）
+const DefaultArticleGazeSessions = 200
+
+// MaxArticleGazeSessions 一次汇总允许请求的会话数上限
+const MaxArticleGazeSessions = DefaultArticleGazeSessions * 5
+
+// ArticleGazeQuery 文章注视汇总的筛选条件
+type ArticleGazeQuery struct {
+	ArticleID string
+	From, To  time.Time // 会话开始时间范围 [From, To)
+	Source    string    // analysis.GazeSourceFixations 或 analysis.GazeSourceSamples
+	Algorithm string    // 注视检测算法，Source 为 fixations 时使用
+	// 实验分组，为 nil 时不限
+	HasRecommend       *bool
+	HasMoreInformation *bool
+	MaxSessions        int
+}
+
+// ArticleGaze 文章注视汇总结果
+type ArticleGaze struct {
+	ArticleID string `json:"article_id"`
+	Source    string `json:"source"`
+	// Matched 符合筛选条件的会话数，Paths 中只包含有数据且能归一化的会话
+	Matched    int                 `json:"matched"`
+	NoViewport int                 `json:"no_viewport"` // 会话和批次的设备信息中都没有视口宽度，无法归一化
+	NoData     int                 `json:"no_data"`     // 本地没有追踪数据
+	Truncated  bool                `json:"truncated"`   // 会话数超过上限，只汇总了最近的会话
+	Paths      []analysis.GazePath `json:"paths"`
+}
+
+// ArticleGaze 逐个会话读取追踪数据，投影到文档坐标后按会话自己的视口宽度归一化
+func (s *analysisService) ArticleGaze(ctx context.Context, query ArticleGazeQuery) (*ArticleGaze, error) {
+	if _, err := s.queries.GetArticleByGUID(ctx, query.ArticleID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrArticleNotFound
+		}
+		return nil, fmt.Errorf("查询文章失败: %w", err)
+	}
+
+	rows, err := s.queries.ListArticleSessions(ctx, db.ListArticleSessionsParams{
+		ArticleID: query.ArticleID,
+		StartFrom: query.From,
+		StartTo:   query.To,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询文章的阅读会话失败: %w", err)
+	}
+
+	if query.Source == "" {
+		query.Source = analysis.GazeSourceFixations
+	}
+	if query.MaxSessions <= 0 {
+		query.MaxSessions = DefaultArticleGazeSessions
+	}
+	if query.MaxSessions > MaxArticleGazeSessions {
+		query.MaxSessions = MaxArticleGazeSessions
+	}
+
+	result := &ArticleGaze{ArticleID: query.ArticleID, Source: query.Source, Paths: []analysis.GazePath{}}
+	for _, row := range rows {
+		if !matchArm(query.HasRecommend, row.HasRecommend) || !matchArm(query.HasMoreInformation, row.HasMoreInformation) {
+			continue
+		}
+		result.Matched++
+		if result.Matched > query.MaxSessions {
+			result.Truncated = true
+			continue
+		}
+
+		session := db.ReadingSession{ID: row.ID, UserID: row.UserID, ArticleID: row.ArticleID, StartTime: row.StartTime, EndTime: row.EndTime}
+		records, err := loadRecords(ctx, s.queries, s.trackingDir, s.fromDatabase, &session)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			result.NoData++
+			continue
+		}
+
+		projector := analysis.NewProjector(records, row.ID, sessionDevice(row.DeviceInfo))
+		if !projector.HasViewport() {
+			result.NoViewport++
+			continue
+		}
+
+		path, err := analysis.DocumentGazePath(projector, records, row.ID, query.Source, query.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		result.Paths = append(result.Paths, *path)
+	}
+	return result, nil
+}
+
+// sessionDevice 解析会话开始时保存的设备信息，没有或无法解析时返回 nil
+func sessionDevice(raw pqtype.NullRawMessage) *models.DeviceInfo {
+	if !raw.Valid {
+		return nil
+	}
+	var device models.DeviceInfo
+	if err := json.Unmarshal(raw.RawMessage, &device); err != nil {
+		return nil
+	}
+	return &device
+}
+
+// matchArm 实验分组条件，want 为 nil 时不限；没有邀请码记录的用户不属于任何分组
+func matchArm(want *bool, value sql.NullBool) bool {
+	return want == nil || (value.Valid && value.Bool == *want)
+}
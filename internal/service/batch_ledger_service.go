@@ -0,0 +1,230 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/database"
+	"NewsEyeTracking/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// BatchLedgerService 追踪数据批次台账
+// 按阅读会话记录收到的批次ID和序号，用于去重客户端的重试、记录序号缺口并生成完整性报告
+type BatchLedgerService interface {
+	// RegisterBatch 登记一个批次，返回 true 表示该批次之前已经收到过，不应重复保存
+	RegisterBatch(ctx context.Context, sessionID uuid.UUID, seq uint64, batchID string) (bool, error)
+	// ReleaseBatch 撤销登记，批次保存失败时调用，保证客户端重试时不会被当作重复
+	ReleaseBatch(ctx context.Context, sessionID uuid.UUID, seq uint64, batchID string) error
+	// SetFinalSeq 记录客户端在结束会话时声明的最后一个批次序号
+	SetFinalSeq(ctx context.Context, sessionID uuid.UUID, finalSeq uint64) error
+	// Completeness 生成阅读会话的完整性报告
+	Completeness(ctx context.Context, sessionID uuid.UUID) (*models.CompletenessReport, error)
+}
+
+const (
+	batchLedgerKeyPrefix = "tracking_batch:"
+	batchLedgerTTL       = 7 * 24 * time.Hour // 台账保留时间，需要覆盖数据上传和分析前的检查
+)
+
+// registerBatchScript 原子地完成去重和登记
+// KEYS: 批次ID集合、序号位图、统计哈希、缺口列表、序号归属哈希
+// ARGV: 批次ID、序号、TTL（秒）、当前时间（Unix 毫秒）
+// 返回 {是否重复, 缺口起点, 缺口终点}
+var registerBatchScript = redis.NewScript(`
+local batchID = ARGV[1]
+local seq = tonumber(ARGV[2])
+if batchID ~= '' then
+	if redis.call('SADD', KEYS[1], batchID) == 0 then
+		redis.call('HINCRBY', KEYS[3], 'duplicates', 1)
+		return {1, 0, 0}
+	end
+end
+local gapFrom, gapTo = 0, 0
+if seq > 0 then
+	local prev = redis.call('SETBIT', KEYS[2], seq, 1)
+	if prev == 1 and batchID == '' then
+		redis.call('HINCRBY', KEYS[3], 'duplicates', 1)
+		return {1, 0, 0}
+	end
+	if prev == 0 then
+		redis.call('HSET', KEYS[5], seq, batchID)
+	end
+	local max = tonumber(redis.call('HGET', KEYS[3], 'max_seq') or '0')
+	if seq > max + 1 then
+		gapFrom, gapTo = max + 1, seq - 1
+		redis.call('RPUSH', KEYS[4], gapFrom .. ':' .. gapTo .. ':' .. ARGV[4])
+	end
+	if seq > max then
+		redis.call('HSET', KEYS[3], 'max_seq', seq)
+	end
+end
+redis.call('HINCRBY', KEYS[3], 'received', 1)
+redis.call('HSETNX', KEYS[3], 'first_at', ARGV[4])
+redis.call('HSET', KEYS[3], 'last_at', ARGV[4])
+for i = 1, 5 do
+	redis.call('EXPIRE', KEYS[i], ARGV[3])
+end
+return {0, gapFrom, gapTo}
+`)
+
+// releaseBatchScript 撤销一次登记，最大序号和缺口记录保持不变
+// 序号位只在由同一批次ID设置时才清除，避免撤销另一个使用相同序号的批次的登记
+var releaseBatchScript = redis.NewScript(`
+if ARGV[1] ~= '' then
+	redis.call('SREM', KEYS[1], ARGV[1])
+end
+if tonumber(ARGV[2]) > 0 and redis.call('HGET', KEYS[5], ARGV[2]) == ARGV[1] then
+	redis.call('SETBIT', KEYS[2], ARGV[2], 0)
+	redis.call('HDEL', KEYS[5], ARGV[2])
+end
+redis.call('HINCRBY', KEYS[3], 'received', -1)
+return 0
+`)
+
+type batchLedgerService struct {
+	redisClient *database.RedisClient
+}
+
+// NewBatchLedgerService 创建批次台账服务
+func NewBatchLedgerService(redisClient *database.RedisClient) BatchLedgerService {
+	return &batchLedgerService{redisClient: redisClient}
+}
+
+func (s *batchLedgerService) keys(sessionID uuid.UUID) []string {
+	prefix := batchLedgerKeyPrefix + sessionID.String()
+	return []string{prefix + ":ids", prefix + ":seqs", prefix + ":meta", prefix + ":gaps", prefix + ":owners"}
+}
+
+func (s *batchLedgerService) RegisterBatch(ctx context.Context, sessionID uuid.UUID, seq uint64, batchID string) (bool, error) {
+	result, err := registerBatchScript.Run(ctx, s.redisClient.GetClient(), s.keys(sessionID),
+		batchID, seq, int64(batchLedgerTTL.Seconds()), time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, fmt.Errorf("登记追踪批次失败: %w", err)
+	}
+
+	if result[1] > 0 {
+		log.Printf("会话%s的追踪批次出现缺口: 序号 %d-%d 尚未收到（当前序号 %d）", sessionID, result[1], result[2], seq)
+	}
+	return result[0] == 1, nil
+}
+
+func (s *batchLedgerService) ReleaseBatch(ctx context.Context, sessionID uuid.UUID, seq uint64, batchID string) error {
+	if err := releaseBatchScript.Run(ctx, s.redisClient.GetClient(), s.keys(sessionID), batchID, seq).Err(); err != nil {
+		return fmt.Errorf("撤销追踪批次登记失败: %w", err)
+	}
+	return nil
+}
+
+func (s *batchLedgerService) SetFinalSeq(ctx context.Context, sessionID uuid.UUID, finalSeq uint64) error {
+	metaKey := s.keys(sessionID)[2]
+	client := s.redisClient.GetClient()
+
+	pipe := client.TxPipeline()
+	pipe.HSet(ctx, metaKey, "final_seq", finalSeq)
+	pipe.Expire(ctx, metaKey, batchLedgerTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("记录最后批次序号失败: %w", err)
+	}
+	return nil
+}
+
+func (s *batchLedgerService) Completeness(ctx context.Context, sessionID uuid.UUID) (*models.CompletenessReport, error) {
+	keys := s.keys(sessionID)
+	client := s.redisClient.GetClient()
+
+	meta, err := client.HGetAll(ctx, keys[2]).Result()
+	if err != nil {
+		return nil, fmt.Errorf("查询批次台账失败: %w", err)
+	}
+
+	report := &models.CompletenessReport{
+		SessionID:    sessionID,
+		Missing:      []models.SeqRange{},
+		GapsDetected: []models.SeqGap{},
+	}
+	if len(meta) == 0 {
+		return report, nil
+	}
+
+	report.ReceivedBatches, _ = strconv.ParseInt(meta["received"], 10, 64)
+	report.DuplicateBatches, _ = strconv.ParseInt(meta["duplicates"], 10, 64)
+	report.MaxSeq, _ = strconv.ParseUint(meta["max_seq"], 10, 64)
+	report.FinalSeq, _ = strconv.ParseUint(meta["final_seq"], 10, 64)
+	report.FirstReceivedAt = parseMillis(meta["first_at"])
+	report.LastReceivedAt = parseMillis(meta["last_at"])
+	report.Sequenced = report.MaxSeq > 0
+
+	report.ExpectedBatches = max(report.MaxSeq, report.FinalSeq)
+	if report.ExpectedBatches > 0 {
+		bitmap, err := client.Get(ctx, keys[1]).Bytes()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("查询批次序号失败: %w", err)
+		}
+		report.Missing = missingRanges(bitmap, report.ExpectedBatches)
+		for _, r := range report.Missing {
+			report.MissingBatches += r.To - r.From + 1
+		}
+		report.Completeness = float64(report.ExpectedBatches-report.MissingBatches) / float64(report.ExpectedBatches)
+	}
+	report.Complete = report.Sequenced && report.MissingBatches == 0
+
+	gaps, err := client.LRange(ctx, keys[3], 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("查询批次缺口失败: %w", err)
+	}
+	for _, entry := range gaps {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			continue
+		}
+		from, _ := strconv.ParseUint(parts[0], 10, 64)
+		to, _ := strconv.ParseUint(parts[1], 10, 64)
+		gap := models.SeqGap{SeqRange: models.SeqRange{From: from, To: to}}
+		if at := parseMillis(parts[2]); at != nil {
+			gap.DetectedAt = *at
+		}
+		report.GapsDetected = append(report.GapsDetected, gap)
+	}
+
+	return report, nil
+}
+
+// missingRanges 找出 1..expected 中位图里没有置位的序号区间
+// Redis 位图的偏移 0 是第一个字节的最高位
+func missingRanges(bitmap []byte, expected uint64) []models.SeqRange {
+	ranges := []models.SeqRange{}
+	var start uint64
+	for seq := uint64(1); seq <= expected; seq++ {
+		set := false
+		if idx := seq / 8; idx < uint64(len(bitmap)) {
+			set = bitmap[idx]&(0x80>>(seq%8)) != 0
+		}
+
+		switch {
+		case !set && start == 0:
+			start = seq
+		case set && start != 0:
+			ranges = append(ranges, models.SeqRange{From: start, To: seq - 1})
+			start = 0
+		}
+	}
+	if start != 0 {
+		ranges = append(ranges, models.SeqRange{From: start, To: expected})
+	}
+	return ranges
+}
+
+func parseMillis(value string) *time.Time {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	t := time.UnixMilli(ms)
+	return &t
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/export"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportService 数据导出服务接口
+type ExportService interface {
+	// ExportParquet 把 from 到 to（含）之间的追踪数据和新闻浏览记录导出为 Parquet 文件
+	ExportParquet(ctx context.Context, from, to time.Time) (*ParquetExport, error)
+	// ExportFilePath 某次导出中某个文件的路径
+	ExportFilePath(id, name string) (string, error)
+	// SessionBIDS 读取单个阅读会话按 BIDS 规范导出所需的数据
+	SessionBIDS(ctx context.Context, sessionID uuid.UUID) (*export.BIDSSession, error)
+}
+
+// ParquetExport 一次 Parquet 导出
+type ParquetExport struct {
+	ID string `json:"id"`
+	*export.Result
+}
+
+type exportService struct {
+	queries      *db.Queries
+	trackingDir  string
+	fromDatabase bool // 追踪数据只写入了 Postgres
+	mu           sync.Mutex
+}
+
+// NewExportService 创建数据导出服务
+func NewExportService(queries *db.Queries) ExportService {
+	return &exportService{
+		queries:      queries,
+		trackingDir:  storage.TrackingDir(),
+		fromDatabase: trackingOnlyInDatabase(),
+	}
+}
+
+// ExportDir 导出文件根目录（EXPORT_DIR），每次导出在其中新建一个目录
+func ExportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "data/exports"
+}
+
+func (s *exportService) ExportParquet(ctx context.Context, from, to time.Time) (*ParquetExport, error) {
+	// 导出会读取整个日期范围的文件，同一时间只允许一个
+	if !s.mu.TryLock() {
+		return nil, ErrExportInProgress
+	}
+	defer s.mu.Unlock()
+
+	sessions, err := export.LoadSessions(ctx, s.queries, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var impressions []db.NewsImpression
+	if newsInDatabase() {
+		if impressions, err = export.LoadNewsImpressions(ctx, s.queries, from, to); err != nil {
+			return nil, err
+		}
+	}
+
+	id := fmt.Sprintf("%s_%s_%s", from.Format("20060102"), to.Format("20060102"), time.Now().Format("150405"))
+	opts := export.Options{
+		From:            from,
+		To:              to,
+		TrackingDir:     s.trackingDir,
+		NewsDir:         storage.NewsDir(),
+		OutDir:          filepath.Join(ExportDir(), id),
+		Sessions:        sessions,
+		NewsImpressions: impressions,
+	}
+	// 追踪数据写入了数据库时从表中导出，文件在上传后会被删除（只写数据库时根本没有文件）
+	if s.fromDatabase {
+		opts.TrackingQueries = s.queries
+	}
+	result, err := export.Run(ctx, opts)
+	if err != nil {
+		os.RemoveAll(filepath.Join(ExportDir(), id))
+		return nil, fmt.Errorf("导出 Parquet 失败: %w", err)
+	}
+	return &ParquetExport{ID: id, Result: result}, nil
+}
+
+func (s *exportService) ExportFilePath(id, name string) (string, error) {
+	// id 来自 URL，不能包含路径分隔符或 ..
+	if id == "" || id != filepath.Base(id) || id == "." || id == ".." || !slices.Contains(export.Files, name) {
+		return "", ErrExportNotFound
+	}
+	path := filepath.Join(ExportDir(), id, name)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrExportNotFound
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *exportService) SessionBIDS(ctx context.Context, sessionID uuid.UUID) (*export.BIDSSession, error) {
+	session, records, err := loadSessionWithRecords(ctx, s.queries, s.trackingDir, s.fromDatabase, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &export.BIDSSession{
+		ID:        session.ID,
+		UserID:    session.UserID,
+		ArticleID: session.ArticleID,
+		StartTime: session.StartTime.Time,
+		Records:   records,
+	}
+	if session.EndTime.Valid {
+		result.EndTime = session.EndTime.Time
+	}
+	if session.DeviceInfo.Valid {
+		result.DeviceInfo = session.DeviceInfo.RawMessage
+	}
+
+	// 用户ID即邀请码ID，实验分组来自邀请码
+	arm, err := s.queries.GetUserABTestConfig(ctx, session.UserID)
+	switch {
+	case err == nil:
+		result.Arm = &models.ExperimentConfig{HasRecommend: arm.HasRecommend.Bool, HasMoreInformation: arm.HasMoreInformation.Bool}
+	case err != sql.ErrNoRows:
+		return nil, fmt.Errorf("查询实验分组失败: %w", err)
+	}
+	return result, nil
+}
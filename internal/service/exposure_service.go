@@ -0,0 +1,284 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExposureService 列表页新闻展示与点击的统计
+type ExposureService interface {
+	// CTRReport 按推荐算法统计 [from, to) 内的展示和点击
+	CTRReport(ctx context.Context, from, to time.Time) (*CTRReport, error)
+	// FindImpression 按展示ID查询用户对某篇新闻的展示，用于关联内存中已经没有的展示（服务重启或已过期）
+	// 展示不存在、不属于该用户或没有写入数据库时返回 nil
+	FindImpression(ctx context.Context, userID uuid.UUID, guid string, impressionID uuid.UUID) (*db.NewsImpression, error)
+}
+
+// CTRReport 按推荐算法分组的点击率报告
+// 点击率 = 被点击过的展示数 / 展示数，同一次展示被多次打开只算一次
+type CTRReport struct {
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	Strategies []StrategyCTR `json:"strategies"`
+	// UnattributedClicks 找不到对应展示的点击（直接打开链接、展示记录已过期等），不计入任何算法
+	UnattributedClicks int `json:"unattributed_clicks"`
+	// LegacyRecords 没有逐条展示信息的旧格式浏览记录，无法关联点击，不参与统计（展示从数据库读取时为 0）
+	LegacyRecords int `json:"legacy_records"`
+}
+
+// StrategyCTR 一种推荐算法的展示和点击
+type StrategyCTR struct {
+	Strategy           string        `json:"strategy"`
+	Users              int           `json:"users"`
+	ListSessions       int           `json:"list_sessions"`
+	Impressions        int           `json:"impressions"`
+	ClickedImpressions int           `json:"clicked_impressions"`
+	Clicks             int           `json:"clicks"` // 归属于该算法的全部点击，包括对范围之前的展示的点击
+	CTR                float64       `json:"ctr"`
+	Positions          []PositionCTR `json:"positions"`
+}
+
+// PositionCTR 列表中某个位置（从 0 开始）的展示和点击
+type PositionCTR struct {
+	Position           int     `json:"position"`
+	Impressions        int     `json:"impressions"`
+	ClickedImpressions int     `json:"clicked_impressions"`
+	CTR                float64 `json:"ctr"`
+}
+
+type exposureService struct {
+	queries      *db.Queries
+	newsDir      string
+	fromDatabase bool // 展示和点击写入了数据库时从 news_impressions 和 news_clicks 表读取，否则读取新闻目录下的文件
+}
+
+// NewExposureService 创建展示统计服务
+func NewExposureService(queries *db.Queries) ExposureService {
+	return &exposureService{
+		queries:      queries,
+		newsDir:      storage.NewsDir(),
+		fromDatabase: newsInDatabase(),
+	}
+}
+
+func (s *exposureService) FindImpression(ctx context.Context, userID uuid.UUID, guid string, impressionID uuid.UUID) (*db.NewsImpression, error) {
+	if !s.fromDatabase {
+		return nil, nil
+	}
+	impression, err := s.queries.GetNewsImpression(ctx, db.GetNewsImpressionParams{
+		ImpressionID: impressionID,
+		UserID:       userID,
+		Guid:         guid,
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询新闻展示失败: %w", err)
+	}
+	return &impression, nil
+}
+
+// exposureImpression 范围内的一次展示
+type exposureImpression struct {
+	strategy      string
+	userID        string
+	listSessionID string
+	position      int
+}
+
+func (s *exposureService) CTRReport(ctx context.Context, from, to time.Time) (*CTRReport, error) {
+	report := &CTRReport{From: from, To: to, Strategies: []StrategyCTR{}}
+
+	data := &exposureData{
+		impressions: make(map[string]exposureImpression),
+		clicked:     make(map[string]bool),
+		clicks:      make(map[string]int),
+	}
+	var err error
+	if s.fromDatabase {
+		err = s.loadDatabase(ctx, from, to, report, data)
+	} else {
+		err = s.loadFiles(ctx, from, to, report, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	type strategyStats struct {
+		StrategyCTR
+		users        map[string]bool
+		listSessions map[string]bool
+		positions    map[int]*PositionCTR
+	}
+	byStrategy := make(map[string]*strategyStats)
+	stats := func(strategy string) *strategyStats {
+		if _, ok := byStrategy[strategy]; !ok {
+			byStrategy[strategy] = &strategyStats{
+				StrategyCTR:  StrategyCTR{Strategy: strategy},
+				users:        make(map[string]bool),
+				listSessions: make(map[string]bool),
+				positions:    make(map[int]*PositionCTR),
+			}
+		}
+		return byStrategy[strategy]
+	}
+
+	for id, impression := range data.impressions {
+		entry := stats(impression.strategy)
+		entry.Impressions++
+		entry.users[impression.userID] = true
+		if impression.listSessionID != "" {
+			entry.listSessions[impression.listSessionID] = true
+		}
+		position, ok := entry.positions[impression.position]
+		if !ok {
+			position = &PositionCTR{Position: impression.position}
+			entry.positions[impression.position] = position
+		}
+		position.Impressions++
+		if data.clicked[id] {
+			entry.ClickedImpressions++
+			position.ClickedImpressions++
+		}
+	}
+	for strategy, count := range data.clicks {
+		stats(strategy).Clicks += count
+	}
+
+	for _, entry := range byStrategy {
+		entry.Users = len(entry.users)
+		entry.ListSessions = len(entry.listSessions)
+		entry.CTR = ratio(entry.ClickedImpressions, entry.Impressions)
+		entry.Positions = make([]PositionCTR, 0, len(entry.positions))
+		for _, position := range entry.positions {
+			position.CTR = ratio(position.ClickedImpressions, position.Impressions)
+			entry.Positions = append(entry.Positions, *position)
+		}
+		sort.Slice(entry.Positions, func(i, j int) bool {
+			return entry.Positions[i].Position < entry.Positions[j].Position
+		})
+		report.Strategies = append(report.Strategies, entry.StrategyCTR)
+	}
+	sort.Slice(report.Strategies, func(i, j int) bool {
+		return report.Strategies[i].Strategy < report.Strategies[j].Strategy
+	})
+	return report, nil
+}
+
+// loadFiles 从新闻目录下的浏览记录和点击记录文件读取展示和点击
+func (s *exposureService) loadFiles(ctx context.Context, from, to time.Time, report *CTRReport, data *exposureData) error {
+	inRange := func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+
+	// 文件按写入日期分目录，DatesBetween 多包含 to 的后一天
+	for _, date := range storage.DatesBetween(from, to) {
+		users, err := storage.DateUsers(s.newsDir, date)
+		if err != nil {
+			return fmt.Errorf("列出 %s 的新闻浏览记录失败: %w", date, err)
+		}
+
+		for _, userID := range users {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			err := storage.ReadUserRecords(s.newsDir, date, userID, storage.LegacyNewsRecords, func(record models.UserNewsRecord) {
+				if !inRange(record.StartTime) {
+					return
+				}
+				if len(record.Impressions) == 0 {
+					report.LegacyRecords++
+					return
+				}
+				for _, impression := range record.Impressions {
+					data.impressions[impression.ImpressionID] = exposureImpression{
+						strategy:      record.Strategy,
+						userID:        userID,
+						listSessionID: record.ListSessionID,
+						position:      impression.Position,
+					}
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("读取用户%s在 %s 的新闻浏览记录失败: %w", userID, date, err)
+			}
+
+			err = storage.ReadUserRecords(s.newsDir, date, userID+"."+storage.SchemaNewsClicks, nil, func(click models.NewsClickRecord) {
+				if inRange(click.ClickedAt) {
+					data.addClick(report, click.ImpressionID, click.Strategy)
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("读取用户%s在 %s 的新闻点击记录失败: %w", userID, date, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadDatabase 从 news_impressions 和 news_clicks 表读取展示和点击
+// 文件会在上传后删除，数据库中的记录一直保留，两者必须来自同一个来源
+func (s *exposureService) loadDatabase(ctx context.Context, from, to time.Time, report *CTRReport, data *exposureData) error {
+	rows, err := s.queries.ListNewsImpressionsBetween(ctx, db.ListNewsImpressionsBetweenParams{ShownFrom: from, ShownTo: to})
+	if err != nil {
+		return fmt.Errorf("查询新闻展示失败: %w", err)
+	}
+	for _, row := range rows {
+		impression := exposureImpression{
+			strategy: row.Strategy,
+			userID:   row.UserID.String(),
+			position: int(row.Position),
+		}
+		if row.ListSessionID.Valid {
+			impression.listSessionID = row.ListSessionID.UUID.String()
+		}
+		data.impressions[row.ImpressionID.String()] = impression
+	}
+
+	clicks, err := s.queries.ListNewsClicksBetween(ctx, db.ListNewsClicksBetweenParams{ClickedFrom: from, ClickedTo: to})
+	if err != nil {
+		return fmt.Errorf("查询新闻点击失败: %w", err)
+	}
+	for _, click := range clicks {
+		impressionID := ""
+		if click.ImpressionID.Valid {
+			impressionID = click.ImpressionID.UUID.String()
+		}
+		data.addClick(report, impressionID, click.Strategy)
+	}
+	return nil
+}
+
+// exposureData 范围内的展示和点击
+type exposureData struct {
+	impressions map[string]exposureImpression
+	clicked     map[string]bool // 被点击过的展示ID
+	clicks      map[string]int  // 推荐算法 -> 点击数
+}
+
+// addClick 记录一次点击，没有展示ID的点击计入 UnattributedClicks
+func (d *exposureData) addClick(report *CTRReport, impressionID, strategy string) {
+	if impressionID == "" {
+		report.UnattributedClicks++
+		return
+	}
+	d.clicked[impressionID] = true
+	d.clicks[strategy]++
+}
+
+// ratio 比例，分母为 0 时为 0
+func ratio(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
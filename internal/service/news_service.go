@@ -4,10 +4,16 @@ import (
 	"NewsEyeTracking/internal/db"
 	"NewsEyeTracking/internal/models"
 	"context"
-	//"database/sql"
+	"database/sql"
 	"fmt"
 	"encoding/json"
-	//"time"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	//"math/rand"
 
 
@@ -16,30 +22,83 @@ import (
 
 // NewsService 新闻列表的设计，主要是获
 type NewsService interface {
-	// GetNews 获取新闻列表
-	GetNews(ctx context.Context, userID string, limit int, addToCache func(userID string, newsGUIDs []string, strategy string, recommendUserID string)) (*models.NewsListResponse, error)
+	// GetNews 获取新闻列表，响应的 Exposure 记录本次使用的推荐结果，由调用方写入展示日志
+	GetNews(ctx context.Context, userID string, limit int) (*models.NewsListResponse, error)
 	// GetNewsDetail 获取新闻详情
 	GetNewsDetail(ctx context.Context, newsID string, userID uuid.UUID) (*models.NewsDetailResponse, error)
 	// Stop 停止后台任务并刷新缓存（现在为空实现，保持兼容性）
 	Stop()
 }
 
+// 已展示新闻的处理方式（NEWS_SHOWN_POLICY）
+const (
+	ShownPolicyDownrank = "downrank" // 没看过的新闻优先，不够时用展示次数少的补足（默认）
+	ShownPolicyExclude  = "exclude"  // 排除看过的新闻，可能返回少于 limit 条
+	ShownPolicyOff      = "off"      // 不处理
+)
+
+// defaultShownDays 默认按最近多少天的展示判断看过（NEWS_SHOWN_DAYS）
+const defaultShownDays = 7
+
+// 推荐服务返回的策略名之外，新闻列表实际使用的策略
+const (
+	StrategyRemote        = "remote"         // 推荐服务没有返回策略名
+	StrategyLocalFallback = "local_fallback" // 推荐服务不可用，本地按最新 + 随机挑选
+	StrategyCached        = "cached"         // 本地兜底也失败，返回该用户上一次的列表
+)
+
+// 本地兜底的时间范围和历史列表的有效期
+const (
+	fallbackRecentWindow = 24 * time.Hour
+	fallbackRandomWindow = 7 * 24 * time.Hour
+	lastGoodTTL          = 24 * time.Hour
+)
+
+// lastGoodNews 用户上一次成功返回的列表
+type lastGoodNews struct {
+	items []models.NewsListItem
+	at    time.Time
+}
+
 // newsService 新闻服务实现
 type newsService struct {
 	queries         *db.Queries
 	recommendClient *RecommendService // 推荐服务客户端
+	shownPolicy     string
+	shownWindow     time.Duration
+	shownFromDB     bool // 展示写入了 news_impressions 表，可以查询看过的新闻
+	lastGoodMutex   sync.Mutex
+	lastGood        map[string]lastGoodNews // 用户ID -> 上一次成功返回的列表
 }
 
 // NewNewsService 创建新闻服务实例
 func NewNewsService(queries *db.Queries, recommendClient *RecommendService) NewsService {
+	policy := strings.ToLower(strings.TrimSpace(os.Getenv("NEWS_SHOWN_POLICY")))
+	switch policy {
+	case ShownPolicyDownrank, ShownPolicyExclude, ShownPolicyOff:
+	case "":
+		policy = ShownPolicyDownrank
+	default:
+		log.Printf("警告: 不支持的 NEWS_SHOWN_POLICY=%s（可选 downrank、exclude、off），使用 %s", policy, ShownPolicyDownrank)
+		policy = ShownPolicyDownrank
+	}
+	days, err := strconv.Atoi(os.Getenv("NEWS_SHOWN_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultShownDays
+	}
+
 	return &newsService{
 		queries:         queries,
 		recommendClient: recommendClient,
+		shownPolicy:     policy,
+		shownWindow:     time.Duration(days) * 24 * time.Hour,
+		shownFromDB:     newsInDatabase(),
+		lastGood:        make(map[string]lastGoodNews),
 	}
 }
 
 // GetNews 实现获取新闻列表逻辑, 服务端只需要实现服务逻辑就够了，不需要想着认证之类的东西, 应该在 getnes 认证的时候多获得一个字段
-func (s *newsService) GetNews(ctx context.Context, userID string, limit int, addToCache func(userID string, newsGUIDs []string, strategy string, recommendUserID string)) (*models.NewsListResponse, error) {
+func (s *newsService) GetNews(ctx context.Context, userID string, limit int) (*models.NewsListResponse, error) {
 	
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
@@ -56,76 +115,217 @@ func (s *newsService) GetNews(ctx context.Context, userID string, limit int, add
 	hasMoreInformation := abConfig.HasMoreInformation.Valid && abConfig.HasMoreInformation.Bool
 	//这里判断是否需要增加额外信息, 需要获取每一篇新闻的点赞收藏等信息
 //e7b0ecc0-b28b-45a2-8804-28479b680d08
-	 RecommendResponse, err := s.recommendClient.GetRecommendations(ctx, userID)
-	 if err != nil {
-	 	return nil, fmt.Errorf("failed to get articles: %w", err)
-	 }
-	 var  articleID  []string
-	 for _, recommendNews := range RecommendResponse.Recommendations {
-	 	articleID = append(articleID,  recommendNews.NewsID)
-	 }
-	 Params := db.GetArticlesByGUIDParams{
-		Column1: articleID,
-		Limit:   int32(limit),
-	 }
-	 articles, err := s.queries.GetArticlesByGUID(ctx, Params)
-//这一步位置得到的都是正确的 10 条
-	// 临时解决方案：直接从数据库获取最新文章
-	/*oneDayAgo := time.Now().AddDate(0, 0, -1)
-	articles, err := s.queries.GetNewArticles(ctx, db.GetNewArticlesParams{
-		PublishedAt: sql.NullTime{Time: oneDayAgo, Valid: true},
-		Limit: int32(limit),
-	})*/
+	// 查询失败时按没有看过处理，不影响返回列表
+	shown, err := s.shownNews(ctx, userUUID)
+	if err != nil {
+		log.Printf("警告: 查询用户%s看过的新闻失败: %v", userID, err)
+	}
+	shownIDs := make([]string, 0, len(shown))
+	for guid := range shown {
+		shownIDs = append(shownIDs, guid)
+	}
+	sort.Strings(shownIDs)
 
+	// 依次尝试推荐服务、本地兜底（最新 + 随机）、该用户上一次成功返回的列表
+	newsItems, exposure, err := s.remoteNews(ctx, userID, shown, shownIDs, limit)
 	if err != nil {
-		return nil, fmt.Errorf("从数据库查询对应文章错误: %w", err)
+		log.Printf("警告: 用户%s的推荐服务不可用，使用本地兜底: %v", userID, err)
+		newsItems, exposure, err = s.localNews(ctx, shown, limit)
+	}
+	if err != nil {
+		log.Printf("警告: 用户%s的本地兜底失败，使用上一次的列表: %v", userID, err)
+		newsItems, exposure, err = s.cachedNews(userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取新闻列表失败: %w", err)
+	}
+	if exposure.Strategy != StrategyCached {
+		s.rememberNews(userID, newsItems, exposure)
 	}
-	newsItems := make([]models.NewsListItem, 0, len(articles))
-	newsGUIDs := make([]string, 0, len(articles))
-	if hasMoreInformation {
-		for _, article := range articles {
-			newsItem := models.NewsListItem{
-				ID:          int(article.ID),
-				GUID:        article.Guid,
-				//Content:     article.Content.String,
-				Title:       article.Title,
-				LikeCount:   article.LikeCount.Int32,
-				ShareCount:  article.ShareCount.Int32,
-				SaveCount:   article.SaveCount.Int32,
-				CommentCount:article.CommentCount.Int32 ,
-			}
-			
-			
 
-			newsItems = append(newsItems, newsItem)
-			newsGUIDs = append(newsGUIDs, article.Guid)
+	// 没有开启额外信息的用户不返回点赞、分享等计数
+	if !hasMoreInformation {
+		for i := range newsItems {
+			newsItems[i].LikeCount, newsItems[i].ShareCount, newsItems[i].SaveCount, newsItems[i].CommentCount = 0, 0, 0, 0
 		}
-	}else{
+	}
+
+	return &models.NewsListResponse{
+		Articles: newsItems,
+		Limit:    limit,
+		Strategy: exposure.Strategy,
+		Exposure: exposure,
+	}, nil
+}
+
+// remoteNews 按推荐服务的结果查询新闻，推荐服务不可用或没有可用的新闻时返回错误
+func (s *newsService) remoteNews(ctx context.Context, userID string, shown map[string]int32, shownIDs []string, limit int) ([]models.NewsListItem, *models.NewsExposure, error) {
+	recommendResponse, err := s.recommendClient.GetRecommendations(ctx, userID, shownIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	scores := make(map[string]float64, len(recommendResponse.Recommendations))
+	for _, recommendNews := range recommendResponse.Recommendations {
+		scores[recommendNews.NewsID] = recommendNews.Score
+	}
+
+	articles, err := s.queries.GetArticlesByGUID(ctx, db.GetArticlesByGUIDParams{
+		Column1: rankShownNews(recommendResponse.Recommendations, shown, s.shownPolicy, limit),
+		Limit:   int32(limit),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("从数据库查询对应文章错误: %w", err)
+	}
+	if len(articles) == 0 {
+		return nil, nil, fmt.Errorf("推荐服务返回的 %d 篇新闻都不可用", len(recommendResponse.Recommendations))
+	}
+
+	newsItems := make([]models.NewsListItem, 0, len(articles))
+	for _, article := range articles {
+		newsItems = append(newsItems, newsListItem(article.ID, article.Guid, article.Title,
+			article.LikeCount, article.ShareCount, article.SaveCount, article.CommentCount))
+	}
+	strategy := recommendResponse.Strategy
+	if strategy == "" {
+		strategy = StrategyRemote
+	}
+	return newsItems, &models.NewsExposure{
+		Strategy:        strategy,
+		RecommendUserID: recommendResponse.UserID,
+		Scores:          scores,
+	}, nil
+}
+
+// localNews 本地兜底：一半取最近发布的新闻，其余随机补足，先排除看过的新闻
+// 不够时（NEWS_SHOWN_POLICY 不是 exclude）再用看过的新闻补足
+func (s *newsService) localNews(ctx context.Context, shown map[string]int32, limit int) ([]models.NewsListItem, *models.NewsExposure, error) {
+	now := time.Now()
+	candidates := int32(2*limit + len(shown))
+	recent, err := s.queries.GetNewArticles(ctx, db.GetNewArticlesParams{
+		PublishedAt: sql.NullTime{Time: now.Add(-fallbackRecentWindow), Valid: true},
+		Limit:       candidates,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询最新新闻失败: %w", err)
+	}
+	random, err := s.queries.GetRandomArticles(ctx, db.GetRandomArticlesParams{
+		PublishedAt: sql.NullTime{Time: now.Add(-fallbackRandomWindow), Valid: true},
+		Limit:       candidates,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询随机新闻失败: %w", err)
+	}
+
+	newsItems := make([]models.NewsListItem, 0, limit)
+	picked := make(map[string]bool)
+	pick := func(articles []db.FeedItem, count int, allowShown bool) {
 		for _, article := range articles {
-			newsItem := models.NewsListItem{
-				ID:          int(article.ID),
-				GUID:        article.Guid,
-				//Content:     article.Content.String,
-				Title:       article.Title,
+			if len(newsItems) >= count {
+				return
 			}
-			
-			
-
-			newsItems = append(newsItems, newsItem)
-			newsGUIDs = append(newsGUIDs, article.Guid)
+			if _, seen := shown[article.Guid]; picked[article.Guid] || (seen && !allowShown) {
+				continue
+			}
+			picked[article.Guid] = true
+			newsItems = append(newsItems, newsListItem(article.ID, article.Guid, article.Title,
+				article.LikeCount, article.ShareCount, article.SaveCount, article.CommentCount))
 		}
 	}
+	pick(recent, (limit+1)/2, false)
+	pick(random, limit, false)
+	pick(recent, limit, false)
+	if s.shownPolicy != ShownPolicyExclude {
+		pick(recent, limit, true)
+		pick(random, limit, true)
+	}
 
+	if len(newsItems) == 0 {
+		return nil, nil, fmt.Errorf("最近 %s 内没有可用的新闻", fallbackRandomWindow)
+	}
+	return newsItems, &models.NewsExposure{Strategy: StrategyLocalFallback}, nil
+}
 
-	if addToCache != nil {
-		addToCache(userID, newsGUIDs, RecommendResponse.Strategy, RecommendResponse.UserID)
+// cachedNews 该用户上一次成功返回的列表，超过 lastGoodTTL 的不再使用
+func (s *newsService) cachedNews(userID string) ([]models.NewsListItem, *models.NewsExposure, error) {
+	s.lastGoodMutex.Lock()
+	defer s.lastGoodMutex.Unlock()
+	cached, ok := s.lastGood[userID]
+	if !ok || time.Since(cached.at) > lastGoodTTL {
+		return nil, nil, fmt.Errorf("没有可用的历史列表")
 	}
+	// 返回副本，调用方会给列表项写入展示ID
+	return append([]models.NewsListItem(nil), cached.items...), &models.NewsExposure{Strategy: StrategyCached}, nil
+}
 
-	return &models.NewsListResponse{
-		Articles: newsItems,
-		Limit:    limit,
-	}, nil
+// rememberNews 记住用户本次成功返回的列表，作为最后的兜底
+func (s *newsService) rememberNews(userID string, newsItems []models.NewsListItem, exposure *models.NewsExposure) {
+	s.lastGoodMutex.Lock()
+	defer s.lastGoodMutex.Unlock()
+	s.lastGood[userID] = lastGoodNews{
+		items: append([]models.NewsListItem(nil), newsItems...),
+		at:    time.Now(),
+	}
+}
+
+// newsListItem 列表项，包含全部计数，是否返回计数由调用方决定
+func newsListItem(id int32, guid, title string, likeCount, shareCount, saveCount, commentCount sql.NullInt32) models.NewsListItem {
+	return models.NewsListItem{
+		ID:           int(id),
+		GUID:         guid,
+		Title:        title,
+		LikeCount:    likeCount.Int32,
+		ShareCount:   shareCount.Int32,
+		SaveCount:    saveCount.Int32,
+		CommentCount: commentCount.Int32,
+	}
+}
+
+// shownNews 用户最近看过的新闻及展示次数，新闻展示没有写入数据库或不处理时返回 nil
+func (s *newsService) shownNews(ctx context.Context, userID uuid.UUID) (map[string]int32, error) {
+	if !s.shownFromDB || s.shownPolicy == ShownPolicyOff {
+		return nil, nil
+	}
+	rows, err := s.queries.ListShownNews(ctx, db.ListShownNewsParams{
+		UserID:     userID,
+		ShownAfter: time.Now().Add(-s.shownWindow),
+	})
+	if err != nil {
+		return nil, err
+	}
+	shown := make(map[string]int32, len(rows))
+	for _, row := range rows {
+		shown[row.Guid] = row.ShowCount
+	}
+	return shown, nil
+}
+
+// rankShownNews 按处理方式挑选要查询的推荐新闻
+// 查询按发布时间取前 limit 条，所以降权时没看过的新闻足够就只查它们，不够时只补足差额，
+// 补足的看过的新闻按展示次数从少到多、同样次数保持推荐顺序
+func rankShownNews(recommendations []models.RecommendationItem, shown map[string]int32, policy string, limit int) []string {
+	var unseen, seen []string
+	for _, item := range recommendations {
+		if _, ok := shown[item.NewsID]; ok && policy != ShownPolicyOff {
+			seen = append(seen, item.NewsID)
+		} else {
+			unseen = append(unseen, item.NewsID)
+		}
+	}
+
+	switch {
+	case len(seen) == 0:
+		return unseen
+	case policy == ShownPolicyExclude:
+		return unseen
+	case len(unseen) >= limit:
+		return unseen
+	}
+	sort.SliceStable(seen, func(i, j int) bool {
+		return shown[seen[i]] < shown[seen[j]]
+	})
+	return append(unseen, seen[:min(len(seen), limit-len(unseen))]...)
 }
+
 /*
 type AdditionalInfo struct {
 	LikeCount    int `json:"like_count"`
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// NewNewsSink 按 NEWS_SINK 创建新闻浏览记录的落地目标
+func NewNewsSink(database *sql.DB) (storage.NewsSink, error) {
+	mode, err := storage.NewsSinkMode()
+	if err != nil {
+		return nil, err
+	}
+
+	files := &storage.FileSink{BaseDir: storage.NewsDir()}
+	switch mode {
+	case storage.SinkFile:
+		return files, nil
+	case storage.SinkBoth:
+		return storage.MultiNewsSink{NewPostgresNewsSink(database), files}, nil
+	default:
+		return NewPostgresNewsSink(database), nil
+	}
+}
+
+// newsInDatabase 新闻展示是否写入 Postgres
+func newsInDatabase() bool {
+	mode, _ := storage.NewsSinkMode()
+	return mode == storage.SinkPostgres || mode == storage.SinkBoth
+}
+
+// PostgresNewsSink 把新闻展示写入 news_impressions 表，每次列表请求的展示一条语句写入；点击写入 news_clicks 表
+// 一个用户一次刷新的全部记录在同一个事务中写入；展示ID和点击ID是主键，重试时已写入的记录被忽略
+// 没有逐条展示信息的旧记录不写入
+type PostgresNewsSink struct {
+	db *sql.DB
+}
+
+// NewPostgresNewsSink 创建 Postgres 落地目标
+func NewPostgresNewsSink(database *sql.DB) *PostgresNewsSink {
+	return &PostgresNewsSink{db: database}
+}
+
+func (s *PostgresNewsSink) Name() string {
+	return storage.SinkPostgres
+}
+
+func (s *PostgresNewsSink) WriteNews(ctx context.Context, date, userID string, records []models.UserNewsRecord) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return fmt.Errorf("无效的用户ID %s: %w", userID, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := db.New(tx)
+	for _, record := range records {
+		if len(record.Impressions) == 0 {
+			continue
+		}
+		params, err := newsImpressionParams(uid, record)
+		if err != nil {
+			return err
+		}
+		if err := queries.InsertNewsImpressions(ctx, params); err != nil {
+			return fmt.Errorf("写入新闻展示失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交新闻展示失败: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresNewsSink) WriteClicks(ctx context.Context, date, userID string, clicks []models.NewsClickRecord) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return fmt.Errorf("无效的用户ID %s: %w", userID, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := db.New(tx)
+	for _, click := range clicks {
+		params, err := newsClickParams(uid, click)
+		if err != nil {
+			return err
+		}
+		if err := queries.InsertNewsClick(ctx, params); err != nil {
+			return fmt.Errorf("写入新闻点击失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交新闻点击失败: %w", err)
+	}
+	return nil
+}
+
+// newsClickParams 把点击记录转换为数据库参数，缺少的展示和会话写入 NULL
+func newsClickParams(userID uuid.UUID, click models.NewsClickRecord) (db.InsertNewsClickParams, error) {
+	clickID, err := uuid.Parse(click.ClickID)
+	if err != nil {
+		return db.InsertNewsClickParams{}, fmt.Errorf("无效的点击ID %s: %w", click.ClickID, err)
+	}
+	params := db.InsertNewsClickParams{
+		ClickID:         clickID,
+		UserID:          userID,
+		Guid:            click.GUID,
+		Strategy:        click.Strategy,
+		ImpressionID:    nullUUID(click.ImpressionID),
+		ListSessionID:   nullUUID(click.ListSessionID),
+		DetailSessionID: nullUUID(click.DetailSessionID),
+		ClickedAt:       click.ClickedAt,
+	}
+	if click.Position != nil {
+		params.Position = sql.NullInt32{Int32: int32(*click.Position), Valid: true}
+	}
+	return params, nil
+}
+
+// nullUUID 解析可选的 UUID 字符串，为空或格式不正确时为 NULL
+func nullUUID(value string) uuid.NullUUID {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+// newsImpressionParams 把一次列表请求的浏览记录展开为按列的数组
+func newsImpressionParams(userID uuid.UUID, record models.UserNewsRecord) (db.InsertNewsImpressionsParams, error) {
+	params := db.InsertNewsImpressionsParams{
+		UserID:          userID,
+		Strategy:        record.Strategy,
+		RecommendUserID: record.UserID,
+		ShownAt:         record.StartTime,
+		ImpressionIds:   make([]uuid.UUID, 0, len(record.Impressions)),
+		Guids:           make([]string, 0, len(record.Impressions)),
+		Positions:       make([]int32, 0, len(record.Impressions)),
+		Scores:          make([]float64, 0, len(record.Impressions)),
+	}
+	params.ListSessionID = nullUUID(record.ListSessionID)
+
+	for _, impression := range record.Impressions {
+		id, err := uuid.Parse(impression.ImpressionID)
+		if err != nil {
+			return params, fmt.Errorf("无效的展示ID %s: %w", impression.ImpressionID, err)
+		}
+		score := math.NaN()
+		if impression.Score != nil {
+			score = *impression.Score
+		}
+		params.ImpressionIds = append(params.ImpressionIds, id)
+		params.Guids = append(params.Guids, impression.GUID)
+		params.Positions = append(params.Positions, int32(impression.Position))
+		params.Scores = append(params.Scores, score)
+	}
+	return params, nil
+}
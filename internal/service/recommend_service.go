@@ -5,17 +5,32 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// ErrRecommendCircuitOpen 推荐服务连续失败，熔断期间不再请求
+var ErrRecommendCircuitOpen = errors.New("推荐服务熔断中")
+
+// 熔断和超时的默认配置，可通过 RECOMMEND_BREAKER_FAILURES、RECOMMEND_BREAKER_COOLDOWN_SECONDS、RECOMMEND_TIMEOUT_MS 覆盖
+const (
+	defaultBreakerFailures  = 3
+	defaultBreakerCooldown  = 30   // 秒
+	defaultRecommendTimeout = 2000 // 毫秒
+)
 
 type RecommendService struct {
 	client  *http.Client
 	baseURL string
+	timeout time.Duration // 单次推荐请求的超时，留出时间给兜底策略
+	breaker *circuitBreaker
 }
 
 
@@ -30,15 +45,107 @@ func NewRecommendService() *RecommendService {
 			Timeout: 10 * time.Second,
 		},
 		baseURL: baseURL,
+		timeout: time.Duration(envPositiveInt("RECOMMEND_TIMEOUT_MS", defaultRecommendTimeout)) * time.Millisecond,
+		breaker: &circuitBreaker{
+			threshold: envPositiveInt("RECOMMEND_BREAKER_FAILURES", defaultBreakerFailures),
+			cooldown:  time.Duration(envPositiveInt("RECOMMEND_BREAKER_COOLDOWN_SECONDS", defaultBreakerCooldown)) * time.Second,
+		},
+	}
+}
+
+// envPositiveInt 读取正整数环境变量，未设置或无效时使用默认值
+func envPositiveInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		fmt.Printf("警告: 环境变量 %s=%q 无效，使用默认值 %d\n", name, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// circuitBreaker 连续失败 threshold 次后熔断 cooldown，期间直接返回错误；
+// 冷却结束后只放行一个探测请求，成功则恢复，失败则重新熔断
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow 当前是否可以请求推荐服务
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
 	}
+	b.probing = true
+	return true
 }
 
-// GetRecommendations 获取用户推荐
-func (r *RecommendService) GetRecommendations(ctx context.Context, userID string) (*models.RecommendResponse, error) {
+// release 放弃本次请求的结果（调用方取消），允许下一个请求继续探测
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// record 记录一次请求的结果
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil {
+		if b.failures >= b.threshold {
+			log.Printf("推荐服务已恢复，关闭熔断")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		log.Printf("推荐服务连续失败 %d 次，熔断 %s: %v", b.failures, b.cooldown, err)
+	}
+}
+
+// GetRecommendations 获取用户推荐，shownNewsIDs 为用户最近看过的新闻，推荐服务可以据此排除或降权
+// 熔断期间直接返回 ErrRecommendCircuitOpen；调用方取消请求不计为推荐服务失败
+func (r *RecommendService) GetRecommendations(ctx context.Context, userID string, shownNewsIDs []string) (*models.RecommendResponse, error) {
+	if !r.breaker.allow() {
+		return nil, ErrRecommendCircuitOpen
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	response, err := r.requestRecommendations(requestCtx, userID, shownNewsIDs)
+	if err != nil && ctx.Err() != nil {
+		// 不是推荐服务的问题，不计入失败
+		r.breaker.release()
+		return nil, err
+	}
+	r.breaker.record(err)
+	return response, err
+}
+
+// requestRecommendations 请求推荐服务的 /recommend
+func (r *RecommendService) requestRecommendations(ctx context.Context, userID string, shownNewsIDs []string) (*models.RecommendResponse, error) {
 	// 构建请求体，直接使用UUID字符串
 	requestBody := map[string]interface{}{
 		"user_id": userID,
 	}
+	if len(shownNewsIDs) > 0 {
+		requestBody["shown_news_ids"] = shownNewsIDs
+	}
 
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
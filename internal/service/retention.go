@@ -0,0 +1,219 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/storage"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 数据保留规则名称
+const (
+	RetentionTrackingFiles   = "tracking_files"   // 本地追踪数据文件中已上传的
+	RetentionNewsFiles       = "news_files"       // 本地新闻浏览记录文件中已上传的
+	RetentionTempFiles       = "temp_files"       // 临时上传目录中遗留的压缩包等文件
+	RetentionReadingSessions = "reading_sessions" // 已结束的阅读会话
+	RetentionUserSessions    = "user_sessions"    // 非活跃的用户会话
+	RetentionLogs            = "logs"             // 应用日志目录中的历史日志
+)
+
+// maxReportedItems 报告中每条规则最多列出的文件数
+const maxReportedItems = 200
+
+// logDir 应用日志目录，与 pkg/logger 一致；正在写入的 app.log / error.log 不会被删除
+const logDir = "logs"
+
+// RetentionPolicy 数据保留策略，每条规则单独设置最长保留时间，0 表示不清理
+type RetentionPolicy struct {
+	TrackingFiles   time.Duration
+	NewsFiles       time.Duration
+	TempFiles       time.Duration
+	ReadingSessions time.Duration
+	UserSessions    time.Duration
+	Logs            time.Duration
+}
+
+// RetentionPolicyFromEnv 从环境变量读取保留天数：
+// RETENTION_TRACKING_DAYS、RETENTION_NEWS_DAYS、RETENTION_TEMP_DAYS、
+// RETENTION_READING_SESSION_DAYS、RETENTION_USER_SESSION_DAYS、RETENTION_LOG_DAYS
+// 本地数据文件只清理上传台账记录为已上传的（上传后删除失败遗留的），默认不清理，其余规则有默认值
+func RetentionPolicyFromEnv() RetentionPolicy {
+	return RetentionPolicy{
+		TrackingFiles:   envDays("RETENTION_TRACKING_DAYS", 0),
+		NewsFiles:       envDays("RETENTION_NEWS_DAYS", 0),
+		TempFiles:       envDays("RETENTION_TEMP_DAYS", 7),
+		ReadingSessions: envDays("RETENTION_READING_SESSION_DAYS", 0),
+		UserSessions:    envDays("RETENTION_USER_SESSION_DAYS", 90),
+		Logs:            envDays("RETENTION_LOG_DAYS", 30),
+	}
+}
+
+func envDays(name string, defaultDays int) time.Duration {
+	days := defaultDays
+	if value := os.Getenv(name); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			fmt.Printf("警告: 环境变量 %s=%q 无效，使用默认值 %d 天\n", name, value, defaultDays)
+		} else {
+			days = parsed
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RetentionReport 一次清理的结果
+type RetentionReport struct {
+	DryRun     bool                  `json:"dry_run"`
+	StartedAt  time.Time             `json:"started_at"`
+	FinishedAt time.Time             `json:"finished_at"`
+	Rules      []RetentionRuleResult `json:"rules"`
+}
+
+// RetentionRuleResult 一条规则的清理结果，试运行时 Deleted 为 0
+type RetentionRuleResult struct {
+	Rule       string    `json:"rule"`
+	MaxAgeDays float64   `json:"max_age_days"`
+	Cutoff     time.Time `json:"cutoff"`
+	Matched    int64     `json:"matched"` // 超过保留时间的文件数或数据行数
+	Bytes      int64     `json:"bytes,omitempty"`
+	Deleted    int64     `json:"deleted"`
+	Skipped    int64     `json:"skipped,omitempty"` // 仍在写入、仍在上传队列中或尚未上传的文件
+	Items      []string  `json:"items,omitempty"`   // 匹配的文件（最多 200 个）
+	Error      string    `json:"error,omitempty"`
+}
+
+func newRuleResult(rule string, maxAge time.Duration, now time.Time) RetentionRuleResult {
+	return RetentionRuleResult{
+		Rule:       rule,
+		MaxAgeDays: maxAge.Hours() / 24,
+		Cutoff:     now.Add(-maxAge),
+	}
+}
+
+// addFileRule 执行一条本地文件规则，maxAge 为 0 或目录未配置时跳过
+func (r *RetentionReport) addFileRule(rule string, maxAge time.Duration, now time.Time, root string, recursive, dryRun bool, skip func(path string) bool) {
+	if maxAge <= 0 || root == "" {
+		return
+	}
+	result := newRuleResult(rule, maxAge, now)
+	cleanupFiles(&result, root, recursive, dryRun, skip)
+	r.Rules = append(r.Rules, result)
+}
+
+// addRowRule 执行一条数据库规则，试运行时只统计行数
+func (r *RetentionReport) addRowRule(rule string, maxAge time.Duration, now time.Time, dryRun bool, count, remove func(cutoff time.Time) (int64, error)) {
+	if maxAge <= 0 {
+		return
+	}
+	result := newRuleResult(rule, maxAge, now)
+	if dryRun {
+		matched, err := count(result.Cutoff)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Matched = matched
+	} else {
+		deleted, err := remove(result.Cutoff)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Matched, result.Deleted = deleted, deleted
+	}
+	r.Rules = append(r.Rules, result)
+}
+
+// cleanupFiles 删除 root 下修改时间早于截止时间的文件，skip 返回 true 的文件不处理
+// 递归模式下删除后变空的日期目录也一并删除
+func cleanupFiles(result *RetentionRuleResult, root string, recursive, dryRun bool, skip func(path string) bool) {
+	var matched []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !info.ModTime().Before(result.Cutoff) {
+			return nil
+		}
+		if skip != nil && skip(path) {
+			result.Skipped++
+			return nil
+		}
+
+		result.Matched++
+		result.Bytes += info.Size()
+		matched = append(matched, path)
+		return nil
+	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	sort.Strings(matched)
+	if len(matched) > maxReportedItems {
+		result.Items = matched[:maxReportedItems]
+	} else {
+		result.Items = matched
+	}
+	if dryRun {
+		return
+	}
+
+	dirs := make(map[string]struct{})
+	for _, path := range matched {
+		if err := os.Remove(path); err != nil {
+			result.Error = fmt.Sprintf("删除 %s 失败: %v", path, err)
+			continue
+		}
+		result.Deleted++
+		dirs[filepath.Dir(path)] = struct{}{}
+	}
+	if recursive {
+		for dir := range dirs {
+			if dir != root {
+				os.Remove(dir) // 只有空目录会被删除
+			}
+		}
+	}
+}
+
+// isActiveLogFile 正在写入的日志文件
+func isActiveLogFile(path string) bool {
+	name := filepath.Base(path)
+	return name == "app.log" || name == "error.log"
+}
+
+// isUploadedFile 上传台账记录为已上传，且大小与压缩时一致（之后没有追加新数据）的文件
+func isUploadedFile(path string, uploaded map[string]int64) bool {
+	size, ok := uploaded[path]
+	if !ok {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == size
+}
+
+// isOpenDataFile 仍在写入的数据文件
+func isOpenDataFile(path string) bool {
+	return storage.IsOpenFile(path) || strings.HasSuffix(path, ".tmp")
+}
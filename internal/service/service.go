@@ -3,7 +3,29 @@ package service
 import (
 	"NewsEyeTracking/internal/database"
 	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/storage"
 	"database/sql"
+	"errors"
+	"log"
+)
+
+var (
+	// ErrSessionNotFound 阅读会话不存在
+	ErrSessionNotFound = errors.New("阅读会话不存在")
+	// ErrNoTrackingData 本地没有该会话的追踪数据（尚未上传或已上传清理）
+	ErrNoTrackingData = errors.New("未找到该会话的追踪数据")
+	// ErrNotArticleSession 列表页会话没有对应的文章
+	ErrNotArticleSession = errors.New("该会话是列表页会话，没有对应的文章")
+	// ErrArticleNotFound 文章不存在
+	ErrArticleNotFound = errors.New("文章不存在")
+	// ErrQuietHours 当前处于上传静默时段
+	ErrQuietHours = errors.New("当前处于上传静默时段")
+	// ErrExportInProgress 已有导出任务在进行
+	ErrExportInProgress = errors.New("已有导出任务在进行")
+	// ErrExportNotFound 导出文件不存在
+	ErrExportNotFound = errors.New("导出文件不存在")
+	// ErrSessionNotEnded 阅读会话尚未结束
+	ErrSessionNotEnded = errors.New("阅读会话尚未结束")
 )
 
 // 合理的架构设计？ service 包含每个所有的service 接口, 通过 service 来调用相应的接口
@@ -14,6 +36,12 @@ type Services struct {
 	UserSession    UserSessionService
 	Auth           AuthService
 	Upload         UploadService
+	Analysis       AnalysisService
+	BatchLedger    BatchLedgerService
+	Export         ExportService
+	Exposure       ExposureService
+	TrackingSink   storage.TrackingSink   // 追踪数据的落地目标（文件 / Postgres）
+	NewsSink       storage.NewsSink       // 新闻浏览记录的落地目标（Postgres / 文件）
 	Recommend      *RecommendService      // 推荐服务
 	SessionCleanup *SessionCleanupService // 会话清理服务
 }
@@ -31,6 +59,15 @@ func NewServices(database *sql.DB, redisClient *database.RedisClient) *Services
 	// 创建会话清理服务
 	sessionCleanupService := NewSessionCleanupService(queries, userSessionService, sessionService)
 
+	trackingSink, err := NewTrackingSink(database)
+	if err != nil {
+		log.Fatalf("初始化追踪数据落地目标失败: %v", err)
+	}
+	newsSink, err := NewNewsSink(database)
+	if err != nil {
+		log.Fatalf("初始化新闻浏览记录落地目标失败: %v", err)
+	}
+
 	return &Services{
 		User:           NewUserService(queries),
 		News:           NewNewsService(queries, recommendService), // 传递推荐服务
@@ -38,6 +75,12 @@ func NewServices(database *sql.DB, redisClient *database.RedisClient) *Services
 		UserSession:    userSessionService,
 		Auth:           NewAuthService(queries),
 		Upload:         NewUploadService(queries),
+		Analysis:       NewAnalysisService(queries),
+		BatchLedger:    NewBatchLedgerService(redisClient),
+		Export:         NewExportService(queries),
+		Exposure:       NewExposureService(queries),
+		TrackingSink:   trackingSink,
+		NewsSink:       newsSink,
 		Recommend:      recommendService,
 		SessionCleanup: sessionCleanupService,
 	}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/analysis"
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// ComputeSessionMetrics 计算已结束会话的概要指标并保存到 reading_session_metrics，重复计算时覆盖
+// 没有追踪数据的会话也会保存（只有停留时间，其余为 0）
+func (s *analysisService) ComputeSessionMetrics(ctx context.Context, sessionID uuid.UUID) (*models.SessionSummaryResponse, error) {
+	session, err := s.queries.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("查询阅读会话失败: %w", err)
+	}
+	return s.computeSessionMetrics(ctx, &session)
+}
+
+// SessionSummary 查询用户自己的会话的概要指标，会话已结束但还没有计算过时立即计算
+func (s *analysisService) SessionSummary(ctx context.Context, userID, sessionID uuid.UUID) (*models.SessionSummaryResponse, error) {
+	session, err := s.queries.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("查询阅读会话失败: %w", err)
+	}
+	// 其他用户的会话按不存在处理
+	if session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	if !session.EndTime.Valid {
+		return nil, ErrSessionNotEnded
+	}
+
+	metrics, err := s.queries.GetSessionMetrics(ctx, sessionID)
+	if err == sql.ErrNoRows {
+		return s.computeSessionMetrics(ctx, &session)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询会话概要指标失败: %w", err)
+	}
+	return newSessionSummaryResponse(&session, &metrics), nil
+}
+
+func (s *analysisService) computeSessionMetrics(ctx context.Context, session *db.ReadingSession) (*models.SessionSummaryResponse, error) {
+	if !session.EndTime.Valid {
+		return nil, ErrSessionNotEnded
+	}
+
+	records, err := loadRecords(ctx, s.queries, s.trackingDir, s.fromDatabase, session)
+	if err != nil {
+		return nil, err
+	}
+
+	projector := analysis.NewProjector(records, session.ID, sessionDevice(session.DeviceInfo))
+	summary := analysis.SummarizeSession(records, session.ID, session.StartTime.Time, session.EndTime.Time, projector, s.idleGap)
+
+	// 刷新时关闭了注视检测也照常计算分词指标
+	algorithm := s.flushAlgorithm
+	if algorithm == "off" {
+		algorithm = analysis.AlgorithmIVT
+	}
+	params := db.UpsertSessionMetricsParams{
+		SessionID:          session.ID,
+		UserID:             session.UserID,
+		ArticleID:          session.ArticleID,
+		DwellTimeMs:        int64(math.Round(summary.DwellTime)),
+		ActiveTimeMs:       int64(math.Round(summary.ActiveTime)),
+		IdleTimeMs:         int64(math.Round(summary.IdleTime)),
+		GazeSamples:        int32(summary.GazeSamples),
+		ValidSamplePercent: summary.ValidSamplePercent,
+		MaxScrollDepth:     summary.MaxScrollDepth,
+		ClickCount:         int32(summary.Clicks),
+		Algorithm:          algorithm,
+	}
+
+	if !models.IsListPageArticleID(session.ArticleID) {
+		aoi, err := s.articleTokenMetrics(ctx, session, records, algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if aoi != nil {
+			params.TokenCount = sql.NullInt32{Int32: int32(aoi.TokenCount), Valid: true}
+			params.FixatedTokens = sql.NullInt32{Int32: int32(aoi.FixatedTokens), Valid: true}
+			if aoi.TokenCount > 0 {
+				params.FixatedTokenRatio = sql.NullFloat64{Float64: float64(aoi.FixatedTokens) / float64(aoi.TokenCount), Valid: true}
+			}
+			if summary.ActiveTime > 0 {
+				params.ReadingSpeed = sql.NullFloat64{Float64: float64(aoi.FixatedTokens) / (summary.ActiveTime / 60000), Valid: true}
+			}
+		}
+	}
+
+	metrics, err := s.queries.UpsertSessionMetrics(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("保存会话概要指标失败: %w", err)
+	}
+
+	log.Printf("会话 %s 概要指标计算完成：停留 %dms，活跃 %dms，采样 %d 个（有效 %.1f%%）",
+		session.ID, metrics.DwellTimeMs, metrics.ActiveTimeMs, metrics.GazeSamples, metrics.ValidSamplePercent)
+	return newSessionSummaryResponse(session, &metrics), nil
+}
+
+// articleTokenMetrics 用会话的注视序列计算文章的逐词指标，文章已不存在时返回 nil
+func (s *analysisService) articleTokenMetrics(ctx context.Context, session *db.ReadingSession, records []models.UserTrackingRecord, algorithm string) (*analysis.AOIMetrics, error) {
+	article, err := s.queries.GetArticleByGUID(ctx, session.ArticleID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Printf("会话 %s 对应的文章 %s 不存在，跳过分词指标", session.ID, session.ArticleID)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询文章失败: %w", err)
+	}
+
+	index, err := analysis.BuildAOIIndex(article.Title, article.Content.String)
+	if err != nil {
+		return nil, err
+	}
+	result, err := analysis.Detect(algorithm, analysis.SessionSamples(records, session.ID))
+	if err != nil {
+		return nil, err
+	}
+	return analysis.ComputeAOIMetrics(index, result.Fixations), nil
+}
+
+// newSessionSummaryResponse 把数据库中的指标转换为接口响应
+func newSessionSummaryResponse(session *db.ReadingSession, metrics *db.ReadingSessionMetric) *models.SessionSummaryResponse {
+	response := &models.SessionSummaryResponse{
+		SessionID:          metrics.SessionID,
+		ArticleID:          metrics.ArticleID,
+		StartTime:          session.StartTime.Time,
+		EndTime:            session.EndTime.Time,
+		DwellTimeMs:        metrics.DwellTimeMs,
+		ActiveTimeMs:       metrics.ActiveTimeMs,
+		IdleTimeMs:         metrics.IdleTimeMs,
+		GazeSamples:        int(metrics.GazeSamples),
+		ValidSamplePercent: metrics.ValidSamplePercent,
+		MaxScrollDepth:     metrics.MaxScrollDepth,
+		ClickCount:         int(metrics.ClickCount),
+		Algorithm:          metrics.Algorithm,
+		ComputedAt:         metrics.ComputedAt,
+	}
+	if metrics.TokenCount.Valid {
+		value := int(metrics.TokenCount.Int32)
+		response.TokenCount = &value
+	}
+	if metrics.FixatedTokens.Valid {
+		value := int(metrics.FixatedTokens.Int32)
+		response.FixatedTokens = &value
+	}
+	if metrics.FixatedTokenRatio.Valid {
+		response.FixatedTokenRatio = &metrics.FixatedTokenRatio.Float64
+	}
+	if metrics.ReadingSpeed.Valid {
+		response.ReadingSpeed = &metrics.ReadingSpeed.Float64
+	}
+	return response
+}
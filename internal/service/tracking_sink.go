@@ -0,0 +1,309 @@
+package service
+
+import (
+	"NewsEyeTracking/internal/analysis"
+	"NewsEyeTracking/internal/db"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// 追踪数据表，与 migrations/011_create_tracking_tables.sql 一致
+var (
+	eyeSampleColumns = []string{
+		"session_id", "user_id", "batch_time", "batch_seq", "batch_id", "sample_index", "sample_time",
+		"client_t", "client_clock", "sampling_rate", "element_id", "x", "y", "pupil", "valid",
+		"left_x", "left_y", "left_pupil", "left_valid", "right_x", "right_y", "right_pupil", "right_valid",
+	}
+	clickColumns = []string{
+		"session_id", "user_id", "batch_time", "batch_seq", "batch_id", "event_index", "clicked_at", "element_id", "x", "y",
+	}
+	scrollColumns = []string{
+		"session_id", "user_id", "batch_time", "batch_seq", "batch_id", "event_index", "scrolled_at", "delta_y",
+	}
+	trackingTables = []string{"eye_samples", "clicks", "scrolls"}
+)
+
+// NewTrackingSink 按 TRACKING_SINK 创建追踪数据的落地目标
+func NewTrackingSink(database *sql.DB) (storage.TrackingSink, error) {
+	mode, err := storage.SinkMode()
+	if err != nil {
+		return nil, err
+	}
+
+	files := &storage.FileSink{BaseDir: storage.TrackingDir()}
+	switch mode {
+	case storage.SinkPostgres:
+		return NewPostgresTrackingSink(database), nil
+	case storage.SinkBoth:
+		return storage.MultiSink{files, NewPostgresTrackingSink(database)}, nil
+	default:
+		return files, nil
+	}
+}
+
+// PostgresTrackingSink 用 COPY 把追踪记录批量写入按月分区的 eye_samples / clicks / scrolls 表
+// 一个用户一次刷新的全部记录在同一个事务中写入，失败时整体回滚，重试不会产生重复数据
+// 批次附带的 device_info（视口变化）只保存在文件中，从数据库还原的批次没有该字段
+type PostgresTrackingSink struct {
+	db         *sql.DB
+	partitions sync.Map // 已确认存在的分区表名
+}
+
+// NewPostgresTrackingSink 创建 Postgres 落地目标
+func NewPostgresTrackingSink(database *sql.DB) *PostgresTrackingSink {
+	return &PostgresTrackingSink{db: database}
+}
+
+func (s *PostgresTrackingSink) Name() string {
+	return storage.SinkPostgres
+}
+
+func (s *PostgresTrackingSink) WriteTracking(ctx context.Context, date, userID string, records []models.UserTrackingRecord) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return fmt.Errorf("无效的用户ID %s: %w", userID, err)
+	}
+
+	for _, record := range records {
+		if err := s.ensurePartitions(ctx, record.StartTime); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := copyRows(ctx, tx, "eye_samples", eyeSampleColumns, records, func(record models.UserTrackingRecord, emit func(...interface{}) error) error {
+		return eyeSampleRows(uid, record, emit)
+	}); err != nil {
+		return err
+	}
+	if err := copyRows(ctx, tx, "clicks", clickColumns, records, func(record models.UserTrackingRecord, emit func(...interface{}) error) error {
+		for i, click := range record.Data.ClickEvents {
+			if err := emit(record.SessionID, uid, record.StartTime, int64(record.Seq), record.BatchID,
+				i, click.Timestamp, click.ID, click.X, click.Y); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := copyRows(ctx, tx, "scrolls", scrollColumns, records, func(record models.UserTrackingRecord, emit func(...interface{}) error) error {
+		for i, scroll := range record.Data.ScrollEvents {
+			if err := emit(record.SessionID, uid, record.StartTime, int64(record.Seq), record.BatchID,
+				i, scroll.Timestamp, scroll.DeltaY); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交追踪数据失败: %w", err)
+	}
+	return nil
+}
+
+// copyRows 用一条 COPY 语句写入 records 展开后的所有行
+func copyRows(ctx context.Context, tx *sql.Tx, table string, columns []string, records []models.UserTrackingRecord,
+	expand func(record models.UserTrackingRecord, emit func(...interface{}) error) error) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("准备 COPY %s 失败: %w", table, err)
+	}
+	defer stmt.Close()
+
+	emit := func(values ...interface{}) error {
+		_, err := stmt.ExecContext(ctx, values...)
+		return err
+	}
+	for _, record := range records {
+		if err := expand(record, emit); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", table, err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("COPY %s 失败: %w", table, err)
+	}
+	return nil
+}
+
+// eyeSampleRows 展开一个批次的眼动采样，可选字段缺省时写入 NULL，读取时可以原样还原
+func eyeSampleRows(userID uuid.UUID, record models.UserTrackingRecord, emit func(...interface{}) error) error {
+	times := analysis.BatchSampleTimes(record)
+	for i, event := range record.Data.EyeEvents {
+		sampleTime := record.StartTime
+		if i < len(times) {
+			sampleTime = time.UnixMicro(int64(math.Round(times[i] * 1000)))
+		}
+		left, right := eyeColumns(event.Left), eyeColumns(event.Right)
+		if err := emit(record.SessionID, userID, record.StartTime, int64(record.Seq), record.BatchID,
+			i, sampleTime, nullFloat64(event.Timestamp), nullFloat64(record.Data.ClientClock), record.Data.SamplingRate,
+			event.ID, event.X, event.Y, nullFloat32(event.Pupil), nullBool(event.Valid),
+			left[0], left[1], left[2], left[3], right[0], right[1], right[2], right[3]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func eyeColumns(sample *models.EyeSample) [4]interface{} {
+	if sample == nil {
+		return [4]interface{}{nil, nil, nil, nil}
+	}
+	return [4]interface{}{sample.X, sample.Y, nullFloat32(sample.Pupil), nullBool(sample.Valid)}
+}
+
+func nullFloat32(v *float32) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func nullFloat64(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func nullBool(v *bool) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// ensurePartitions 确保批次时间所在月份的分区表存在
+// 分区按 UTC 月份划分，表名形如 eye_samples_y2026m10
+func (s *PostgresTrackingSink) ensurePartitions(ctx context.Context, batchTime time.Time) error {
+	t := batchTime.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	for _, table := range trackingTables {
+		name := fmt.Sprintf("%s_y%04dm%02d", table, from.Year(), from.Month())
+		if _, ok := s.partitions.Load(name); ok {
+			continue
+		}
+		query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+			pq.QuoteIdentifier(name), table, from.Format(time.RFC3339), to.Format(time.RFC3339))
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("创建分区表 %s 失败: %w", name, err)
+		}
+		s.partitions.Store(name, struct{}{})
+	}
+	return nil
+}
+
+// loadSessionRecordsFromDB 从追踪数据表还原某个阅读会话的追踪记录，按批次时间排序
+func loadSessionRecordsFromDB(ctx context.Context, queries *db.Queries, sessionID uuid.UUID) ([]models.UserTrackingRecord, error) {
+	eyeRows, err := queries.ListSessionEyeSamples(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("查询眼动采样失败: %w", err)
+	}
+	clickRows, err := queries.ListSessionClicks(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("查询点击事件失败: %w", err)
+	}
+	scrollRows, err := queries.ListSessionScrolls(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("查询滚动事件失败: %w", err)
+	}
+
+	type batchKey struct {
+		time time.Time
+		seq  int64
+		id   string
+	}
+	var keys []batchKey
+	batches := make(map[batchKey]*models.UserTrackingRecord)
+	batch := func(batchTime time.Time, seq int64, id string) *models.UserTrackingRecord {
+		key := batchKey{batchTime.UTC(), seq, id}
+		record, ok := batches[key]
+		if !ok {
+			record = &models.UserTrackingRecord{SessionID: sessionID, StartTime: batchTime, Seq: uint64(seq), BatchID: id}
+			batches[key] = record
+			keys = append(keys, key)
+		}
+		return record
+	}
+
+	for _, row := range eyeRows {
+		record := batch(row.BatchTime, row.BatchSeq, row.BatchID)
+		record.Data.SamplingRate = row.SamplingRate
+		record.Data.ClientClock = float64Ptr(row.ClientClock)
+		event := models.EyeEvent{
+			ID:        row.ElementID,
+			X:         row.X,
+			Y:         row.Y,
+			Timestamp: float64Ptr(row.ClientT),
+			Pupil:     float32Ptr(row.Pupil),
+			Valid:     boolPtr(row.Valid),
+		}
+		if row.LeftX.Valid && row.LeftY.Valid {
+			event.Left = &models.EyeSample{X: float32(row.LeftX.Float64), Y: float32(row.LeftY.Float64), Pupil: float32Ptr(row.LeftPupil), Valid: boolPtr(row.LeftValid)}
+		}
+		if row.RightX.Valid && row.RightY.Valid {
+			event.Right = &models.EyeSample{X: float32(row.RightX.Float64), Y: float32(row.RightY.Float64), Pupil: float32Ptr(row.RightPupil), Valid: boolPtr(row.RightValid)}
+		}
+		record.Data.EyeEvents = append(record.Data.EyeEvents, event)
+	}
+	for _, row := range clickRows {
+		record := batch(row.BatchTime, row.BatchSeq, row.BatchID)
+		record.Data.ClickEvents = append(record.Data.ClickEvents, models.ClickEvent{Timestamp: row.ClickedAt, ID: row.ElementID, X: row.X, Y: row.Y})
+	}
+	for _, row := range scrollRows {
+		record := batch(row.BatchTime, row.BatchSeq, row.BatchID)
+		record.Data.ScrollEvents = append(record.Data.ScrollEvents, models.ScrollEvent{Timestamp: row.ScrolledAt, DeltaY: row.DeltaY})
+	}
+
+	// 只有点击或滚动的批次排在眼动批次之后插入，按批次时间重新排序
+	records := make([]models.UserTrackingRecord, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, *batches[key])
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartTime.Before(records[j].StartTime)
+	})
+	return records, nil
+}
+
+func float64Ptr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func float32Ptr(v sql.NullFloat64) *float32 {
+	if !v.Valid {
+		return nil
+	}
+	f := float32(v.Float64)
+	return &f
+}
+
+func boolPtr(v sql.NullBool) *bool {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Bool
+}
@@ -10,16 +10,40 @@ import (
 	"time"
 
 	"log"
+
+	"github.com/robfig/cron/v3"
 )
 
+// defaultUploadSchedule 默认每天凌晨0点定时上传
+const defaultUploadSchedule = "0 0 * * *"
+
 // UploadService 上传服务接口
 type UploadService interface {
 	// StartMonitoring 启动文件监控和自动上传
 	StartMonitoring(ctx context.Context) error
-	// CleanupOldData 清理过期数据
-	CleanupOldData(ctx context.Context, daysToKeep int) error
+	// CleanupOldData 按保留策略清理过期数据，dryRun 为 true 时只报告将要删除的内容
+	CleanupOldData(ctx context.Context, policy RetentionPolicy, dryRun bool) (*RetentionReport, error)
 	// ForceUpload 强制上传所有文件
 	ForceUpload(ctx context.Context) error
+	// ListBatches 列出上传批次，state 为空时返回全部
+	ListBatches(ctx context.Context, state string) ([]*utils.UploadBatch, error)
+	// RetryBatch 把失败（死信）的批次重新排队
+	RetryBatch(ctx context.Context, batchID string) (*utils.UploadBatch, error)
+	// StartUpload 立即在后台打包并上传全部文件，返回任务进度
+	StartUpload(ctx context.Context, ignoreQuietHours bool) (*utils.UploadRun, error)
+	// GetUploadStatus 获取上传计划和正在进行（或最近一次）的上传进度
+	GetUploadStatus(ctx context.Context) (*UploadStatus, error)
+}
+
+// UploadStatus 上传计划和进度
+type UploadStatus struct {
+	Run             *utils.UploadRun `json:"run"` // 还没有上传过时为 null
+	Schedule        string           `json:"schedule"`
+	Timezone        string           `json:"timezone"`
+	NextScheduledAt *time.Time       `json:"next_scheduled_at,omitempty"`
+	QuietHours      string           `json:"quiet_hours,omitempty"`
+	InQuietHours    bool             `json:"in_quiet_hours"`
+	BandwidthMax    int64            `json:"bandwidth_max"` // 字节/秒，0 表示不限速
 }
 
 // uploadService 上传服务实现
@@ -27,6 +51,13 @@ type uploadService struct {
 	queries  *db.Queries
 	uploader *utils.FileUploader
 	config   utils.Config
+
+	schedule  string         // cron 表达式
+	location  *time.Location // 定时上传和静默时段所在时区
+	configErr error          // 定时上传配置错误，启动时返回
+
+	cron    *cron.Cron
+	entryID cron.EntryID
 }
 
 // NewUploadService 创建上传服务实例
@@ -35,6 +66,29 @@ func NewUploadService(queries *db.Queries) UploadService {
 	maxFiles, _ := strconv.Atoi(os.Getenv("UPLOAD_MAX_FILES"))
 	maxSize, _ := strconv.ParseInt(os.Getenv("UPLOAD_MAX_SIZE"), 10, 64)
 	checkInterval, _ := time.ParseDuration(os.Getenv("UPLOAD_CHECK_INTERVAL"))
+	// 重试配置可选，未设置时使用上传器的默认值
+	maxAttempts, _ := strconv.Atoi(os.Getenv("UPLOAD_MAX_ATTEMPTS"))
+	retryBase, _ := time.ParseDuration(os.Getenv("UPLOAD_RETRY_BASE"))
+	retryMax, _ := time.ParseDuration(os.Getenv("UPLOAD_RETRY_MAX"))
+	bandwidthMax, _ := strconv.ParseInt(os.Getenv("UPLOAD_BANDWIDTH_MAX"), 10, 64)
+
+	// 定时上传：UPLOAD_SCHEDULE 为标准 5 段 cron 表达式，UPLOAD_TIMEZONE 为 IANA 时区名，
+	// UPLOAD_QUIET_HOURS 为静默时段（HH:MM-HH:MM，逗号分隔），三者都按 UPLOAD_TIMEZONE 解释
+	schedule := os.Getenv("UPLOAD_SCHEDULE")
+	if schedule == "" {
+		schedule = defaultUploadSchedule
+	}
+	location, configErr := utils.LoadLocation(os.Getenv("UPLOAD_TIMEZONE"))
+	if configErr != nil {
+		location = time.Local
+	}
+	quietHours, err := utils.ParseQuietHours(os.Getenv("UPLOAD_QUIET_HOURS"), location)
+	if err != nil && configErr == nil {
+		configErr = err
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil && configErr == nil {
+		configErr = fmt.Errorf("无效的定时上传表达式 %q: %v", schedule, err)
+	}
 
 	config := utils.Config{
 		TrackingDir:   os.Getenv("UPLOAD_TRACKING_DIR"),
@@ -43,6 +97,12 @@ func NewUploadService(queries *db.Queries) UploadService {
 		MaxFiles:      maxFiles,
 		MaxSize:       maxSize,
 		CheckInterval: checkInterval,
+		MaxAttempts:   maxAttempts,
+		RetryBase:     retryBase,
+		RetryMax:      retryMax,
+		DeadLetterDir: os.Getenv("UPLOAD_DEAD_LETTER_DIR"),
+		QuietHours:    quietHours,
+		BandwidthMax:  bandwidthMax,
 	}
 
 	uploader := utils.NewUploader(config)
@@ -51,6 +111,10 @@ func NewUploadService(queries *db.Queries) UploadService {
 		queries:  queries,
 		uploader: uploader,
 		config:   config,
+
+		schedule:  schedule,
+		location:  location,
+		configErr: configErr,
 	}
 }
 
@@ -58,6 +122,10 @@ func NewUploadService(queries *db.Queries) UploadService {
 func (s *uploadService) StartMonitoring(ctx context.Context) error {
 	log.Println("启动文件监控服务...")
 
+	if s.configErr != nil {
+		return s.configErr
+	}
+
 	// 在独立的 goroutine 中运行监控服务
 	go func() {
 		if err := s.uploader.Start(ctx); err != nil {
@@ -65,67 +133,133 @@ func (s *uploadService) StartMonitoring(ctx context.Context) error {
 		}
 	}()
 
-	// 启动每日定时上传
-	go s.startDailyUpload(ctx)
+	// 启动定时上传
+	s.startUploadSchedule(ctx)
+
+	// 启动每日数据保留清理
+	go s.startDailyCleanup(ctx)
 
 	return nil
 }
 
-// CleanupOldData 清理过期数据
-func (s *uploadService) CleanupOldData(ctx context.Context, daysToKeep int) error {
-	log.Printf("清理 %d 天前的数据...", daysToKeep)
+// CleanupOldData 按保留策略清理过期数据
+// 本地文件按修改时间判断，数据文件只删除上传台账记录为已上传且之后没有变化的，仍在写入或仍在上传队列中的文件不会被删除；
+// 数据库中只删除已结束的阅读会话和非活跃的用户会话
+func (s *uploadService) CleanupOldData(ctx context.Context, policy RetentionPolicy, dryRun bool) (*RetentionReport, error) {
+	now := time.Now()
+	report := &RetentionReport{DryRun: dryRun, StartedAt: now}
+	if dryRun {
+		log.Println("开始试运行数据保留清理（不会删除任何数据）...")
+	} else {
+		log.Println("开始数据保留清理...")
+	}
+
+	config := s.uploader.Config()
+	err := s.uploader.WithClaimedFiles(func(claimed map[string]bool) error {
+		uploaded, err := utils.UploadedFiles(utils.UploadLedgerPath())
+		if err != nil {
+			return fmt.Errorf("读取上传台账失败: %w", err)
+		}
+		skipData := func(path string) bool {
+			return claimed[path] || isOpenDataFile(path) || !isUploadedFile(path, uploaded)
+		}
+		report.addFileRule(RetentionTrackingFiles, policy.TrackingFiles, now, config.TrackingDir, true, dryRun, skipData)
+		report.addFileRule(RetentionNewsFiles, policy.NewsFiles, now, config.NewsDir, true, dryRun, skipData)
+		// 临时目录下只有遗留的压缩包，队列和死信目录中的批次由上传队列自己管理
+		report.addFileRule(RetentionTempFiles, policy.TempFiles, now, config.UploadDir, false, dryRun, nil)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("检查本地数据文件失败: %w", err)
+	}
+
+	report.addRowRule(RetentionReadingSessions, policy.ReadingSessions, now, dryRun,
+		func(cutoff time.Time) (int64, error) { return s.queries.CountEndedSessionsBefore(ctx, cutoff) },
+		func(cutoff time.Time) (int64, error) { return s.queries.DeleteEndedSessionsBefore(ctx, cutoff) })
+	report.addRowRule(RetentionUserSessions, policy.UserSessions, now, dryRun,
+		func(cutoff time.Time) (int64, error) { return s.queries.CountInactiveUserSessionsBefore(ctx, cutoff) },
+		func(cutoff time.Time) (int64, error) { return s.queries.DeleteInactiveUserSessionsBefore(ctx, cutoff) })
 
-	//这里需要删除一些比较旧的日志记录
+	report.addFileRule(RetentionLogs, policy.Logs, now, logDir, false, dryRun, isActiveLogFile)
 
-	return nil
+	report.FinishedAt = time.Now()
+	for _, rule := range report.Rules {
+		if rule.Error != "" {
+			log.Printf("数据保留清理 %s 出错: %s", rule.Rule, rule.Error)
+		}
+		log.Printf("数据保留清理 %s: 超期 %d，已删除 %d，跳过 %d", rule.Rule, rule.Matched, rule.Deleted, rule.Skipped)
+	}
+	return report, nil
 }
 
-// startDailyUpload 启动每日定时上传任务
-func (s *uploadService) startDailyUpload(ctx context.Context) {
-	log.Println("启动每日定时上传任务...")
+// startDailyCleanup 每天在 RETENTION_HOUR 点（默认凌晨3点）按环境变量中的保留策略清理一次
+// RETENTION_DRY_RUN=true 时只记录将要删除的内容
+func (s *uploadService) startDailyCleanup(ctx context.Context) {
+	hour, err := strconv.Atoi(os.Getenv("RETENTION_HOUR"))
+	if err != nil || hour < 0 || hour > 23 {
+		hour = 3
+	}
+	dryRun := os.Getenv("RETENTION_DRY_RUN") == "true"
 
-	// 计算下一次凌晨12点的时间
-	nextUploadTime := s.calculateNextMidnight()
-	log.Printf("下一次定时上传时间: %s", nextUploadTime.Format("2006-01-02 15:04:05"))
+	next := nextDailyRun(time.Now(), hour)
+	log.Printf("下一次数据保留清理时间: %s", next.Format("2006-01-02 15:04:05"))
 
-	timer := time.NewTimer(time.Until(nextUploadTime))
+	timer := time.NewTimer(time.Until(next))
 	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("停止每日定时上传任务")
+			log.Println("停止每日数据保留清理任务")
 			return
 		case <-timer.C:
-			log.Println("开始执行每日定时上传...")
-
-			// 执行强制上传
-			if err := s.ForceUpload(ctx); err != nil {
-				log.Printf("每日定时上传失败: %v", err)
-			} else {
-				log.Println("每日定时上传完成")
+			if _, err := s.CleanupOldData(ctx, RetentionPolicyFromEnv(), dryRun); err != nil {
+				log.Printf("每日数据保留清理失败: %v", err)
 			}
 
-			// 设置下一次上传时间（24小时后）
-			nextUploadTime = s.calculateNextMidnight()
-			log.Printf("下一次定时上传时间: %s", nextUploadTime.Format("2006-01-02 15:04:05"))
-			timer.Reset(time.Until(nextUploadTime))
+			next = nextDailyRun(time.Now(), hour)
+			log.Printf("下一次数据保留清理时间: %s", next.Format("2006-01-02 15:04:05"))
+			timer.Reset(time.Until(next))
 		}
 	}
 }
 
-// calculateNextMidnight 计算下一次凌晨12点的时间
-func (s *uploadService) calculateNextMidnight() time.Time {
-	now := time.Now()
-	// 获取今天的凌晨12点
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+// nextDailyRun 计算下一次在 hour 点执行的时间
+func nextDailyRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
 
-	// 如果现在已经过了今天的凌晨12点，就设置为明天的凌晨12点
-	if now.After(today) {
-		return today.Add(24 * time.Hour)
+// startUploadSchedule 按 cron 表达式定时强制上传，上一次还没结束时跳过本次
+func (s *uploadService) startUploadSchedule(ctx context.Context) {
+	s.cron = cron.New(
+		cron.WithLocation(s.location),
+		cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)),
+	)
+	s.entryID, _ = s.cron.AddFunc(s.schedule, func() {
+		log.Println("开始执行定时上传...")
+		if err := s.ForceUpload(ctx); err != nil {
+			log.Printf("定时上传失败: %v", err)
+		} else {
+			log.Println("定时上传完成")
+		}
+	})
+	s.cron.Start()
+
+	next := s.cron.Entry(s.entryID).Next
+	log.Printf("定时上传计划: %s（%s），下一次: %s", s.schedule, s.location, next.Format("2006-01-02 15:04:05"))
+	if quiet := s.config.QuietHours.String(); quiet != "" {
+		log.Printf("上传静默时段: %s", quiet)
 	}
 
-	return today
+	go func() {
+		<-ctx.Done()
+		s.cron.Stop()
+		log.Println("停止定时上传任务")
+	}()
 }
 
 // ForceUpload 强制上传所有文件
@@ -149,3 +283,43 @@ func (s *uploadService) ForceUpload(ctx context.Context) error {
 	log.Println("强制上传完成")
 	return nil
 }
+
+// ListBatches 列出上传批次
+func (s *uploadService) ListBatches(ctx context.Context, state string) ([]*utils.UploadBatch, error) {
+	batches, err := s.uploader.ListBatches(state)
+	if err != nil {
+		return nil, fmt.Errorf("读取上传队列失败: %w", err)
+	}
+	return batches, nil
+}
+
+// RetryBatch 重新排队失败的批次，上传在后台进行
+func (s *uploadService) RetryBatch(ctx context.Context, batchID string) (*utils.UploadBatch, error) {
+	return s.uploader.RetryBatch(batchID)
+}
+
+// StartUpload 立即在后台上传，静默时段内需要 ignoreQuietHours 才会上传
+func (s *uploadService) StartUpload(ctx context.Context, ignoreQuietHours bool) (*utils.UploadRun, error) {
+	if !ignoreQuietHours && s.uploader.InQuietHours() {
+		return nil, ErrQuietHours
+	}
+	return s.uploader.StartUpload(ignoreQuietHours)
+}
+
+// GetUploadStatus 获取上传计划和进度
+func (s *uploadService) GetUploadStatus(ctx context.Context) (*UploadStatus, error) {
+	status := &UploadStatus{
+		Run:          s.uploader.Progress(),
+		Schedule:     s.schedule,
+		Timezone:     s.location.String(),
+		QuietHours:   s.config.QuietHours.String(),
+		InQuietHours: s.uploader.InQuietHours(),
+		BandwidthMax: s.config.BandwidthMax,
+	}
+	if s.cron != nil {
+		if next := s.cron.Entry(s.entryID).Next; !next.IsZero() {
+			status.NextScheduledAt = &next
+		}
+	}
+	return status, nil
+}
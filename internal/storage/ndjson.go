@@ -0,0 +1,300 @@
+package storage
+
+// 追踪/新闻数据的行分隔 JSON（NDJSON）文件格式
+// 第一行为文件头，记录数据类型和格式版本；之后每行一条记录，只追加不改写。
+// 旧格式（版本 1）是整个文件一个 UserTrackingBatchRecord / UserNewsBatchRecord，
+// 可使用 cmd/convert-tracking 一次性转换。
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// NDJSONExt 新格式文件扩展名
+	NDJSONExt = ".ndjson"
+	// LegacyJSONExt 旧格式文件扩展名
+	LegacyJSONExt = ".json"
+
+	SchemaTracking   = "tracking"
+	SchemaNews       = "news"
+	SchemaNewsClicks = "news_clicks"
+
+	// TrackingSchemaVersion 追踪数据格式版本，1 为旧的整文件 JSON，
+	// 3 起眼动采样可带逐点时间戳、瞳孔、有效性和左右眼数据（字段均可选，向下兼容），
+	// 4 起批次可带视口变化后的 device_info
+	TrackingSchemaVersion = 4
+	// NewsSchemaVersion 新闻浏览记录格式版本，1 为旧的整文件 JSON，
+	// 3 起记录带列表页会话ID和逐条展示（展示ID、位置、推荐分数）
+	NewsSchemaVersion = 3
+	// NewsClicksSchemaVersion 新闻点击记录格式版本，2 起记录带点击ID
+	NewsClicksSchemaVersion = 2
+
+	maxLineSize = 64 << 20
+)
+
+// FileHeader NDJSON 文件头（文件第一行）
+type FileHeader struct {
+	Schema    string    `json:"schema"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TrackingHeader 追踪数据文件头
+func TrackingHeader() FileHeader {
+	return FileHeader{Schema: SchemaTracking, Version: TrackingSchemaVersion, CreatedAt: time.Now()}
+}
+
+// NewsHeader 新闻浏览记录文件头
+func NewsHeader() FileHeader {
+	return FileHeader{Schema: SchemaNews, Version: NewsSchemaVersion, CreatedAt: time.Now()}
+}
+
+// NewsClicksHeader 新闻点击记录文件头
+func NewsClicksHeader() FileHeader {
+	return FileHeader{Schema: SchemaNewsClicks, Version: NewsClicksSchemaVersion, CreatedAt: time.Now()}
+}
+
+// TrackingDir 追踪数据根目录，与上传服务使用同一个环境变量
+func TrackingDir() string {
+	if dir := os.Getenv("UPLOAD_TRACKING_DIR"); dir != "" {
+		return dir
+	}
+	return "data/tracking"
+}
+
+// NewsDir 新闻浏览记录根目录
+func NewsDir() string {
+	if dir := os.Getenv("UPLOAD_NEWS_DIR"); dir != "" {
+		return dir
+	}
+	return "data/news"
+}
+
+// UserFilePath 某用户在某天正在写入的数据文件路径，形如 <baseDir>/<date>/<userID>.open.ndjson
+// 读取时使用 UserFiles，它同时包含已经封存的文件
+func UserFilePath(baseDir, date, userID string) string {
+	return filepath.Join(baseDir, date, userID+OpenSuffix)
+}
+
+// AppendRecords 以 O_APPEND 方式向 NDJSON 文件追加记录，文件不存在时先写入文件头
+// 所有记录编码后一次写入并 fsync，不会读取或改写已有内容
+func AppendRecords[T any](path string, header FileHeader, records []T) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %w", err)
+	}
+
+	// 与封存互斥，保证封存后的文件不会再被追加
+	unlock := lockPath(path)
+	defer unlock()
+
+	var buf bytes.Buffer
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	switch {
+	case err == nil:
+		// 新文件，先写文件头
+		if err := writeLine(&buf, header); err != nil {
+			file.Close()
+			return err
+		}
+	case os.IsExist(err):
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("打开数据文件失败: %w", err)
+		}
+		size, torn, err := fileTail(path)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if size == 0 {
+			// 创建后未来得及写入文件头就崩溃的空文件
+			if err := writeLine(&buf, header); err != nil {
+				file.Close()
+				return err
+			}
+		} else if torn {
+			// 上次写入若在行中间崩溃，先补一个换行，避免新记录与残缺行粘连
+			buf.WriteByte('\n')
+		}
+	default:
+		return fmt.Errorf("创建数据文件失败: %w", err)
+	}
+	defer file.Close()
+
+	for i := range records {
+		if err := writeLine(&buf, records[i]); err != nil {
+			return err
+		}
+	}
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("写入数据文件失败: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("数据文件落盘失败: %w", err)
+	}
+
+	return nil
+}
+
+// Reader 逐行惰性读取 NDJSON 文件中的记录
+//
+//	for r.Next() { record := r.Record() }
+//	if err := r.Err(); err != nil { ... }
+type Reader[T any] struct {
+	scanner *bufio.Scanner
+	header  FileHeader
+	current T
+	line    int
+	err     error
+}
+
+// NewReader 创建读取器并读取文件头
+func NewReader[T any](r io.Reader) (*Reader[T], error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	reader := &Reader[T]{scanner: scanner}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("读取文件头失败: %w", err)
+		}
+		return nil, fmt.Errorf("文件为空，缺少文件头")
+	}
+	reader.line = 1
+
+	if err := json.Unmarshal(scanner.Bytes(), &reader.header); err != nil || reader.header.Schema == "" {
+		return nil, fmt.Errorf("文件头格式不正确，可能是旧格式文件")
+	}
+
+	return reader, nil
+}
+
+// Header 返回文件头
+func (r *Reader[T]) Header() FileHeader {
+	return r.header
+}
+
+// Next 读取下一条记录，没有更多记录或出错时返回 false
+// 无法解析的行会被记录日志并跳过：崩溃留下的残缺行在 AppendRecords 补上换行后可能位于文件中间，
+// 不能因此让之后的记录都无法读取
+func (r *Reader[T]) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var record T
+		if err := json.Unmarshal(line, &record); err != nil {
+			log.Printf("忽略无法解析的记录: 第%d行解析失败: %v", r.line, err)
+			continue
+		}
+
+		r.current = record
+		return true
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		r.err = fmt.Errorf("读取数据文件失败: %w", err)
+	}
+	return false
+}
+
+// Record 返回当前记录
+func (r *Reader[T]) Record() T {
+	return r.current
+}
+
+// Err 返回读取过程中的错误
+func (r *Reader[T]) Err() error {
+	return r.err
+}
+
+// ReadFile 打开 NDJSON 文件并依次回调每条记录
+func ReadFile[T any](path string, fn func(header FileHeader, record T) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := NewReader[T](file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for reader.Next() {
+		if err := fn(reader.Header(), reader.Record()); err != nil {
+			return err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadHeader 只读取 NDJSON 文件的文件头
+func ReadHeader(path string) (FileHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileHeader{}, err
+	}
+	defer file.Close()
+
+	reader, err := NewReader[json.RawMessage](file)
+	if err != nil {
+		return FileHeader{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return reader.Header(), nil
+}
+
+func writeLine(buf *bytes.Buffer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("序列化记录失败: %w", err)
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// fileTail 返回文件大小以及最后一个字节是否不是换行符
+func fileTail(path string) (int64, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false, fmt.Errorf("打开数据文件失败: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, false, fmt.Errorf("读取数据文件信息失败: %w", err)
+	}
+	if info.Size() == 0 {
+		return 0, false, nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return 0, false, fmt.Errorf("读取数据文件失败: %w", err)
+	}
+	return info.Size(), last[0] != '\n', nil
+}
@@ -0,0 +1,121 @@
+package storage
+
+// 数据文件的封存协议
+// 刷新任务只向“打开”的文件 <name>.open.ndjson 追加；会话结束或跨天后把它重命名为封存文件
+// <name>.sealed-<Unix 毫秒>.ndjson，封存后的文件不再被写入，上传器只打包封存文件。
+// 重命名与追加使用同一把路径锁，封存完成后到达的数据会写入新的打开文件。
+// 转换工具生成的 <name>.ndjson 和旧格式 <name>.json 同样视为封存文件。
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OpenSuffix 正在写入的文件后缀
+	OpenSuffix = ".open" + NDJSONExt
+	// sealedMarker 封存文件名中时间戳前的标记
+	sealedMarker = ".sealed-"
+)
+
+// pathLocks 按路径哈希分片的锁，串行化同一文件的追加和封存
+var pathLocks [64]sync.Mutex
+
+func lockPath(path string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(filepath.Clean(path)))
+	mu := &pathLocks[h.Sum32()%uint32(len(pathLocks))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// IsOpenFile 文件是否仍在写入
+func IsOpenFile(path string) bool {
+	return strings.HasSuffix(path, OpenSuffix)
+}
+
+// Seal 把打开的文件重命名为封存文件，返回封存后的路径；文件不存在时返回空字符串
+func Seal(openPath string) (string, error) {
+	if !IsOpenFile(openPath) {
+		return "", fmt.Errorf("%s 不是打开的数据文件", openPath)
+	}
+
+	unlock := lockPath(openPath)
+	defer unlock()
+
+	if _, err := os.Stat(openPath); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	base := strings.TrimSuffix(openPath, OpenSuffix)
+	ms := time.Now().UnixMilli()
+	var sealedPath string
+	for {
+		sealedPath = base + sealedMarker + strconv.FormatInt(ms, 10) + NDJSONExt
+		if _, err := os.Stat(sealedPath); os.IsNotExist(err) {
+			break
+		}
+		ms++
+	}
+
+	if err := os.Rename(openPath, sealedPath); err != nil {
+		return "", fmt.Errorf("封存数据文件失败: %w", err)
+	}
+	if err := syncDir(filepath.Dir(sealedPath)); err != nil {
+		return "", err
+	}
+	return sealedPath, nil
+}
+
+// SealStaleFiles 封存 baseDir 下日期早于 today 的目录中所有打开的文件，返回封存的文件数
+func SealStaleFiles(baseDir, today string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(baseDir, "*", "*"+OpenSuffix))
+	if err != nil {
+		return 0, err
+	}
+
+	sealed := 0
+	for _, path := range paths {
+		if date := filepath.Base(filepath.Dir(path)); date >= today {
+			continue
+		}
+		if _, err := Seal(path); err != nil {
+			return sealed, err
+		}
+		sealed++
+	}
+	return sealed, nil
+}
+
+// UserFiles 某用户在某天的全部 NDJSON 数据文件，按写入先后排序：
+// 转换生成的 <name>.ndjson、按时间排序的封存文件、最后是打开的文件
+// name 为用户ID，派生数据为 <userID>.<kind>
+func UserFiles(baseDir, date, name string) ([]string, error) {
+	dir := filepath.Join(baseDir, date)
+
+	var files []string
+	if _, err := os.Stat(filepath.Join(dir, name+NDJSONExt)); err == nil {
+		files = append(files, filepath.Join(dir, name+NDJSONExt))
+	}
+
+	sealed, err := filepath.Glob(filepath.Join(dir, name+sealedMarker+"*"+NDJSONExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(sealed)
+	files = append(files, sealed...)
+
+	if _, err := os.Stat(filepath.Join(dir, name+OpenSuffix)); err == nil {
+		files = append(files, filepath.Join(dir, name+OpenSuffix))
+	}
+	return files, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+// 追踪数据和新闻浏览记录的落地目标
+// 刷新任务把缓存中的追踪记录交给 TrackingSink 落地。默认写入按用户、按日期划分的 NDJSON 文件；
+// TRACKING_SINK=postgres 时写入 Postgres 分区表，both 时两者都写（文件为主）。
+// 新闻浏览记录和点击交给 NewsSink，默认写入 Postgres 的 news_impressions 和 news_clicks 表（推荐服务需要读取），
+// NEWS_SINK=both 时同时写入文件作为镜像，file 时只写文件。
+import (
+	"NewsEyeTracking/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// 落地方式
+const (
+	SinkFile     = "file"
+	SinkPostgres = "postgres"
+	SinkBoth     = "both"
+)
+
+// TrackingSink 追踪数据的落地目标
+type TrackingSink interface {
+	// Name 名称，用于日志
+	Name() string
+	// WriteTracking 写入某个用户在 date 这一天刷新的追踪记录，返回错误时这批记录会在下次刷新时重试
+	WriteTracking(ctx context.Context, date, userID string, records []models.UserTrackingRecord) error
+}
+
+// SinkMode 当前配置的落地方式（TRACKING_SINK），未设置时为 file
+func SinkMode() (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("TRACKING_SINK")))
+	switch mode {
+	case "":
+		return SinkFile, nil
+	case SinkFile, SinkPostgres, SinkBoth:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("不支持的追踪数据落地方式: %s（可选 file、postgres、both）", mode)
+	}
+}
+
+// NewsSink 新闻浏览记录的落地目标
+type NewsSink interface {
+	// Name 名称，用于日志
+	Name() string
+	// WriteNews 写入某个用户在 date 这一天刷新的浏览记录，返回错误时这批记录会在下次刷新时重试
+	WriteNews(ctx context.Context, date, userID string, records []models.UserNewsRecord) error
+	// WriteClicks 写入某个用户在 date 这一天打开新闻详情的点击，返回错误时这批点击会在下次刷新时重试
+	WriteClicks(ctx context.Context, date, userID string, clicks []models.NewsClickRecord) error
+}
+
+// NewsSinkMode 当前配置的新闻浏览记录落地方式（NEWS_SINK），未设置时为 postgres
+func NewsSinkMode() (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("NEWS_SINK")))
+	switch mode {
+	case "":
+		return SinkPostgres, nil
+	case SinkFile, SinkPostgres, SinkBoth:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("不支持的新闻浏览记录落地方式: %s（可选 file、postgres、both）", mode)
+	}
+}
+
+// FileSink 写入 <baseDir>/<date>/<userID>.open.ndjson
+type FileSink struct {
+	BaseDir string
+}
+
+func (s *FileSink) Name() string {
+	return SinkFile
+}
+
+func (s *FileSink) WriteTracking(ctx context.Context, date, userID string, records []models.UserTrackingRecord) error {
+	return AppendRecords(UserFilePath(s.BaseDir, date, userID), TrackingHeader(), records)
+}
+
+func (s *FileSink) WriteNews(ctx context.Context, date, userID string, records []models.UserNewsRecord) error {
+	return AppendRecords(UserFilePath(s.BaseDir, date, userID), NewsHeader(), records)
+}
+
+// WriteClicks 点击写入同目录下的 <userID>.news_clicks 文件
+func (s *FileSink) WriteClicks(ctx context.Context, date, userID string, clicks []models.NewsClickRecord) error {
+	return AppendRecords(DerivedFilePath(s.BaseDir, date, userID, SchemaNewsClicks), NewsClicksHeader(), clicks)
+}
+
+// MultiSink 依次写入多个落地目标
+// 第一个为主目标，写入失败时返回错误，整批记录下次重试；其余目标写入失败只记录日志，
+// 否则重试时会在主目标中重复写入
+type MultiSink []TrackingSink
+
+func (m MultiSink) Name() string {
+	names := make([]string, len(m))
+	for i, sink := range m {
+		names[i] = sink.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (m MultiSink) WriteTracking(ctx context.Context, date, userID string, records []models.UserTrackingRecord) error {
+	for i, sink := range m {
+		err := sink.WriteTracking(ctx, date, userID, records)
+		if err == nil {
+			continue
+		}
+		if i == 0 {
+			return err
+		}
+		log.Printf("警告: 用户%s的追踪数据写入 %s 失败（已写入 %s）: %v", userID, sink.Name(), m[0].Name(), err)
+	}
+	return nil
+}
+
+// MultiNewsSink 依次写入多个新闻浏览记录落地目标，与 MultiSink 相同，第一个为主目标
+type MultiNewsSink []NewsSink
+
+func (m MultiNewsSink) Name() string {
+	names := make([]string, len(m))
+	for i, sink := range m {
+		names[i] = sink.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (m MultiNewsSink) WriteNews(ctx context.Context, date, userID string, records []models.UserNewsRecord) error {
+	for i, sink := range m {
+		err := sink.WriteNews(ctx, date, userID, records)
+		if err == nil {
+			continue
+		}
+		if i == 0 {
+			return err
+		}
+		log.Printf("警告: 用户%s的新闻浏览记录写入 %s 失败（已写入 %s）: %v", userID, sink.Name(), m[0].Name(), err)
+	}
+	return nil
+}
+
+func (m MultiNewsSink) WriteClicks(ctx context.Context, date, userID string, clicks []models.NewsClickRecord) error {
+	for i, sink := range m {
+		err := sink.WriteClicks(ctx, date, userID, clicks)
+		if err == nil {
+			continue
+		}
+		if i == 0 {
+			return err
+		}
+		log.Printf("警告: 用户%s的新闻点击记录写入 %s 失败（已写入 %s）: %v", userID, sink.Name(), m[0].Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+// 追踪数据文件的定位与按会话读取
+import (
+	"NewsEyeTracking/internal/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SchemaFixations = "fixations"
+	// FixationsSchemaVersion 注视检测结果文件格式版本
+	FixationsSchemaVersion = 1
+
+	SchemaDocument = "document"
+	// DocumentSchemaVersion 文档坐标文件格式版本
+	DocumentSchemaVersion = 1
+)
+
+// FixationsHeader 注视检测结果文件头
+func FixationsHeader() FileHeader {
+	return FileHeader{Schema: SchemaFixations, Version: FixationsSchemaVersion, CreatedAt: time.Now()}
+}
+
+// DocumentHeader 文档坐标文件头
+func DocumentHeader() FileHeader {
+	return FileHeader{Schema: SchemaDocument, Version: DocumentSchemaVersion, CreatedAt: time.Now()}
+}
+
+// DerivedFilePath 正在写入的派生数据文件路径，与原始数据放在同一日期目录，形如 <baseDir>/<date>/<userID>.<kind>.open.ndjson
+func DerivedFilePath(baseDir, date, userID, kind string) string {
+	return filepath.Join(baseDir, date, userID+"."+kind+OpenSuffix)
+}
+
+// DatesBetween 返回 from 到 to（含）之间的本地日期字符串
+// 刷新任务按写入时的本地日期建目录，所以额外包含 to 的后一天以覆盖跨午夜的刷新
+func DatesBetween(from, to time.Time) []string {
+	from, to = from.Local(), to.Local()
+	if to.Before(from) {
+		to = from
+	}
+
+	var dates []string
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	for !day.After(last) {
+		dates = append(dates, day.Format("2006-01-02"))
+		day = day.AddDate(0, 0, 1)
+	}
+	return dates
+}
+
+// LoadSessionRecords 从追踪文件中读取某个阅读会话的全部记录（按文件中的顺序）
+// 同时兼容尚未转换的旧格式 JSON 文件
+func LoadSessionRecords(baseDir, userID string, sessionID uuid.UUID, from, to time.Time) ([]models.UserTrackingRecord, error) {
+	var records []models.UserTrackingRecord
+
+	keep := func(record models.UserTrackingRecord) {
+		if record.SessionID == sessionID {
+			records = append(records, record)
+		}
+	}
+
+	for _, date := range DatesBetween(from, to) {
+		if err := ReadUserRecords(baseDir, date, userID, LegacyTrackingRecords, keep); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// ReadUserRecords 按写入顺序读取某用户某天的全部记录：先读旧格式 JSON 文件（由 legacy 解析，可以为 nil），
+// 再读 UserFiles 列出的 NDJSON 文件
+func ReadUserRecords[T any](baseDir, date, userID string, legacy func(data []byte) ([]T, error), fn func(T)) error {
+	if legacy != nil {
+		legacyPath := filepath.Join(baseDir, date, userID+LegacyJSONExt)
+		data, err := os.ReadFile(legacyPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("读取旧格式文件失败: %w", err)
+		}
+		if err == nil {
+			records, err := legacy(data)
+			if err != nil {
+				return fmt.Errorf("解析旧格式文件 %s 失败: %w", legacyPath, err)
+			}
+			for _, record := range records {
+				fn(record)
+			}
+		}
+	}
+
+	// 读取期间打开的文件可能被封存（重命名），此时重新列出文件，补读新出现的封存文件
+	read := make(map[string]bool)
+	for attempt := 0; attempt < 3; attempt++ {
+		paths, err := UserFiles(baseDir, date, userID)
+		if err != nil {
+			return err
+		}
+
+		renamed := false
+		for _, path := range paths {
+			if read[path] {
+				continue
+			}
+			err := ReadFile(path, func(_ FileHeader, record T) error {
+				fn(record)
+				return nil
+			})
+			if os.IsNotExist(err) {
+				renamed = true
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			read[path] = true
+		}
+		if !renamed {
+			break
+		}
+	}
+	return nil
+}
+
+// DateUsers 某天目录下有数据文件的用户ID（文件名中第一个 "." 之前的部分），按字典序排列
+func DateUsers(baseDir, date string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(baseDir, date))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var users []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		if i := strings.Index(name, "."); i > 0 {
+			name = name[:i]
+		}
+		if _, err := uuid.Parse(name); err != nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		users = append(users, name)
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// LegacyTrackingRecords 解析旧格式的整文件追踪数据
+func LegacyTrackingRecords(data []byte) ([]models.UserTrackingRecord, error) {
+	var batch models.UserTrackingBatchRecord
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, err
+	}
+	return batch.Records, nil
+}
+
+// LegacyNewsRecords 解析旧格式的整文件新闻浏览记录
+func LegacyNewsRecords(data []byte) ([]models.UserNewsRecord, error) {
+	var batch models.UserNewsBatchRecord
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, err
+	}
+	return batch.Records, nil
+}
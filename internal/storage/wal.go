@@ -0,0 +1,344 @@
+package storage
+
+// 追踪数据的预写日志（WAL）
+// 每条追踪记录在返回 success 之前先追加到按天、按分片划分的段文件并 fsync，
+// 定时刷新成功写入 data/tracking 之后再删除对应的段文件；
+// 进程崩溃后启动时重放残留的段文件，补齐尚未落地的数据。
+import (
+	"NewsEyeTracking/internal/models"
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	walSegmentExt    = ".seg"
+	walFrameHeader   = 8        // 4 字节长度 + 4 字节 CRC32C
+	walMaxEntrySize  = 64 << 20 // 单条记录上限，超过视为损坏
+	walDefaultShards = 8
+)
+
+var walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WALEntry 预写日志中的一条记录
+type WALEntry struct {
+	Date   string                    `json:"date"` // 写入时的日期，重放时决定落到哪个日期目录
+	UserID string                    `json:"user_id"`
+	Record models.UserTrackingRecord `json:"record"`
+}
+
+// WAL 按天、按分片组织的追加写日志
+type WAL struct {
+	dir    string
+	shards []*walShard
+	now    func() time.Time
+}
+
+type walShard struct {
+	mu     sync.Mutex
+	index  int
+	date   string
+	path   string
+	file   *os.File
+	closed []string // 跨天时关闭的旧段，等待下一次 Rotate 返回
+}
+
+// OpenWAL 打开（或创建）预写日志目录, shards <= 0 时使用默认分片数
+func OpenWAL(dir string, shards int) (*WAL, error) {
+	if shards <= 0 {
+		shards = walDefaultShards
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建预写日志目录失败: %w", err)
+	}
+
+	w := &WAL{dir: dir, shards: make([]*walShard, shards), now: time.Now}
+	for i := range w.shards {
+		w.shards[i] = &walShard{index: i}
+	}
+	return w, nil
+}
+
+// Append 追加一条追踪记录并 fsync，返回 nil 即表示数据已持久化
+func (w *WAL) Append(userID string, record *models.UserTrackingRecord) error {
+	entry := WALEntry{
+		Date:   w.now().Format("2006-01-02"),
+		UserID: userID,
+		Record: *record,
+	}
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化预写日志记录失败: %w", err)
+	}
+
+	frame := make([]byte, walFrameHeader+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, walCRCTable))
+	copy(frame[walFrameHeader:], payload)
+
+	shard := w.shardFor(userID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if err := w.ensureSegment(shard, entry.Date); err != nil {
+		return err
+	}
+
+	if _, err := shard.file.Write(frame); err != nil {
+		return fmt.Errorf("写入预写日志失败: %w", err)
+	}
+	if err := shard.file.Sync(); err != nil {
+		return fmt.Errorf("预写日志落盘失败: %w", err)
+	}
+
+	return nil
+}
+
+// Rotate 关闭所有分片当前的段文件并返回它们的路径（包括跨天时已关闭的旧段）
+// 之后的写入会进入新的段文件，调用方在数据落地后通过 Remove 删除返回的段
+func (w *WAL) Rotate() ([]string, error) {
+	var segments []string
+	var firstErr error
+
+	for _, shard := range w.shards {
+		shard.mu.Lock()
+		segments = append(segments, shard.closed...)
+		shard.closed = nil
+		if shard.file != nil {
+			if err := shard.file.Close(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("关闭预写日志段失败: %w", err)
+			}
+			segments = append(segments, shard.path)
+			shard.file = nil
+			shard.path = ""
+			shard.date = ""
+		}
+		shard.mu.Unlock()
+	}
+
+	return segments, firstErr
+}
+
+// Remove 删除已经落地的段文件
+func (w *WAL) Remove(segments []string) error {
+	var firstErr error
+	for _, segment := range segments {
+		if err := os.Remove(segment); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = fmt.Errorf("删除预写日志段 %s 失败: %w", segment, err)
+		}
+	}
+	w.removeEmptyDateDirs()
+	return firstErr
+}
+
+// Replay 重放目录中残留的段文件，apply 成功后删除该段
+// 应在开始接收新数据之前调用；段尾部因崩溃产生的残缺记录会被忽略
+// 刷新失败时旧段可能被保留，同一批次会同时出现在新旧段中，带批次ID的记录只重放一次
+func (w *WAL) Replay(apply func(entries []WALEntry) error) (int, error) {
+	segments, err := w.listSegments()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	seen := make(map[string]bool)
+	for _, segment := range segments {
+		entries, err := readSegment(segment)
+		if err != nil {
+			log.Printf("读取预写日志段 %s 失败: %v", segment, err)
+			continue
+		}
+		entries = dedupeEntries(entries, seen)
+
+		if len(entries) > 0 {
+			if err := apply(entries); err != nil {
+				// 保留该段，下次启动时再尝试
+				log.Printf("重放预写日志段 %s 失败: %v", segment, err)
+				continue
+			}
+		}
+
+		if err := os.Remove(segment); err != nil {
+			log.Printf("删除已重放的预写日志段 %s 失败: %v", segment, err)
+		}
+		replayed += len(entries)
+	}
+
+	w.removeEmptyDateDirs()
+	return replayed, nil
+}
+
+// dedupeEntries 去掉批次ID已经出现过的记录，没有批次ID的旧客户端记录无法判断，全部保留
+func dedupeEntries(entries []WALEntry, seen map[string]bool) []WALEntry {
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Record.BatchID != "" {
+			key := entry.UserID + "/" + entry.Record.BatchID
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// SetClock 替换决定段文件日期的时钟，用于检查程序模拟跨天；应在写入之前调用
+func (w *WAL) SetClock(now func() time.Time) {
+	w.now = now
+}
+
+// Close 关闭所有打开的段文件（不删除，未刷新的数据在下次启动时重放）
+func (w *WAL) Close() error {
+	_, err := w.Rotate()
+	return err
+}
+
+// shardFor 根据用户ID选择分片，同一用户的记录始终落在同一分片以保持顺序
+func (w *WAL) shardFor(userID string) *walShard {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return w.shards[h.Sum32()%uint32(len(w.shards))]
+}
+
+// ensureSegment 确保分片有当天的段文件，跨天时自动切换
+func (w *WAL) ensureSegment(shard *walShard, date string) error {
+	if shard.file != nil && shard.date == date {
+		return nil
+	}
+
+	if shard.file != nil {
+		// 跨天：旧段保持关闭状态，等待下一次刷新时由 Rotate 一并返回
+		if err := shard.file.Close(); err != nil {
+			log.Printf("关闭预写日志段 %s 失败: %v", shard.path, err)
+		}
+		shard.closed = append(shard.closed, shard.path)
+		shard.file = nil
+		shard.path = ""
+	}
+
+	dateDir := filepath.Join(w.dir, date)
+	if err := os.MkdirAll(dateDir, 0755); err != nil {
+		return fmt.Errorf("创建预写日志日期目录失败: %w", err)
+	}
+
+	path := filepath.Join(dateDir, fmt.Sprintf("shard-%02d-%d%s", shard.index, time.Now().UnixNano(), walSegmentExt))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("创建预写日志段失败: %w", err)
+	}
+
+	// 同步目录项，保证新建的段文件本身在崩溃后可见
+	if err := syncDir(dateDir); err != nil {
+		file.Close()
+		return err
+	}
+
+	shard.file = file
+	shard.path = path
+	shard.date = date
+	return nil
+}
+
+// listSegments 列出所有段文件，按日期和创建时间排序
+func (w *WAL) listSegments() ([]string, error) {
+	segments, err := filepath.Glob(filepath.Join(w.dir, "*", "*"+walSegmentExt))
+	if err != nil {
+		return nil, fmt.Errorf("列出预写日志段失败: %w", err)
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// removeEmptyDateDirs 清理已经没有段文件的日期目录
+func (w *WAL) removeEmptyDateDirs() {
+	dirs, err := os.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+	today := w.now().Format("2006-01-02")
+	for _, d := range dirs {
+		if !d.IsDir() || d.Name() == today {
+			continue
+		}
+		// 目录非空时 os.Remove 会失败，直接忽略即可
+		os.Remove(filepath.Join(w.dir, d.Name()))
+	}
+}
+
+// readSegment 读取段文件中的全部完整记录
+func readSegment(path string) ([]WALEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, walFrameHeader)
+	var entries []WALEntry
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return entries, nil
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				log.Printf("预写日志段 %s 尾部存在残缺记录，已忽略", path)
+				return entries, nil
+			}
+			return entries, err
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+		if size == 0 || size > walMaxEntrySize {
+			log.Printf("预写日志段 %s 记录长度异常(%d)，停止读取", path, size)
+			return entries, nil
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			log.Printf("预写日志段 %s 尾部存在残缺记录，已忽略", path)
+			return entries, nil
+		}
+
+		if crc32.Checksum(payload, walCRCTable) != checksum {
+			log.Printf("预写日志段 %s 记录校验失败，停止读取", path)
+			return entries, nil
+		}
+
+		var entry WALEntry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			log.Printf("预写日志段 %s 记录解析失败: %v", path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// syncDir 对目录执行 fsync
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("打开目录失败: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("目录落盘失败: %w", err)
+	}
+	return nil
+}
@@ -5,49 +5,112 @@ package utils
 import (
 	"archive/zip"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc64"
 	"io"
 
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
-	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
-	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss/credentials"
+	"NewsEyeTracking/internal/blobstore"
+	"NewsEyeTracking/internal/envelope"
+	"NewsEyeTracking/internal/storage"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/joho/godotenv"
+	"golang.org/x/time/rate"
 )
 
 // Config 上传器配置
 type Config struct {
-	TrackingDir   string        // 眼动追踪数据目录
-	NewsDir       string        // 新闻数据目录
-	UploadDir     string        // 临时上传目录
-	MaxFiles      int           // 触发上传的最大文件数
-	MaxSize       int64         // 触发上传的最大总大小(字节)
-	CheckInterval time.Duration // 检查间隔
+	TrackingDir   string              // 眼动追踪数据目录
+	NewsDir       string              // 新闻数据目录
+	UploadDir     string              // 临时上传目录
+	MaxFiles      int                 // 触发上传的最大文件数
+	MaxSize       int64               // 触发上传的最大总大小(字节)
+	CheckInterval time.Duration       // 检查间隔
+	Store         blobstore.BlobStore // 对象存储，为空时按 STORAGE_BACKEND 环境变量创建
+	Keys          envelope.KeyWrapper // 归档加密主密钥，为空时按 ARCHIVE_MASTER_KEY 环境变量创建
+	MaxAttempts   int                 // 单个批次的最大上传次数，超过后移到死信目录
+	RetryBase     time.Duration       // 第一次重试前的等待时间，之后每次翻倍
+	RetryMax      time.Duration       // 重试等待时间上限
+	DeadLetterDir string              // 死信目录，默认为 <UploadDir>/dead-letter
+	QuietHours    QuietHours          // 静默时段，时段内只打包不上传
+	BandwidthMax  int64               // 上传带宽上限(字节/秒)，0 表示不限速
 }
 
 // FileUploader 文件上传器
 type FileUploader struct {
-	config    Config
-	ossClient *oss.Client
-	watcher   *fsnotify.Watcher
+	config  Config
+	store   blobstore.BlobStore
+	keys    envelope.KeyWrapper // 归档加密的主密钥，为空时上传明文压缩包
+	keysSet bool
+	watcher *fsnotify.Watcher
+	queue   *UploadQueue
+	cycleMu sync.Mutex // 打包和上传不并发执行（定时检查、定时上传、管理员触发和重试）
+	limiter *rate.Limiter
+	runs    runTracker
+
+	ctxMu sync.Mutex
+	ctx   context.Context // Start 传入的服务上下文，停止服务时取消正在进行的上传
+}
+
+// queueRun 一次处理上传队列的参数
+type queueRun struct {
+	trigger          string
+	force            bool // 忽略重试等待时间
+	ignoreQuietHours bool
 }
 
 // NewUploader 创建新的上传器
 func NewUploader(config Config) *FileUploader {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.RetryBase <= 0 {
+		config.RetryBase = time.Minute
+	}
+	if config.RetryMax <= 0 {
+		config.RetryMax = time.Hour
+	}
+	if config.DeadLetterDir == "" {
+		config.DeadLetterDir = filepath.Join(config.UploadDir, "dead-letter")
+	}
+
 	return &FileUploader{
-		config: config,
+		config:  config,
+		store:   config.Store,
+		keys:    config.Keys,
+		queue:   NewUploadQueue(filepath.Join(config.UploadDir, "queue"), config.DeadLetterDir),
+		limiter: blobstore.NewLimiter(config.BandwidthMax),
 	}
 }
 
 // Start 启动上传器
 func (u *FileUploader) Start(ctx context.Context) error {
+	u.ctxMu.Lock()
+	u.ctx = ctx
+	u.ctxMu.Unlock()
+
+	if err := u.initStore(); err != nil {
+		return fmt.Errorf("初始化对象存储失败: %v", err)
+	}
 
-	if err := u.initOSSClient(); err != nil {
-		return fmt.Errorf("初始化OSS客户端失败: %v", err)
+	if err := u.initEncryption(); err != nil {
+		return fmt.Errorf("初始化归档加密失败: %v", err)
+	}
+
+	if err := u.queue.Recover(u.config.UploadDir); err != nil {
+		return fmt.Errorf("恢复上传队列失败: %v", err)
 	}
 
 	// 创建文件监控器
@@ -86,24 +149,50 @@ func (u *FileUploader) Start(ctx context.Context) error {
 	}
 }
 
-// initOSSClient 初始化OSS客户端
-func (u *FileUploader) initOSSClient() error {
+// initStore 初始化对象存储，已经初始化时直接返回
+func (u *FileUploader) initStore() error {
+	if u.store != nil {
+		return nil
+	}
+
 	// 加载环境变量
 	if err := godotenv.Load(); err != nil {
 		log.Println("未找到 .env 文件，使用系统环境变量")
 	}
 
-	accessKeyId := os.Getenv("ACCESS_ID")
-	accessKeySecret := os.Getenv("ACCESS_KEY")
-	if accessKeyId == "" || accessKeySecret == "" {
-		return fmt.Errorf("请设置 ACCESS_ID 和 ACCESS_KEY 环境变量")
+	store, err := blobstore.FromEnv()
+	if err != nil {
+		return err
 	}
+	u.store = store
+	log.Printf("对象存储后端: %s", store.Backend())
 
-	region := os.Getenv("OSS_REGION")
-	provider := credentials.NewStaticCredentialsProvider(accessKeyId, accessKeySecret)
-	cfg := oss.LoadDefaultConfig().WithCredentialsProvider(provider).WithRegion(region)
-	u.ossClient = oss.NewClient(cfg)
+	return nil
+}
+
+// initEncryption 加载归档加密的主密钥，只加载一次
+// 没有配置主密钥时上传明文压缩包，ARCHIVE_ENCRYPTION=required 时报错
+func (u *FileUploader) initEncryption() error {
+	if u.keys != nil || u.keysSet {
+		return nil
+	}
+
+	keys, err := envelope.FromEnv()
+	if errors.Is(err, envelope.ErrNoMasterKey) {
+		if envelope.Required() {
+			return err
+		}
+		log.Println("警告: 未配置 ARCHIVE_MASTER_KEY，归档将以明文上传")
+		u.keysSet = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
+	u.keys = keys
+	u.keysSet = true
+	log.Printf("归档加密已启用，主密钥: %s", keys.KeyID())
 	return nil
 }
 
@@ -116,14 +205,22 @@ func (u *FileUploader) handleFileEvent(event fsnotify.Event) {
 
 // checkAndUpload 检查并上传文件
 func (u *FileUploader) checkAndUpload() {
-	// 分别扫描 tracking 和 news 目录
+	u.cycleMu.Lock()
+	defer u.cycleMu.Unlock()
+
+	u.sealStaleFiles()
+
+	// 分别扫描 tracking 和 news 目录，达到条件的打包成新批次
 	u.checkAndUploadDirectory(u.config.TrackingDir, "tracking")
 	u.checkAndUploadDirectory(u.config.NewsDir, "news")
+
+	// 上传到期的批次（新批次和等待重试的批次）
+	u.processQueue(queueRun{trigger: TriggerThreshold})
 }
 
-// checkAndUploadDirectory 检查并上传指定目录
+// checkAndUploadDirectory 检查指定目录，达到上传条件时创建上传批次
 func (u *FileUploader) checkAndUploadDirectory(dir, dirType string) {
-	files, totalSize, err := u.scanDirectory(dir)
+	files, totalSize, err := u.scanUnclaimed(dir)
 	if err != nil {
 		log.Printf("扫描%s目录失败: %v", dirType, err)
 		return
@@ -136,8 +233,47 @@ func (u *FileUploader) checkAndUploadDirectory(dir, dirType string) {
 	// 检查是否达到上传条件
 	if len(files) >= u.config.MaxFiles || totalSize >= u.config.MaxSize {
 		log.Printf("%s达到上传条件 - 文件数: %d, 总大小: %d bytes", dirType, len(files), totalSize)
-		if err := u.uploadDirectoryFiles(files, dir, dirType); err != nil {
-			log.Printf("上传%s文件失败: %v", dirType, err)
+		if _, err := u.createBatch(files, dir, dirType); err != nil {
+			log.Printf("创建%s上传批次失败: %v", dirType, err)
+		}
+	}
+}
+
+// scanUnclaimed 扫描目录，跳过已经在队列中（包括死信）的文件
+func (u *FileUploader) scanUnclaimed(dir string) ([]string, int64, error) {
+	files, _, err := u.scanDirectory(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	claimed, err := u.queue.ClaimedFiles()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var unclaimed []string
+	var totalSize int64
+	for _, file := range files {
+		if claimed[file] {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			unclaimed = append(unclaimed, file)
+			totalSize += info.Size()
+		}
+	}
+	return unclaimed, totalSize, nil
+}
+
+// sealStaleFiles 封存前一天及更早仍处于打开状态的文件（跨天后不会再有数据写入）
+func (u *FileUploader) sealStaleFiles() {
+	today := time.Now().Format("2006-01-02")
+	for _, dir := range []string{u.config.TrackingDir, u.config.NewsDir} {
+		sealed, err := storage.SealStaleFiles(dir, today)
+		if err != nil {
+			log.Printf("封存%s中的过期文件失败: %v", dir, err)
+		}
+		if sealed > 0 {
+			log.Printf("已封存%s中的 %d 个过期文件", dir, sealed)
 		}
 	}
 }
@@ -149,6 +285,10 @@ func (u *FileUploader) scanDirectory(dir string) ([]string, int64, error) {
 
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
+			// 扫描过程中文件可能被封存改名，这里跳过即可，改名后的文件下一轮再处理
+			if os.IsNotExist(err) {
+				return nil
+			}
 			return err
 		}
 
@@ -157,8 +297,18 @@ func (u *FileUploader) scanDirectory(dir string) ([]string, int64, error) {
 			return nil
 		}
 
-		// 可以在这里添加文件过滤逻辑
-		// 例如：只处理特定扩展名的文件
+		// 只处理数据文件：新的 NDJSON 格式和尚未转换的旧 JSON 格式
+		switch filepath.Ext(path) {
+		case ".ndjson", ".json":
+		default:
+			return nil
+		}
+
+		// 仍在写入的文件等封存后再上传
+		if storage.IsOpenFile(path) {
+			return nil
+		}
+
 		files = append(files, path)
 		totalSize += info.Size()
 		return nil
@@ -167,79 +317,319 @@ func (u *FileUploader) scanDirectory(dir string) ([]string, int64, error) {
 	return files, totalSize, err
 }
 
-// uploadDirectoryFiles 上传指定目录的文件
-func (u *FileUploader) uploadDirectoryFiles(files []string, baseDir, dirType string) error {
-	if len(files) == 0 {
+// createBatch 把文件打包成一个上传批次：压缩包和清单只生成一次，之后的重试都使用同一个压缩包
+// 配置了主密钥时压缩包先做信封加密，磁盘上和对象存储中都不保留明文压缩包
+func (u *FileUploader) createBatch(files []string, baseDir, dirType string) (batch *UploadBatch, err error) {
+	if err := u.initEncryption(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	batchID, err := u.queue.NewBatchID(dirType, now)
+	if err != nil {
+		return nil, fmt.Errorf("创建上传队列目录失败: %v", err)
+	}
+	zipPath := u.queue.ZipPath(batchID)
+	archivePath := zipPath
+	manifestPath := u.queue.ManifestPath(batchID)
+	defer func() {
+		if err != nil {
+			os.Remove(zipPath)
+			os.Remove(archivePath)
+			os.Remove(manifestPath)
+		}
+	}()
+
+	entries, err := u.createZipFileWithBaseDir(files, zipPath, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("创建压缩文件失败: %v", err)
+	}
+
+	// 生成清单
+	manifest := &Manifest{
+		Version:   ManifestVersion,
+		BatchID:   batchID,
+		Type:      dirType,
+		CreatedAt: now,
+		Backend:   blobstore.Backend(),
+		Files:     entries,
+	}
+	if u.store != nil {
+		manifest.Backend = u.store.Backend()
+	}
+	manifest.summarize()
+
+	if u.keys != nil {
+		plain, err := archiveDigest(zipPath)
+		if err != nil {
+			return nil, fmt.Errorf("计算压缩文件校验和失败: %v", err)
+		}
+		archivePath = zipPath + envelope.FileExt
+		header, err := envelope.EncryptFile(zipPath, archivePath, u.keys)
+		if err != nil {
+			return nil, fmt.Errorf("加密压缩文件失败: %v", err)
+		}
+		os.Remove(zipPath)
+
+		manifest.Encryption = &ManifestEncryption{
+			Algorithm:       header.Algorithm,
+			KeyID:           header.KeyID,
+			PlaintextSize:   plain.Size,
+			PlaintextSHA256: plain.SHA256,
+		}
+	}
+
+	if manifest.Archive, err = archiveDigest(archivePath); err != nil {
+		return nil, fmt.Errorf("计算压缩文件校验和失败: %v", err)
+	}
+	manifest.Archive.Key = fmt.Sprintf("%s/%s", dirType, filepath.Base(archivePath))
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = os.WriteFile(manifestPath, manifestData, 0644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("写入清单失败: %v", err)
+	}
+
+	batch = &UploadBatch{
+		ID:            batchID,
+		Type:          dirType,
+		State:         BatchStatePending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		ObjectKey:     manifest.Archive.Key,
+		ManifestKey:   fmt.Sprintf("%s/%s%s", dirType, batchID, ManifestSuffix),
+		FileCount:     len(entries),
+		Size:          manifest.Archive.Size,
+		ArchiveName:   filepath.Base(archivePath),
+		Encrypted:     manifest.Encryption != nil,
+	}
+	for _, entry := range entries {
+		batch.Sources = append(batch.Sources, SourceFile{Path: entry.localPath, Size: entry.Size})
+	}
+	if err := u.queue.Save(batch); err != nil {
+		return nil, fmt.Errorf("保存批次状态失败: %v", err)
+	}
+
+	log.Printf("创建上传批次 %s - 文件数: %d, 压缩包 %d bytes, 加密: %t", batchID, len(entries), batch.Size, batch.Encrypted)
+	return batch, nil
+}
+
+// processQueue 上传到期的批次，静默时段内不上传（管理员指定忽略时除外）
+func (u *FileUploader) processQueue(run queueRun) error {
+	batches, err := u.queue.List(BatchStatePending)
+	if err != nil {
+		log.Printf("读取上传队列失败: %v", err)
+		return err
+	}
+
+	var due []*UploadBatch
+	now := time.Now()
+	for _, batch := range batches {
+		if run.force || !batch.NextAttemptAt.After(now) {
+			due = append(due, batch)
+		}
+	}
+	if len(due) == 0 {
+		u.queue.PruneUploaded()
+		return nil
+	}
+	if !run.ignoreQuietHours && u.InQuietHours() {
+		log.Printf("处于静默时段 %s，%d 个批次推迟上传", u.config.QuietHours, len(due))
+		u.runs.update(func(r *UploadRun) { r.BatchesDeferred += len(due) })
 		return nil
 	}
 
-	// 创建压缩文件
-	timestamp := time.Now().Format("20060102_150405")
-	zipFileName := fmt.Sprintf("%s_batch_%s.zip", dirType, timestamp)
-	zipPath := filepath.Join(u.config.UploadDir, zipFileName)
+	owned := u.runs.begin(run.trigger)
+	u.runs.update(func(r *UploadRun) { r.BatchesTotal += len(due) })
 
-	if err := u.createZipFileWithBaseDir(files, zipPath, baseDir); err != nil {
-		return fmt.Errorf("创建压缩文件失败: %v", err)
+	var failed int
+	for i, batch := range due {
+		// 上传过程中进入静默时段时，剩余批次留到静默时段结束后
+		if !run.ignoreQuietHours && u.InQuietHours() {
+			log.Printf("处于静默时段 %s，%d 个批次推迟上传", u.config.QuietHours, len(due)-i)
+			u.runs.update(func(r *UploadRun) { r.BatchesDeferred += len(due) - i })
+			break
+		}
+
+		u.runs.update(func(r *UploadRun) { r.CurrentBatch = batch.ID })
+		if err := u.processBatch(batch); err != nil {
+			log.Printf("上传批次 %s 失败（第 %d 次）: %v", batch.ID, batch.Attempts, err)
+			failed++
+			u.runs.update(func(r *UploadRun) { r.BatchesFailed++ })
+		} else {
+			u.runs.update(func(r *UploadRun) { r.BatchesDone++ })
+		}
 	}
+	u.queue.PruneUploaded()
 
-	// 上传到OSS的对应文件夹
-	ossObjectName := fmt.Sprintf("%s/%s", dirType, zipFileName)
-	if err := u.uploadToOSS(zipPath, ossObjectName); err != nil {
-		return fmt.Errorf("上传到OSS失败: %v", err)
+	if failed > 0 {
+		err = fmt.Errorf("%d 个批次上传失败", failed)
+	}
+	if owned {
+		u.runs.finish(err)
+	}
+	return err
+}
+
+// processBatch 上传一个批次并更新状态：成功后清理原始文件，失败后按指数退避安排重试，
+// 超过最大次数移到死信目录
+func (u *FileUploader) processBatch(batch *UploadBatch) error {
+	batch.State = BatchStateUploading
+	batch.Attempts++
+	if err := u.queue.Save(batch); err != nil {
+		return fmt.Errorf("保存批次状态失败: %v", err)
+	}
+
+	deleted, uploadErr := u.uploadBatch(batch)
+	if uploadErr == nil {
+		if err := u.queue.Complete(batch); err != nil {
+			log.Printf("保存批次 %s 状态失败: %v", batch.ID, err)
+		}
+		log.Printf("成功上传 %s 类型 %d 个文件（批次 %s，删除 %d 个）", batch.Type, batch.FileCount, batch.ID, deleted)
+		return nil
+	}
+
+	batch.LastError = uploadErr.Error()
+	if batch.Attempts >= u.config.MaxAttempts {
+		if err := u.queue.DeadLetter(batch); err != nil {
+			log.Printf("移动批次 %s 到死信目录失败: %v", batch.ID, err)
+		} else {
+			log.Printf("批次 %s 已尝试 %d 次，移到死信目录 %s", batch.ID, batch.Attempts, u.config.DeadLetterDir)
+		}
+		return uploadErr
+	}
+
+	batch.State = BatchStatePending
+	batch.NextAttemptAt = time.Now().Add(retryDelay(batch.Attempts, u.config.RetryBase, u.config.RetryMax))
+	if err := u.queue.Save(batch); err != nil {
+		log.Printf("保存批次 %s 状态失败: %v", batch.ID, err)
+	}
+	return uploadErr
+}
+
+// uploadBatch 上传压缩包和清单，先校验远端对象再删除原始文件，结果记录到上传台账
+// 返回删除的原始文件数
+func (u *FileUploader) uploadBatch(batch *UploadBatch) (int, error) {
+	manifestData, err := os.ReadFile(u.queue.ManifestPath(batch.ID))
+	if err != nil {
+		return 0, fmt.Errorf("读取清单失败: %v", err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(manifestData, manifest); err != nil {
+		return 0, fmt.Errorf("解析清单失败: %v", err)
+	}
+
+	// 上传到对象存储的对应文件夹，清单与压缩包放在一起
+	if err := u.uploadToStore(u.queue.ArchivePath(batch), batch.ObjectKey); err != nil {
+		return 0, fmt.Errorf("上传到对象存储失败: %v", err)
+	}
+	if err := u.uploadToStore(u.queue.ManifestPath(batch.ID), batch.ManifestKey); err != nil {
+		return 0, fmt.Errorf("上传清单失败: %v", err)
+	}
+
+	// 校验远端对象，不通过时保留原始文件，等待重试
+	entry := manifest.ledgerEntry(batch.ManifestKey)
+	entry.Backend = u.store.Backend()
+	info, err := u.verifyUpload(manifest, batch.ManifestKey, manifestData)
+	if info != nil {
+		entry.ETag, entry.CRC64 = info.ETag, info.CRC64
+	}
+	if err != nil {
+		entry.Status, entry.Reason = LedgerStatusVerificationFailed, err.Error()
+		u.appendLedger(entry)
+		return 0, fmt.Errorf("上传校验失败，保留本地文件: %v", err)
 	}
 
 	// 清理文件
-	if err := u.cleanupFiles(files); err != nil {
-		log.Printf("清理文件失败: %v", err)
+	entry.Status = LedgerStatusVerified
+	entry.Sources = batch.Sources
+	entry.Deleted = u.cleanupFiles(batch.Sources)
+	u.appendLedger(entry)
+	return entry.Deleted, nil
+}
+
+// verifyUpload 查询压缩包和清单的元信息并与本地校验和比较，返回压缩包的元信息
+func (u *FileUploader) verifyUpload(manifest *Manifest, manifestKey string, manifestData []byte) (*blobstore.ObjectInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	info, err := u.store.Stat(ctx, manifest.Archive.Key)
+	if err != nil {
+		return nil, fmt.Errorf("查询压缩包元信息失败: %v", err)
+	}
+	if err := verifyObject(info, manifest.Archive.Size, manifest.Archive.MD5, manifest.Archive.CRC64); err != nil {
+		return info, err
 	}
 
-	// 删除临时压缩文件
-	if err := os.Remove(zipPath); err != nil {
-		log.Printf("删除临时压缩文件失败: %v", err)
+	manifestInfo, err := u.store.Stat(ctx, manifestKey)
+	if err != nil {
+		return info, fmt.Errorf("查询清单元信息失败: %v", err)
+	}
+	sum := md5.Sum(manifestData)
+	crc := crc64.Checksum(manifestData, crc64.MakeTable(crc64.ECMA))
+	if err := verifyObject(manifestInfo, int64(len(manifestData)), hex.EncodeToString(sum[:]), strconv.FormatUint(crc, 10)); err != nil {
+		return info, err
 	}
 
-	log.Printf("成功上传 %s 类型 %d 个文件", dirType, len(files))
-	return nil
+	return info, nil
+}
+
+// appendLedger 记录上传台账，写入失败只记录日志
+func (u *FileUploader) appendLedger(entry LedgerEntry) {
+	if err := AppendLedger(UploadLedgerPath(), entry); err != nil {
+		log.Printf("写入上传台账失败: %v", err)
+	}
 }
 
-// createZipFileWithBaseDir 创建带基础目录的压缩文件
-func (u *FileUploader) createZipFileWithBaseDir(files []string, zipPath, baseDir string) error {
+// createZipFileWithBaseDir 创建带基础目录的压缩文件，返回每个文件的大小和 SHA-256
+func (u *FileUploader) createZipFileWithBaseDir(files []string, zipPath, baseDir string) ([]ManifestFile, error) {
 	zipFile, err := os.Create(zipPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer zipFile.Close()
 
 	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
+	entries := make([]ManifestFile, 0, len(files))
 	for _, file := range files {
-		if err := u.addFileToZipWithBaseDir(zipWriter, file, baseDir); err != nil {
-			return err
+		entry, err := u.addFileToZipWithBaseDir(zipWriter, file, baseDir)
+		if err != nil {
+			zipWriter.Close()
+			return nil, err
 		}
+		entries = append(entries, entry)
 	}
 
-	return nil
+	// 中央目录在 Close 时写入，必须检查错误
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := zipFile.Sync(); err != nil {
+		return nil, err
+	}
+	return entries, nil
 }
 
-// addFileToZipWithBaseDir 将文件添加到压缩包（保持目录结构）
-func (u *FileUploader) addFileToZipWithBaseDir(zipWriter *zip.Writer, filename, baseDir string) error {
+// addFileToZipWithBaseDir 将文件添加到压缩包（保持目录结构），同时计算写入内容的 SHA-256
+func (u *FileUploader) addFileToZipWithBaseDir(zipWriter *zip.Writer, filename, baseDir string) (ManifestFile, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return err
+		return ManifestFile{}, err
 	}
 	defer file.Close()
 
 	// 获取文件信息
 	info, err := file.Stat()
 	if err != nil {
-		return err
+		return ManifestFile{}, err
 	}
 
 	// 创建zip文件头
 	header, err := zip.FileInfoHeader(info)
 	if err != nil {
-		return err
+		return ManifestFile{}, err
 	}
 
 	// 设置文件名（使用相对路径，保持目录结构）
@@ -247,75 +637,170 @@ func (u *FileUploader) addFileToZipWithBaseDir(zipWriter *zip.Writer, filename,
 	if err != nil {
 		relPath = filepath.Base(filename)
 	}
-	header.Name = relPath
+	header.Name = filepath.ToSlash(relPath)
 	header.Method = zip.Deflate
 
 	// 创建写入器
 	writer, err := zipWriter.CreateHeader(header)
 	if err != nil {
-		return err
+		return ManifestFile{}, err
 	}
 
-	// 复制文件内容
-	_, err = io.Copy(writer, file)
-	return err
+	// 复制文件内容；文件可能仍在追加，清单以实际写入压缩包的内容为准
+	entry := newManifestFile(filename, relPath)
+	sha := sha256.New()
+	entry.Size, err = io.Copy(io.MultiWriter(writer, sha), file)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	entry.SHA256 = hexSum(sha)
+	return entry, nil
 }
 
-// uploadToOSS 上传到阿里云OSS
-func (u *FileUploader) uploadToOSS(filePath, objectName string) error {
-	bucketName := os.Getenv("OSS_BUCKET_NAME")
+// uploadToStore 上传到对象存储
+func (u *FileUploader) uploadToStore(filePath, objectName string) error {
+	if err := u.initStore(); err != nil {
+		return err
+	}
 
-	putRequest := &oss.PutObjectRequest{
-		Bucket: oss.Ptr(bucketName),
-		Key:    oss.Ptr(objectName),
-		ProgressFn: func(increment, transferred, total int64) {
-			progress := float64(transferred) / float64(total) * 100
-			log.Printf("上传进度: %.2f%%", progress)
+	u.runs.update(func(r *UploadRun) {
+		r.CurrentObject, r.BytesTransferred, r.BytesTotal = objectName, 0, 0
+	})
+	// 上传期间持有 cycleMu，卡住的请求会阻塞之后所有的上传和清理，必须有超时
+	ctx, cancel := context.WithTimeout(u.serviceContext(), u.uploadTimeout(filePath))
+	defer cancel()
+	ctx = blobstore.WithTransfer(ctx, blobstore.Transfer{
+		Limiter: u.limiter,
+		Progress: func(transferred, total int64) {
+			u.runs.update(func(r *UploadRun) { r.BytesTransferred, r.BytesTotal = transferred, total })
 		},
-	}
+	})
 
-	result, err := u.ossClient.PutObjectFromFile(context.TODO(), putRequest, filePath)
+	result, err := u.store.Put(ctx, objectName, filePath)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("文件上传成功: %s -> %s", filePath, *result.ETag)
+	log.Printf("文件上传成功: %s -> %s:%s (ETag %s)", filePath, u.store.Backend(), objectName, result.ETag)
 	return nil
 }
 
-// cleanupFiles 清理已处理的文件
-func (u *FileUploader) cleanupFiles(files []string) error {
-	for _, file := range files {
-		if err := os.Remove(file); err != nil {
-			log.Printf("删除文件失败 %s: %v", file, err)
+// serviceContext 服务上下文，Start 之前（如命令行工具直接上传）为 Background
+func (u *FileUploader) serviceContext() context.Context {
+	u.ctxMu.Lock()
+	defer u.ctxMu.Unlock()
+	if u.ctx == nil {
+		return context.Background()
+	}
+	return u.ctx
+}
+
+// uploadTimeout 按文件大小估算上传超时：限速时按限速的一半计算，否则按最低 256KB/s 计算，另加 1 分钟
+func (u *FileUploader) uploadTimeout(filePath string) time.Duration {
+	const minRate = 256 << 10
+	var size int64
+	if info, err := os.Stat(filePath); err == nil {
+		size = info.Size()
+	}
+	bytesPerSecond := int64(minRate)
+	if u.config.BandwidthMax > 0 {
+		bytesPerSecond = max(u.config.BandwidthMax/2, 1)
+	}
+	return time.Minute + time.Duration(size/bytesPerSecond)*time.Second
+}
+
+// cleanupFiles 清理已上传的文件，返回删除的文件数
+// 压缩后又被追加过的文件不删除，留到下一个批次
+func (u *FileUploader) cleanupFiles(sources []SourceFile) int {
+	deleted := 0
+	for _, source := range sources {
+		info, err := os.Stat(source.Path)
+		if err != nil {
+			log.Printf("检查文件失败 %s: %v", source.Path, err)
+			continue
+		}
+		if info.Size() != source.Size {
+			log.Printf("文件 %s 在压缩后发生变化（%d -> %d bytes），暂不删除", source.Path, source.Size, info.Size())
+			continue
+		}
+		if err := os.Remove(source.Path); err != nil {
+			log.Printf("删除文件失败 %s: %v", source.Path, err)
+			continue
 		}
+		deleted++
 	}
-	return nil
+	return deleted
 }
 
-// ForceUploadAll 强制上传所有文件，不检查阈值条件
+// ForceUploadAll 强制上传所有文件，不检查阈值条件和重试等待时间，由定时任务调用
+// 静默时段内只打包，批次留在队列中
 func (u *FileUploader) ForceUploadAll() error {
+	u.cycleMu.Lock()
+	defer u.cycleMu.Unlock()
+
+	return u.forceUploadAll(queueRun{trigger: TriggerSchedule, force: true})
+}
+
+// StartUpload 管理员手动触发上传，在后台打包并上传全部文件，立即返回任务进度
+// 已有任务在进行时返回 ErrUploadInProgress 和该任务的进度
+func (u *FileUploader) StartUpload(ignoreQuietHours bool) (*UploadRun, error) {
+	if !u.cycleMu.TryLock() {
+		return u.runs.snapshot(), ErrUploadInProgress
+	}
+	u.runs.begin(TriggerManual)
+	run := u.runs.snapshot()
+
+	go func() {
+		defer u.cycleMu.Unlock()
+		err := u.forceUploadAll(queueRun{trigger: TriggerManual, force: true, ignoreQuietHours: ignoreQuietHours})
+		u.runs.finish(err)
+	}()
+	return run, nil
+}
+
+// forceUploadAll 调用方需持有 cycleMu
+func (u *FileUploader) forceUploadAll(run queueRun) error {
 	log.Println("开始强制上传所有文件...")
 
-	// 强制上传 tracking 目录
+	u.sealStaleFiles()
+
+	// 分别强制打包 tracking 和 news 目录，一个目录失败不影响另一个目录和已经入队的批次
+	var firstErr error
 	if err := u.forceUploadDirectory(u.config.TrackingDir, "tracking"); err != nil {
 		log.Printf("强制上传tracking目录失败: %v", err)
-		return err
+		firstErr = err
 	}
-
-	// 强制上传 news 目录
 	if err := u.forceUploadDirectory(u.config.NewsDir, "news"); err != nil {
 		log.Printf("强制上传news目录失败: %v", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if err := u.processQueue(run); err != nil {
 		return err
 	}
+	if firstErr != nil {
+		return firstErr
+	}
 
 	log.Println("强制上传完成")
 	return nil
 }
 
-// forceUploadDirectory 强制上传指定目录的所有文件
+// InQuietHours 当前是否处于静默时段
+func (u *FileUploader) InQuietHours() bool {
+	return u.config.QuietHours.Contains(time.Now())
+}
+
+// Progress 正在进行或最近一次上传任务的进度，还没有上传过时返回 nil
+func (u *FileUploader) Progress() *UploadRun {
+	return u.runs.snapshot()
+}
+
+// forceUploadDirectory 把指定目录中尚未入队的文件全部打包成一个批次
 func (u *FileUploader) forceUploadDirectory(dir, dirType string) error {
-	files, _, err := u.scanDirectory(dir)
+	files, _, err := u.scanUnclaimed(dir)
 	if err != nil {
 		return fmt.Errorf("扫描%s目录失败: %v", dirType, err)
 	}
@@ -326,7 +811,51 @@ func (u *FileUploader) forceUploadDirectory(dir, dirType string) error {
 	}
 
 	log.Printf("强制上传%s目录 - 文件数: %d", dirType, len(files))
-	return u.uploadDirectoryFiles(files, dir, dirType)
+	_, err = u.createBatch(files, dir, dirType)
+	return err
+}
+
+// ListBatches 列出上传批次，state 为空时返回全部
+func (u *FileUploader) ListBatches(state string) ([]*UploadBatch, error) {
+	return u.queue.List(state)
+}
+
+// RetryBatch 把死信目录中的批次重新排队并在后台立即上传
+func (u *FileUploader) RetryBatch(id string) (*UploadBatch, error) {
+	batch, err := u.queue.Requeue(id)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("批次 %s 已重新排队", id)
+
+	go func() {
+		u.cycleMu.Lock()
+		defer u.cycleMu.Unlock()
+		if err := u.initStore(); err != nil {
+			log.Printf("初始化对象存储失败: %v", err)
+			return
+		}
+		u.processQueue(queueRun{trigger: TriggerRetry})
+	}()
+	return batch, nil
+}
+
+// WithClaimedFiles 在不与打包、上传并发的情况下执行 fn，fn 收到尚未完成的批次占用的原始文件
+// 数据保留清理通过它删除本地文件，避免删掉正在打包的文件
+func (u *FileUploader) WithClaimedFiles(fn func(claimed map[string]bool) error) error {
+	u.cycleMu.Lock()
+	defer u.cycleMu.Unlock()
+
+	claimed, err := u.queue.ClaimedFiles()
+	if err != nil {
+		return err
+	}
+	return fn(claimed)
+}
+
+// Config 返回补全默认值后的上传器配置
+func (u *FileUploader) Config() Config {
+	return u.config
 }
 
 // GetStats 获取统计信息
@@ -354,6 +883,17 @@ func (u *FileUploader) GetStats() (map[string]interface{}, error) {
 		"max_files":      u.config.MaxFiles,
 		"max_size":       u.config.MaxSize,
 		"check_interval": u.config.CheckInterval.String(),
+		"storage":        blobstore.Backend(),
+		"quiet_hours":    u.config.QuietHours.String(),
+		"bandwidth_max":  u.config.BandwidthMax,
+	}
+
+	if batches, err := u.queue.List(""); err == nil {
+		states := map[string]int{}
+		for _, batch := range batches {
+			states[batch.State]++
+		}
+		stats["batches"] = states
 	}
 
 	return stats, nil
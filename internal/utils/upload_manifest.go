@@ -0,0 +1,259 @@
+package utils
+
+// 上传批次的清单、校验和上传台账
+// 每个压缩包旁边上传一份清单（<压缩包>.manifest.json），记录包内每个文件的大小、SHA-256、
+// 用户ID、日期和数据格式版本。上传后重新查询对象元信息，大小、ETag（MD5）和 CRC64 都与本地
+// 一致才删除原始文件；每个批次的结果追加到本地台账，用于核对没有数据在归档过程中丢失。
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"NewsEyeTracking/internal/blobstore"
+	"NewsEyeTracking/internal/storage"
+)
+
+const (
+	// ManifestVersion 清单格式版本
+	ManifestVersion = 1
+	// ManifestSuffix 清单对象相对压缩包的后缀
+	ManifestSuffix = ".manifest.json"
+
+	// SchemaUploadLedger 上传台账文件头中的数据类型
+	SchemaUploadLedger = "upload-ledger"
+	// UploadLedgerVersion 上传台账格式版本
+	UploadLedgerVersion = 1
+
+	LedgerStatusVerified           = "verified"
+	LedgerStatusVerificationFailed = "verification_failed"
+
+	legacySchema = "legacy-json"
+)
+
+// Manifest 上传批次清单
+type Manifest struct {
+	Version    int                 `json:"version"`
+	BatchID    string              `json:"batch_id"`
+	Type       string              `json:"type"` // tracking / news
+	CreatedAt  time.Time           `json:"created_at"`
+	Backend    string              `json:"backend"`
+	Archive    ArchiveDigest       `json:"archive"`
+	Encryption *ManifestEncryption `json:"encryption,omitempty"` // 归档经过信封加密时，Archive 描述的是加密后的对象
+	Files      []ManifestFile      `json:"files"`
+	UserIDs    []string            `json:"user_ids"`
+	DateFrom   string              `json:"date_from,omitempty"`
+	DateTo     string              `json:"date_to,omitempty"`
+}
+
+// ArchiveDigest 压缩包的大小和校验和
+type ArchiveDigest struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	MD5    string `json:"md5"`
+	CRC64  string `json:"crc64"` // CRC-64/ECMA-182，十进制，与 OSS 的 x-oss-hash-crc64ecma 相同
+}
+
+// ManifestEncryption 加密参数和明文压缩包的校验和，解密后用于核对
+type ManifestEncryption struct {
+	Algorithm       string `json:"algorithm"`
+	KeyID           string `json:"key_id"`
+	PlaintextSize   int64  `json:"plaintext_size"`
+	PlaintextSHA256 string `json:"plaintext_sha256"`
+}
+
+// ManifestFile 压缩包内的一个文件
+type ManifestFile struct {
+	Path          string `json:"path"` // 压缩包内的相对路径
+	Size          int64  `json:"size"`
+	SHA256        string `json:"sha256"`
+	UserID        string `json:"user_id"`
+	Date          string `json:"date"`
+	Schema        string `json:"schema"`
+	SchemaVersion int    `json:"schema_version"`
+
+	localPath string
+}
+
+// LedgerEntry 上传台账中的一条记录
+type LedgerEntry struct {
+	BatchID     string    `json:"batch_id"`
+	Type        string    `json:"type"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	Backend     string    `json:"backend"`
+	ArchiveKey  string    `json:"archive_key"`
+	ManifestKey string    `json:"manifest_key"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ETag        string    `json:"etag"`
+	CRC64       string    `json:"crc64,omitempty"`
+	Status      string    `json:"status"`
+	Reason      string    `json:"reason,omitempty"`
+	FileCount   int       `json:"file_count"`
+	UserIDs     []string  `json:"user_ids"`
+	DateFrom    string    `json:"date_from,omitempty"`
+	DateTo      string    `json:"date_to,omitempty"`
+	Deleted     int       `json:"deleted"` // 校验通过后删除的原始文件数
+
+	// Sources 校验通过的批次包含的原始文件及压缩时的大小，数据保留清理据此判断文件是否已上传
+	Sources []SourceFile `json:"sources,omitempty"`
+}
+
+// UploadLedgerPath 上传台账路径
+func UploadLedgerPath() string {
+	if path := os.Getenv("UPLOAD_LEDGER_PATH"); path != "" {
+		return path
+	}
+	return "data/ledger/uploads.ndjson"
+}
+
+// AppendLedger 追加一条上传台账记录
+func AppendLedger(path string, entry LedgerEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	header := storage.FileHeader{Schema: SchemaUploadLedger, Version: UploadLedgerVersion, CreatedAt: time.Now()}
+	return storage.AppendRecords(path, header, []LedgerEntry{entry})
+}
+
+// UploadedFiles 读取上传台账，返回校验通过的原始文件路径及其压缩时的大小
+// 台账不存在时返回空表
+func UploadedFiles(path string) (map[string]int64, error) {
+	uploaded := make(map[string]int64)
+	err := storage.ReadFile(path, func(_ storage.FileHeader, entry LedgerEntry) error {
+		if entry.Status != LedgerStatusVerified {
+			return nil
+		}
+		for _, source := range entry.Sources {
+			uploaded[source.Path] = source.Size
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return uploaded, nil
+}
+
+// newManifestFile 根据文件路径推断用户ID、日期和数据格式
+// 数据文件的路径形如 <baseDir>/<date>/<userID>.ndjson
+func newManifestFile(localPath, relPath string) ManifestFile {
+	name := filepath.Base(localPath)
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+
+	file := ManifestFile{
+		Path:      filepath.ToSlash(relPath),
+		UserID:    name,
+		localPath: localPath,
+	}
+	if dir := filepath.Base(filepath.Dir(localPath)); isDateDir(dir) {
+		file.Date = dir
+	}
+
+	if filepath.Ext(localPath) == storage.LegacyJSONExt {
+		file.Schema, file.SchemaVersion = legacySchema, 1
+	} else if header, err := storage.ReadHeader(localPath); err == nil {
+		file.Schema, file.SchemaVersion = header.Schema, header.Version
+	}
+	return file
+}
+
+func isDateDir(name string) bool {
+	_, err := time.Parse("2006-01-02", name)
+	return err == nil
+}
+
+// summarize 汇总清单中的用户ID和日期范围
+func (m *Manifest) summarize() {
+	users := make(map[string]struct{})
+	m.UserIDs = []string{}
+	for _, file := range m.Files {
+		if _, ok := users[file.UserID]; !ok && file.UserID != "" {
+			users[file.UserID] = struct{}{}
+			m.UserIDs = append(m.UserIDs, file.UserID)
+		}
+		if file.Date == "" {
+			continue
+		}
+		if m.DateFrom == "" || file.Date < m.DateFrom {
+			m.DateFrom = file.Date
+		}
+		if file.Date > m.DateTo {
+			m.DateTo = file.Date
+		}
+	}
+	sort.Strings(m.UserIDs)
+}
+
+// ledgerEntry 根据清单生成台账记录
+func (m *Manifest) ledgerEntry(manifestKey string) LedgerEntry {
+	return LedgerEntry{
+		BatchID:     m.BatchID,
+		Type:        m.Type,
+		UploadedAt:  time.Now(),
+		Backend:     m.Backend,
+		ArchiveKey:  m.Archive.Key,
+		ManifestKey: manifestKey,
+		Size:        m.Archive.Size,
+		SHA256:      m.Archive.SHA256,
+		FileCount:   len(m.Files),
+		UserIDs:     m.UserIDs,
+		DateFrom:    m.DateFrom,
+		DateTo:      m.DateTo,
+	}
+}
+
+// archiveDigest 计算压缩包的大小和校验和
+func archiveDigest(path string) (ArchiveDigest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ArchiveDigest{}, err
+	}
+	defer file.Close()
+
+	sha := sha256.New()
+	md := md5.New()
+	crc := crc64.New(crc64.MakeTable(crc64.ECMA))
+	size, err := io.Copy(io.MultiWriter(sha, md, crc), file)
+	if err != nil {
+		return ArchiveDigest{}, err
+	}
+
+	return ArchiveDigest{
+		Size:   size,
+		SHA256: hexSum(sha),
+		MD5:    hexSum(md),
+		CRC64:  strconv.FormatUint(crc.Sum64(), 10),
+	}, nil
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verifyObject 检查已上传对象的元信息与本地计算的是否一致
+// 分片上传的 ETag（带 "-"）不是内容的 MD5，此时只比较大小和 CRC64
+func verifyObject(info *blobstore.ObjectInfo, size int64, md5Hex, crc string) error {
+	if info.Size != size {
+		return fmt.Errorf("对象 %s 大小不一致: 本地 %d，远端 %d", info.Key, size, info.Size)
+	}
+	if info.ETag != "" && !strings.Contains(info.ETag, "-") && !strings.EqualFold(info.ETag, md5Hex) {
+		return fmt.Errorf("对象 %s 的 ETag 与本地 MD5 不一致: 本地 %s，远端 %s", info.Key, md5Hex, info.ETag)
+	}
+	if info.CRC64 != "" && crc != "" && info.CRC64 != crc {
+		return fmt.Errorf("对象 %s 的 CRC64 不一致: 本地 %s，远端 %s", info.Key, crc, info.CRC64)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 上传触发方式
+const (
+	TriggerThreshold = "threshold" // 文件数或大小达到阈值
+	TriggerSchedule  = "schedule"  // 定时上传
+	TriggerManual    = "manual"    // 管理员手动触发
+	TriggerRetry     = "retry"     // 管理员重试失败批次
+)
+
+// 上传任务状态
+const (
+	RunStateRunning   = "running"
+	RunStateCompleted = "completed"
+	RunStateFailed    = "failed"
+)
+
+// ErrUploadInProgress 已有打包或上传任务在进行
+var ErrUploadInProgress = errors.New("已有上传任务在进行")
+
+// UploadRun 一次上传任务的进度
+type UploadRun struct {
+	ID               string     `json:"id"`
+	Trigger          string     `json:"trigger"`
+	State            string     `json:"state"`
+	StartedAt        time.Time  `json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+	BatchesTotal     int        `json:"batches_total"`
+	BatchesDone      int        `json:"batches_done"`
+	BatchesFailed    int        `json:"batches_failed"`
+	BatchesDeferred  int        `json:"batches_deferred"` // 因进入静默时段留到之后上传的批次
+	CurrentBatch     string     `json:"current_batch,omitempty"`
+	CurrentObject    string     `json:"current_object,omitempty"`
+	BytesTransferred int64      `json:"bytes_transferred"` // 当前对象已上传的字节数
+	BytesTotal       int64      `json:"bytes_total"`       // 当前对象的大小
+	Error            string     `json:"error,omitempty"`
+}
+
+// runTracker 记录正在进行或最近一次的上传任务
+type runTracker struct {
+	mu     sync.Mutex
+	latest *UploadRun
+}
+
+// begin 开始一次任务；已有任务在进行时沿用它，owned 为 false
+func (t *runTracker) begin(trigger string) (owned bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.latest != nil && t.latest.State == RunStateRunning {
+		return false
+	}
+	now := time.Now()
+	t.latest = &UploadRun{
+		ID:        fmt.Sprintf("%s_%s", trigger, now.Format("20060102_150405.000")),
+		Trigger:   trigger,
+		State:     RunStateRunning,
+		StartedAt: now,
+	}
+	return true
+}
+
+// finish 结束当前任务
+func (t *runTracker) finish(err error) {
+	t.update(func(run *UploadRun) {
+		now := time.Now()
+		run.FinishedAt = &now
+		run.CurrentBatch, run.CurrentObject = "", ""
+		run.State = RunStateCompleted
+		if err != nil {
+			run.State, run.Error = RunStateFailed, err.Error()
+		}
+	})
+}
+
+// update 修改正在进行的任务
+func (t *runTracker) update(fn func(run *UploadRun)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.latest != nil && t.latest.State == RunStateRunning {
+		fn(t.latest)
+	}
+}
+
+// snapshot 返回最近一次任务的副本，没有任务时返回 nil
+func (t *runTracker) snapshot() *UploadRun {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.latest == nil {
+		return nil
+	}
+	run := *t.latest
+	return &run
+}
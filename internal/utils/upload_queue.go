@@ -0,0 +1,351 @@
+package utils
+
+// 持久化的上传队列
+// 每个批次在创建时压缩一次，压缩包、清单和状态文件（<批次ID>.state.json）放在队列目录中，
+// 上传失败后按指数退避重试，不再每次重新压缩。超过最大尝试次数的批次连同压缩包移动到死信目录，
+// 原始文件保持不动，由管理员检查后重新排队。
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 批次状态
+const (
+	BatchStatePending   = "pending"   // 等待上传（包括等待重试）
+	BatchStateUploading = "uploading" // 正在上传
+	BatchStateUploaded  = "uploaded"  // 已上传并校验，原始文件已清理
+	BatchStateFailed    = "failed"    // 超过最大尝试次数，已移到死信目录
+)
+
+const (
+	batchStateSuffix  = ".state.json"
+	uploadedRetention = 7 * 24 * time.Hour // 已上传批次的状态文件保留时间
+)
+
+var (
+	// ErrBatchNotFound 上传批次不存在
+	ErrBatchNotFound = errors.New("上传批次不存在")
+	// ErrBatchNotFailed 只有失败的批次可以重新排队
+	ErrBatchNotFailed = errors.New("只有失败的批次可以重试")
+)
+
+// UploadBatch 上传批次的持久化状态
+type UploadBatch struct {
+	ID            string       `json:"id"`
+	Type          string       `json:"type"` // tracking / news
+	State         string       `json:"state"`
+	Attempts      int          `json:"attempts"`
+	NextAttemptAt time.Time    `json:"next_attempt_at"`
+	LastError     string       `json:"last_error,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	ObjectKey     string       `json:"object_key"`
+	ManifestKey   string       `json:"manifest_key"`
+	FileCount     int          `json:"file_count"`
+	Size          int64        `json:"size"`                   // 上传对象（压缩包或加密后的压缩包）大小
+	ArchiveName   string       `json:"archive_name,omitempty"` // 队列目录中的归档文件名，为空时为 <批次ID>.zip
+	Encrypted     bool         `json:"encrypted"`
+	Sources       []SourceFile `json:"sources"`
+}
+
+// SourceFile 批次包含的原始文件，大小为压缩时读取的字节数
+type SourceFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// UploadQueue 上传队列
+type UploadQueue struct {
+	dir     string
+	deadDir string
+	mu      sync.Mutex
+}
+
+// NewUploadQueue 创建上传队列，目录在第一次写入时创建
+func NewUploadQueue(dir, deadDir string) *UploadQueue {
+	return &UploadQueue{dir: dir, deadDir: deadDir}
+}
+
+// ZipPath 批次压缩包在队列目录中的路径
+func (q *UploadQueue) ZipPath(id string) string {
+	return filepath.Join(q.dir, id+".zip")
+}
+
+// ArchivePath 批次待上传的归档在队列目录中的路径
+func (q *UploadQueue) ArchivePath(batch *UploadBatch) string {
+	return filepath.Join(q.dir, batch.archiveName())
+}
+
+func (b *UploadBatch) archiveName() string {
+	if b.ArchiveName != "" {
+		return b.ArchiveName
+	}
+	return b.ID + ".zip"
+}
+
+// ManifestPath 批次清单在队列目录中的路径
+func (q *UploadQueue) ManifestPath(id string) string {
+	return filepath.Join(q.dir, id+ManifestSuffix)
+}
+
+// NewBatchID 生成不与队列中已有批次重复的批次ID
+func (q *UploadQueue) NewBatchID(dirType string, now time.Time) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := os.MkdirAll(q.dir, 0755); err != nil {
+		return "", err
+	}
+	base := fmt.Sprintf("%s_batch_%s", dirType, now.Format("20060102_150405"))
+	id := base
+	for n := 2; ; n++ {
+		_, errQueue := os.Stat(filepath.Join(q.dir, id+batchStateSuffix))
+		_, errDead := os.Stat(filepath.Join(q.deadDir, id+batchStateSuffix))
+		if os.IsNotExist(errQueue) && os.IsNotExist(errDead) {
+			return id, nil
+		}
+		id = fmt.Sprintf("%s_%d", base, n)
+	}
+}
+
+// Save 写入批次状态（先写临时文件再重命名）
+func (q *UploadQueue) Save(batch *UploadBatch) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.save(q.dir, batch)
+}
+
+func (q *UploadQueue) save(dir string, batch *UploadBatch) error {
+	batch.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, batch.ID+batchStateSuffix)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// List 列出队列和死信目录中的批次，state 为空时返回全部，按创建时间排序
+func (q *UploadQueue) List(state string) ([]*UploadBatch, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var batches []*UploadBatch
+	for _, dir := range []string{q.dir, q.deadDir} {
+		loaded, err := loadBatches(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, batch := range loaded {
+			if state == "" || batch.State == state {
+				batches = append(batches, batch)
+			}
+		}
+	}
+
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].CreatedAt.Before(batches[j].CreatedAt)
+	})
+	return batches, nil
+}
+
+func loadBatches(dir string) ([]*UploadBatch, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+batchStateSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	batches := make([]*UploadBatch, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var batch UploadBatch
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, fmt.Errorf("解析批次状态 %s 失败: %w", path, err)
+		}
+		batches = append(batches, &batch)
+	}
+	return batches, nil
+}
+
+// ClaimedFiles 尚未完成的批次（包括死信）占用的原始文件，扫描目录时跳过，避免重复打包
+func (q *UploadQueue) ClaimedFiles() (map[string]bool, error) {
+	batches, err := q.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make(map[string]bool)
+	for _, batch := range batches {
+		if batch.State == BatchStateUploaded {
+			continue
+		}
+		for _, source := range batch.Sources {
+			claimed[source.Path] = true
+		}
+	}
+	return claimed, nil
+}
+
+// Recover 服务启动时恢复队列：中断的上传重新排队，删除没有状态文件的压缩包
+// 旧版本上传失败时遗留的压缩包对应的原始文件没有被删除，下次扫描会重新打包
+func (q *UploadQueue) Recover(tempDir string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	batches, err := loadBatches(q.dir)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, batch := range batches {
+		known[batch.ID] = true
+		if batch.State == BatchStateUploading {
+			batch.State = BatchStatePending
+			batch.NextAttemptAt = time.Now()
+			if err := q.save(q.dir, batch); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, dir := range []string{tempDir, q.dir} {
+		orphans, _ := filepath.Glob(filepath.Join(dir, "*_batch_*"))
+		for _, path := range orphans {
+			name := filepath.Base(path)
+			id := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, ".enc"), ".zip"), ManifestSuffix)
+			if (id == name && !strings.HasSuffix(name, ".tmp")) || known[id] {
+				continue
+			}
+			if err := os.Remove(path); err == nil {
+				log.Printf("删除遗留的临时文件: %s", path)
+			}
+		}
+	}
+	return nil
+}
+
+// Complete 批次上传完成，删除压缩包和清单，保留状态文件
+func (q *UploadQueue) Complete(batch *UploadBatch) error {
+	batch.State = BatchStateUploaded
+	batch.LastError = ""
+	if err := q.Save(batch); err != nil {
+		return err
+	}
+	os.Remove(q.ArchivePath(batch))
+	os.Remove(q.ManifestPath(batch.ID))
+	return nil
+}
+
+// DeadLetter 把批次的压缩包、清单和状态移动到死信目录
+func (q *UploadQueue) DeadLetter(batch *UploadBatch) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := os.MkdirAll(q.deadDir, 0755); err != nil {
+		return err
+	}
+	for _, name := range []string{batch.archiveName(), batch.ID + ManifestSuffix} {
+		if err := os.Rename(filepath.Join(q.dir, name), filepath.Join(q.deadDir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	batch.State = BatchStateFailed
+	if err := q.save(q.deadDir, batch); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(q.dir, batch.ID+batchStateSuffix))
+}
+
+// Requeue 把死信目录中的批次移回队列，重置尝试次数并立即重试
+func (q *UploadQueue) Requeue(id string) (*UploadBatch, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if filepath.Base(id) != id || id == "" {
+		return nil, ErrBatchNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(q.deadDir, id+batchStateSuffix))
+	if os.IsNotExist(err) {
+		if _, errQueue := os.Stat(filepath.Join(q.dir, id+batchStateSuffix)); errQueue == nil {
+			return nil, ErrBatchNotFailed
+		}
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var batch UploadBatch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("解析批次状态失败: %w", err)
+	}
+
+	if err := os.MkdirAll(q.dir, 0755); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{batch.archiveName(), id + ManifestSuffix} {
+		if err := os.Rename(filepath.Join(q.deadDir, name), filepath.Join(q.dir, name)); err != nil {
+			return nil, fmt.Errorf("移动批次文件失败: %w", err)
+		}
+	}
+
+	batch.State = BatchStatePending
+	batch.Attempts = 0
+	batch.NextAttemptAt = time.Now()
+	if err := q.save(q.dir, &batch); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(filepath.Join(q.deadDir, id+batchStateSuffix)); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// PruneUploaded 删除超过保留时间的已上传批次状态文件
+func (q *UploadQueue) PruneUploaded() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	batches, err := loadBatches(q.dir)
+	if err != nil {
+		return
+	}
+	for _, batch := range batches {
+		if batch.State == BatchStateUploaded && time.Since(batch.UpdatedAt) > uploadedRetention {
+			os.Remove(filepath.Join(q.dir, batch.ID+batchStateSuffix))
+		}
+	}
+}
+
+// retryDelay 第 attempts 次失败后的等待时间：base * 2^(attempts-1)，不超过 maxDelay
+func retryDelay(attempts int, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
@@ -0,0 +1,98 @@
+package utils
+
+// 上传时间窗口
+// 实验进行时上传会占用实验室网络带宽，可能影响眼动数据的实时传输，因此可以配置静默时段：
+// 静默时段内照常打包，但不向对象存储上传，批次留在队列中等静默时段结束后再上传。
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow 一天中的一个时间段，单位为分钟；End 小于 Start 时表示跨越午夜
+type TimeWindow struct {
+	Start int
+	End   int
+}
+
+// QuietHours 静默时段
+type QuietHours struct {
+	Windows  []TimeWindow
+	Location *time.Location // 时段所在时区，为空时使用本地时区
+}
+
+// ParseQuietHours 解析静默时段，格式为逗号分隔的 HH:MM-HH:MM，例如 "09:00-12:00,13:30-17:30,22:00-06:00"
+func ParseQuietHours(value string, location *time.Location) (QuietHours, error) {
+	quiet := QuietHours{Location: location}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.Split(part, "-")
+		if len(bounds) != 2 {
+			return QuietHours{}, fmt.Errorf("静默时段 %q 格式错误，应为 HH:MM-HH:MM", part)
+		}
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return QuietHours{}, fmt.Errorf("静默时段 %q 格式错误: %v", part, err)
+		}
+		end, err := parseClock(bounds[1])
+		if err != nil {
+			return QuietHours{}, fmt.Errorf("静默时段 %q 格式错误: %v", part, err)
+		}
+		if start == end {
+			return QuietHours{}, fmt.Errorf("静默时段 %q 的开始和结束时间相同", part)
+		}
+		quiet.Windows = append(quiet.Windows, TimeWindow{Start: start, End: end})
+	}
+	return quiet, nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间 %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains t 是否在静默时段内
+func (q QuietHours) Contains(t time.Time) bool {
+	if q.Location != nil {
+		t = t.In(q.Location)
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range q.Windows {
+		if window.Start < window.End {
+			if minute >= window.Start && minute < window.End {
+				return true
+			}
+		} else if minute >= window.Start || minute < window.End {
+			return true
+		}
+	}
+	return false
+}
+
+// String 与 ParseQuietHours 的输入格式相同
+func (q QuietHours) String() string {
+	parts := make([]string, 0, len(q.Windows))
+	for _, window := range q.Windows {
+		parts = append(parts, fmt.Sprintf("%02d:%02d-%02d:%02d", window.Start/60, window.Start%60, window.End/60, window.End%60))
+	}
+	return strings.Join(parts, ",")
+}
+
+// LoadLocation 按名称加载时区（如 Asia/Shanghai），为空时返回本地时区
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q: %v", name, err)
+	}
+	return location, nil
+}
@@ -0,0 +1,68 @@
+-- +goose Up
+
+-- 追踪数据的数据库存储，TRACKING_SINK=postgres 或 both 时由刷新任务用 COPY 批量写入
+-- 三张表都按批次时间（请求中的 timestamp）按月分区，分区由写入方按需创建（如 eye_samples_y2026m10），
+-- 清理历史数据时直接 DROP 对应月份的分区表
+-- batch_time / batch_seq / batch_id 与文件中的 UserTrackingRecord 一一对应，可以据此还原原始批次
+
+CREATE TABLE eye_samples (
+    session_id    UUID             NOT NULL,
+    user_id       UUID             NOT NULL,
+    batch_time    TIMESTAMPTZ      NOT NULL,            -- 批次时间
+    batch_seq     BIGINT           NOT NULL DEFAULT 0,  -- 批次序号，旧客户端为 0
+    batch_id      TEXT             NOT NULL DEFAULT '', -- 批次ID，旧客户端为空
+    sample_index  INTEGER          NOT NULL,            -- 批次内的顺序
+    sample_time   TIMESTAMPTZ      NOT NULL,            -- 换算后的绝对采样时间，与注视检测使用的时间轴一致
+    client_t      DOUBLE PRECISION,                     -- 客户端单调时钟（毫秒），未提供时为空
+    client_clock  DOUBLE PRECISION,                     -- 批次的 client_clock
+    sampling_rate REAL             NOT NULL DEFAULT 0,  -- 批次的标称采样率，0 表示未提供
+    element_id    TEXT             NOT NULL DEFAULT '',
+    x             REAL             NOT NULL,
+    y             REAL             NOT NULL,
+    pupil         REAL,
+    valid         BOOLEAN,                              -- 为空表示客户端未提供（视为有效）
+    left_x        REAL,                                 -- 单眼数据，为空表示未提供
+    left_y        REAL,
+    left_pupil    REAL,
+    left_valid    BOOLEAN,
+    right_x       REAL,
+    right_y       REAL,
+    right_pupil   REAL,
+    right_valid   BOOLEAN
+) PARTITION BY RANGE (batch_time);
+
+CREATE INDEX idx_eye_samples_session ON eye_samples (session_id, batch_time);
+
+CREATE TABLE clicks (
+    session_id  UUID        NOT NULL,
+    user_id     UUID        NOT NULL,
+    batch_time  TIMESTAMPTZ NOT NULL,
+    batch_seq   BIGINT      NOT NULL DEFAULT 0,
+    batch_id    TEXT        NOT NULL DEFAULT '',
+    event_index INTEGER     NOT NULL,
+    clicked_at  TIMESTAMPTZ NOT NULL,
+    element_id  TEXT        NOT NULL DEFAULT '',
+    x           REAL        NOT NULL,
+    y           REAL        NOT NULL
+) PARTITION BY RANGE (batch_time);
+
+CREATE INDEX idx_clicks_session ON clicks (session_id, batch_time);
+
+CREATE TABLE scrolls (
+    session_id  UUID        NOT NULL,
+    user_id     UUID        NOT NULL,
+    batch_time  TIMESTAMPTZ NOT NULL,
+    batch_seq   BIGINT      NOT NULL DEFAULT 0,
+    batch_id    TEXT        NOT NULL DEFAULT '',
+    event_index INTEGER     NOT NULL,
+    scrolled_at TIMESTAMPTZ NOT NULL,
+    delta_y     REAL        NOT NULL
+) PARTITION BY RANGE (batch_time);
+
+CREATE INDEX idx_scrolls_session ON scrolls (session_id, batch_time);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS scrolls;
+DROP TABLE IF EXISTS clicks;
+DROP TABLE IF EXISTS eye_samples;
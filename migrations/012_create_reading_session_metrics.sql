@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- 阅读会话的概要指标，会话结束、最后一批追踪数据落地后计算，重新计算时覆盖
+-- 时间单位为毫秒；分词相关的指标只对文章页会话有意义，列表页会话为空
+CREATE TABLE reading_session_metrics (
+    session_id           UUID             PRIMARY KEY REFERENCES reading_sessions(id) ON DELETE CASCADE,
+    user_id              UUID             NOT NULL,
+    article_id           VARCHAR(512)     NOT NULL,
+    dwell_time_ms        BIGINT           NOT NULL,            -- 停留时间：结束时间 - 开始时间
+    active_time_ms       BIGINT           NOT NULL,            -- 停留时间中有注视、点击或滚动的部分
+    idle_time_ms         BIGINT           NOT NULL,            -- 相邻两次活动间隔超过阈值的部分
+    gaze_samples         INTEGER          NOT NULL,            -- 眼动采样数
+    valid_sample_percent DOUBLE PRECISION NOT NULL,            -- 有效采样百分比（0~100），没有采样时为 0
+    max_scroll_depth     DOUBLE PRECISION NOT NULL,            -- 到达的最大滚动偏移（像素）
+    click_count          INTEGER          NOT NULL,
+    token_count          INTEGER,                              -- 文章分词数
+    fixated_tokens       INTEGER,                              -- 被注视到的分词数
+    fixated_token_ratio  DOUBLE PRECISION,                     -- 被注视到的分词比例（0~1）
+    reading_speed        DOUBLE PRECISION,                     -- 阅读速度：每分钟活跃时间注视到的分词数
+    algorithm            VARCHAR(16)      NOT NULL,            -- 计算分词指标使用的注视检测算法
+    computed_at          TIMESTAMPTZ      NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_reading_session_metrics_article ON reading_session_metrics (article_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS reading_session_metrics;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- 列表页新闻展示，每次返回新闻列表时每篇新闻一行
+-- 推荐服务通过它排除或降权用户已经看过的新闻；重复写入同一个展示ID时忽略，刷新失败重试不会重复
+CREATE TABLE news_impressions (
+    impression_id     UUID             PRIMARY KEY,
+    user_id           UUID             NOT NULL,
+    list_session_id   UUID,                                    -- 展示这批新闻的列表页阅读会话
+    guid              VARCHAR(512)     NOT NULL,               -- 新闻 GUID
+    position          INTEGER          NOT NULL,               -- 在本次返回列表中的位置，从 0 开始
+    score             DOUBLE PRECISION,                        -- 推荐服务给出的分数
+    strategy          VARCHAR(64)      NOT NULL DEFAULT '',    -- 推荐算法名称
+    recommend_user_id VARCHAR(64)      NOT NULL DEFAULT '',    -- 推荐服务返回的用户ID
+    shown_at          TIMESTAMPTZ      NOT NULL
+);
+
+CREATE INDEX idx_news_impressions_user_guid ON news_impressions (user_id, guid, shown_at);
+CREATE INDEX idx_news_impressions_shown_at ON news_impressions (shown_at);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS news_impressions;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- 新闻详情打开记录，与 news_impressions 一起计算点击率
+-- 点击ID由服务端生成，重复写入时忽略，刷新失败重试不会重复
+CREATE TABLE news_clicks (
+    click_id          UUID             PRIMARY KEY,
+    user_id           UUID             NOT NULL,
+    guid              VARCHAR(512)     NOT NULL,               -- 新闻 GUID
+    impression_id     UUID,                                    -- 归属的展示，找不到对应展示时为空
+    position          INTEGER,                                 -- 展示在列表中的位置
+    strategy          VARCHAR(64)      NOT NULL DEFAULT '',    -- 展示所用的推荐算法
+    list_session_id   UUID,                                    -- 展示这篇新闻的列表页阅读会话
+    detail_session_id UUID,                                    -- 打开详情时创建的文章页阅读会话
+    clicked_at        TIMESTAMPTZ      NOT NULL
+);
+
+CREATE INDEX idx_news_clicks_clicked_at ON news_clicks (clicked_at);
+CREATE INDEX idx_news_clicks_impression ON news_clicks (impression_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS news_clicks;
@@ -64,3 +64,50 @@ ON CONFLICT (guid) DO UPDATE SET
     comments = EXCLUDED.comments,
     content_hash = EXCLUDED.content_hash
 RETURNING *;
+
+-- 新闻展示：一次列表请求的全部展示用一条语句写入，展示ID已存在时忽略（刷新失败重试）
+-- 没有推荐分数的展示在 scores 中传 NaN，写入 NULL
+-- name: InsertNewsImpressions :exec
+INSERT INTO news_impressions (
+    impression_id, user_id, list_session_id, guid, position, score, strategy, recommend_user_id, shown_at
+)
+SELECT t.impression_id, sqlc.arg(user_id), sqlc.narg(list_session_id), t.guid, t.position, NULLIF(t.score, 'NaN'::float8),
+       sqlc.arg(strategy), sqlc.arg(recommend_user_id), sqlc.arg(shown_at)
+FROM unnest(
+    sqlc.arg(impression_ids)::uuid[], sqlc.arg(guids)::text[], sqlc.arg(positions)::int[], sqlc.arg(scores)::float8[]
+) AS t(impression_id, guid, position, score)
+ON CONFLICT (impression_id) DO NOTHING;
+
+-- 推荐：用户在某个时间之后看过的新闻及展示次数，用于排除或降权
+-- name: ListShownNews :many
+SELECT guid, COUNT(*)::int AS show_count, MAX(shown_at)::timestamptz AS last_shown_at
+FROM news_impressions
+WHERE user_id = sqlc.arg(user_id) AND shown_at >= sqlc.arg(shown_after)::timestamptz
+GROUP BY guid
+ORDER BY last_shown_at DESC;
+
+-- 点击关联展示：按展示ID查询，展示必须属于该用户且是同一篇新闻
+-- name: GetNewsImpression :one
+SELECT * FROM news_impressions
+WHERE impression_id = $1 AND user_id = $2 AND guid = $3;
+
+-- 点击率报告：查询展示时间在时间范围内的新闻展示
+-- name: ListNewsImpressionsBetween :many
+SELECT * FROM news_impressions
+WHERE shown_at >= sqlc.arg(shown_from)::timestamptz AND shown_at < sqlc.arg(shown_to)::timestamptz
+ORDER BY shown_at;
+
+-- 新闻点击：点击ID已存在时忽略（刷新失败重试）
+-- name: InsertNewsClick :exec
+INSERT INTO news_clicks (
+    click_id, user_id, guid, impression_id, position, strategy, list_session_id, detail_session_id, clicked_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+ON CONFLICT (click_id) DO NOTHING;
+
+-- 点击率报告：查询点击时间在时间范围内的新闻点击
+-- name: ListNewsClicksBetween :many
+SELECT * FROM news_clicks
+WHERE clicked_at >= sqlc.arg(clicked_from)::timestamptz AND clicked_at < sqlc.arg(clicked_to)::timestamptz
+ORDER BY clicked_at;
@@ -23,3 +23,57 @@ SELECT * FROM reading_sessions WHERE user_id = $1 AND end_time IS NULL;
 
 
 
+-- 数据保留：统计结束时间早于截止时间的阅读会话（试运行使用）
+-- name: CountEndedSessionsBefore :one
+SELECT COUNT(*) FROM reading_sessions
+WHERE end_time IS NOT NULL AND end_time < sqlc.arg(cutoff)::timestamptz;
+
+-- 数据保留：删除结束时间早于截止时间的阅读会话，未结束的会话不删除
+-- name: DeleteEndedSessionsBefore :execrows
+DELETE FROM reading_sessions
+WHERE end_time IS NOT NULL AND end_time < sqlc.arg(cutoff)::timestamptz;
+
+-- 数据导出：查询开始时间在时间范围内的阅读会话，用于补全会话的文章和设备信息
+-- name: ListSessionsStartedBetween :many
+SELECT * FROM reading_sessions
+WHERE start_time >= sqlc.arg(start_from)::timestamptz AND start_time < sqlc.arg(start_to)::timestamptz
+ORDER BY start_time;
+
+-- 热力图：查询某篇文章在时间范围内的阅读会话及用户所在的实验分组（用户ID即邀请码ID）
+-- name: ListArticleSessions :many
+SELECT rs.id, rs.user_id, rs.article_id, rs.start_time, rs.end_time, rs.device_info,
+       ic.has_recommend, ic.has_more_information
+FROM reading_sessions rs
+LEFT JOIN invite_codes ic ON ic.id = rs.user_id
+WHERE rs.article_id = sqlc.arg(article_id)
+  AND rs.start_time >= sqlc.arg(start_from)::timestamptz AND rs.start_time < sqlc.arg(start_to)::timestamptz
+ORDER BY rs.start_time DESC;
+
+-- 会话概要指标：会话结束后计算，重新计算时覆盖之前的结果
+-- name: UpsertSessionMetrics :one
+INSERT INTO reading_session_metrics (
+    session_id, user_id, article_id, dwell_time_ms, active_time_ms, idle_time_ms,
+    gaze_samples, valid_sample_percent, max_scroll_depth, click_count,
+    token_count, fixated_tokens, fixated_token_ratio, reading_speed, algorithm
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+)
+ON CONFLICT (session_id) DO UPDATE SET
+    dwell_time_ms = EXCLUDED.dwell_time_ms,
+    active_time_ms = EXCLUDED.active_time_ms,
+    idle_time_ms = EXCLUDED.idle_time_ms,
+    gaze_samples = EXCLUDED.gaze_samples,
+    valid_sample_percent = EXCLUDED.valid_sample_percent,
+    max_scroll_depth = EXCLUDED.max_scroll_depth,
+    click_count = EXCLUDED.click_count,
+    token_count = EXCLUDED.token_count,
+    fixated_tokens = EXCLUDED.fixated_tokens,
+    fixated_token_ratio = EXCLUDED.fixated_token_ratio,
+    reading_speed = EXCLUDED.reading_speed,
+    algorithm = EXCLUDED.algorithm,
+    computed_at = NOW()
+RETURNING *;
+
+-- 查询会话的概要指标
+-- name: GetSessionMetrics :one
+SELECT * FROM reading_session_metrics WHERE session_id = $1;
@@ -0,0 +1,47 @@
+-- 按批次和批次内顺序读取会话的全部眼动采样，用于还原追踪记录
+-- name: ListSessionEyeSamples :many
+SELECT * FROM eye_samples
+WHERE session_id = $1
+ORDER BY batch_time, batch_seq, batch_id, sample_index;
+
+-- 按批次和批次内顺序读取会话的全部点击事件
+-- name: ListSessionClicks :many
+SELECT * FROM clicks
+WHERE session_id = $1
+ORDER BY batch_time, batch_seq, batch_id, event_index;
+
+-- 按批次和批次内顺序读取会话的全部滚动事件
+-- name: ListSessionScrolls :many
+SELECT * FROM scrolls
+WHERE session_id = $1
+ORDER BY batch_time, batch_seq, batch_id, event_index;
+
+-- 导出：批次时间在范围内、有追踪数据的阅读会话
+-- name: ListTrackingSessionsBetween :many
+SELECT session_id, user_id FROM eye_samples
+WHERE batch_time >= sqlc.arg(batch_from)::timestamptz AND batch_time < sqlc.arg(batch_to)::timestamptz
+UNION
+SELECT session_id, user_id FROM clicks
+WHERE batch_time >= sqlc.arg(batch_from)::timestamptz AND batch_time < sqlc.arg(batch_to)::timestamptz
+UNION
+SELECT session_id, user_id FROM scrolls
+WHERE batch_time >= sqlc.arg(batch_from)::timestamptz AND batch_time < sqlc.arg(batch_to)::timestamptz
+ORDER BY user_id, session_id;
+
+-- 导出：按批次和批次内顺序读取会话在批次时间范围内的眼动采样
+-- name: ListSessionEyeSamplesBetween :many
+SELECT * FROM eye_samples
+WHERE session_id = sqlc.arg(session_id) AND batch_time >= sqlc.arg(batch_from)::timestamptz AND batch_time < sqlc.arg(batch_to)::timestamptz
+ORDER BY batch_time, batch_seq, batch_id, sample_index;
+
+-- 导出：按批次和批次内顺序读取会话在批次时间范围内的点击事件
+-- name: ListSessionClicksBetween :many
+SELECT * FROM clicks
+WHERE session_id = sqlc.arg(session_id) AND batch_time >= sqlc.arg(batch_from)::timestamptz AND batch_time < sqlc.arg(batch_to)::timestamptz
+ORDER BY batch_time, batch_seq, batch_id, event_index;
+
+-- 导出：按批次和批次内顺序读取会话在批次时间范围内的滚动事件
+-- name: ListSessionScrollsBetween :many
+SELECT * FROM scrolls
+WHERE session_id = sqlc.arg(session_id) AND batch_time >= sqlc.arg(batch_from)::timestamptz AND batch_time < sqlc.arg(batch_to)::timestamptz
+ORDER BY batch_time, batch_seq, batch_id, event_index;
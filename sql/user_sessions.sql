@@ -69,3 +69,12 @@ WHERE
     AND EXTRACT(EPOCH FROM (NOW() - last_heartbeat)) > $1::integer;
 
 
+-- 数据保留：统计早于截止时间结束的非活跃用户会话（试运行使用）
+-- name: CountInactiveUserSessionsBefore :one
+SELECT COUNT(*) FROM user_sessions
+WHERE is_active = FALSE AND COALESCE(end_time, last_heartbeat) < sqlc.arg(cutoff)::timestamptz;
+
+-- 数据保留：删除早于截止时间结束的非活跃用户会话
+-- name: DeleteInactiveUserSessionsBefore :execrows
+DELETE FROM user_sessions
+WHERE is_active = FALSE AND COALESCE(end_time, last_heartbeat) < sqlc.arg(cutoff)::timestamptz;
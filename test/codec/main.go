@@ -0,0 +1,167 @@
+package main
+
+// CBOR 紧凑编码与 JSON 的往返检查
+// 用法: go run ./test/codec
+// 对每个样例：JSON -> models -> CBOR -> models -> JSON，比较两次 JSON 是否一致，并打印编码后的大小
+import (
+	"NewsEyeTracking/internal/codec"
+	"NewsEyeTracking/internal/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+type testCase struct {
+	name string
+	json string
+	opts codec.EncodeOptions
+}
+
+func main() {
+	cases := []testCase{
+		{
+			name: "legacy id/x/y",
+			json: `{"timestamp":"2025-06-01T08:00:00.123Z","data":{"eye_event":[{"id":"w1","x":100,"y":200},{"id":"w1","x":102,"y":201},{"id":"","x":500,"y":90}]}}`,
+		},
+		{
+			name: "timestamps, pupil, validity and binocular samples",
+			json: `{"timestamp":"2025-06-01T08:00:01Z","seq":7,"batch_id":"b-7","data":{"sampling_rate":120,"client_clock":1016.5,` +
+				`"eye_event":[{"id":"w2","x":10.5,"y":20.5,"t":1000.125,"pupil":3.25,"valid":true,"left":{"x":10,"y":20,"pupil":3.5},"right":{"x":11,"y":21,"valid":false}},` +
+				`{"id":"w3","x":11,"y":-4.5,"t":1008.458,"valid":false},{"id":"w2","x":12,"y":22,"t":1016.5,"pupil":3}]}}`,
+			opts: codec.EncodeOptions{CoordScale: 2},
+		},
+		{
+			name: "clicks, scrolls and heartbeat",
+			json: `{"timestamp":"2025-06-01T08:00:02.5Z","ping":true,"data":{"click_event":[{"timestamp":"2025-06-01T08:00:02.001Z","id":"btn","x":1,"y":2},` +
+				`{"timestamp":"2025-06-01T08:00:02.400Z","x":3,"y":4}],"scroll_event":[{"timestamp":"2025-06-01T08:00:01.9Z","delta_y":-120},{"timestamp":"2025-06-01T08:00:02.3Z","delta_y":80.5}]}}`,
+		},
+		{
+			name: "viewport resize",
+			json: `{"timestamp":"2025-06-01T08:00:03Z","seq":9,"data":{"eye_event":[{"id":"w4","x":640,"y":360}],` +
+				`"device_info":{"user_agent":"Mozilla/5.0","screen_width":1920,"screen_height":1080,"viewport_width":1280,"viewport_height":720}}}`,
+		},
+		{
+			name: "120 Hz batch of one second",
+			json: syntheticBatch(120),
+		},
+	}
+
+	failed := 0
+	for _, tc := range cases {
+		if err := roundTrip(tc); err != nil {
+			fmt.Printf("FAIL %s: %v\n", tc.name, err)
+			failed++
+			continue
+		}
+	}
+
+	if err := endSessionRoundTrip(); err != nil {
+		fmt.Printf("FAIL end session: %v\n", err)
+		failed++
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("all round trips passed")
+}
+
+func roundTrip(tc testCase) error {
+	var original models.SessionDataRequest
+	if err := json.Unmarshal([]byte(tc.json), &original); err != nil {
+		return fmt.Errorf("parse json: %w", err)
+	}
+
+	payload, err := codec.EncodeSessionData(&original, tc.opts)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	decoded, err := codec.DecodeSessionData(payload)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	want, _ := json.Marshal(normalize(&original))
+	got, _ := json.Marshal(normalize(decoded))
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("mismatch\n want %s\n got  %s", want, got)
+	}
+
+	compact, _ := json.Marshal(original)
+	fmt.Printf("ok   %-50s json %6d bytes, cbor %6d bytes (%.0f%%)\n",
+		tc.name, len(compact), len(payload), 100*float64(len(payload))/float64(len(compact)))
+	return nil
+}
+
+func endSessionRoundTrip() error {
+	original := models.EndSessionRequest{
+		EndTime:  time.UnixMilli(1748764800500),
+		Seq:      12,
+		BatchID:  "final",
+		FinalSeq: 12,
+		Data:     &models.TrackingData{EyeEvents: []models.EyeEvent{{ID: "w9", X: 300, Y: 400}}},
+	}
+
+	payload, err := codec.EncodeEndSession(&original, codec.EncodeOptions{})
+	if err != nil {
+		return err
+	}
+	decoded, err := codec.DecodeEndSession(payload)
+	if err != nil {
+		return err
+	}
+
+	want, _ := json.Marshal(original)
+	got, _ := json.Marshal(decoded)
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("mismatch\n want %s\n got  %s", want, got)
+	}
+	fmt.Printf("ok   %-50s cbor %6d bytes\n", "end session", len(payload))
+	return nil
+}
+
+// normalize 时间统一为 UTC，避免时区表示不同导致的 JSON 差异
+func normalize(req *models.SessionDataRequest) *models.SessionDataRequest {
+	out := *req
+	out.Timestamp = out.Timestamp.UTC()
+	if req.Data != nil {
+		data := *req.Data
+		data.ClickEvents = append([]models.ClickEvent(nil), data.ClickEvents...)
+		for i := range data.ClickEvents {
+			data.ClickEvents[i].Timestamp = data.ClickEvents[i].Timestamp.UTC()
+		}
+		data.ScrollEvents = append([]models.ScrollEvent(nil), data.ScrollEvents...)
+		for i := range data.ScrollEvents {
+			data.ScrollEvents[i].Timestamp = data.ScrollEvents[i].Timestamp.UTC()
+		}
+		out.Data = &data
+	}
+	return &out
+}
+
+// syntheticBatch 生成一秒钟的整数像素采样，模拟逐词阅读时的小幅移动
+func syntheticBatch(hz int) string {
+	rng := rand.New(rand.NewSource(1))
+	data := models.TrackingData{SamplingRate: float32(hz)}
+	x, y := 200.0, 300.0
+	for i := 0; i < hz; i++ {
+		x += float64(rng.Intn(7) - 2)
+		y += float64(rng.Intn(3) - 1)
+		t := float64(i*1000) / float64(hz)
+		t = float64(int64(t*1000)) / 1000
+		data.EyeEvents = append(data.EyeEvents, models.EyeEvent{
+			ID:        fmt.Sprintf("w%d", int(x)/40),
+			X:         float32(x),
+			Y:         float32(y),
+			Timestamp: &t,
+		})
+	}
+	clock := *data.EyeEvents[hz-1].Timestamp
+	data.ClientClock = &clock
+
+	raw, _ := json.Marshal(models.SessionDataRequest{Timestamp: time.UnixMilli(1748764800000), Seq: 1, BatchID: "b-1", Data: &data})
+	return string(raw)
+}
@@ -0,0 +1,199 @@
+package main
+
+// 归档信封加密的往返与篡改检查
+// 用法: go run ./test/envelope
+// 对不同长度（包括空文件和恰好整块）的内容做加密 -> 解密往返，
+// 再对加密结果做截断、删除块、重排块、翻转密文、修改文件头和追加数据，检查每种情况都被拒绝，
+// 并确认 DecryptFile 校验失败时不会留下输出文件。
+import (
+	"NewsEyeTracking/internal/envelope"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// chunkSize 与 envelope 的默认分块大小一致，用于构造恰好整块和跨块的内容
+const chunkSize = 64 << 10
+
+func main() {
+	kw := newKeyWrapper("test-1")
+
+	failed := 0
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 17} {
+		if err := roundTrip(kw, size); err != nil {
+			fmt.Printf("FAIL round trip %d bytes: %v\n", size, err)
+			failed++
+			continue
+		}
+		fmt.Printf("ok   round trip %d bytes\n", size)
+	}
+
+	plain := randomBytes(3*chunkSize + 17)
+	var sealed bytes.Buffer
+	if _, err := envelope.Encrypt(&sealed, bytes.NewReader(plain), kw); err != nil {
+		fmt.Printf("FAIL encrypt: %v\n", err)
+		os.Exit(1)
+	}
+	headerEnd, chunks, err := splitChunks(sealed.Bytes())
+	if err != nil {
+		fmt.Printf("FAIL parse: %v\n", err)
+		os.Exit(1)
+	}
+	header := sealed.Bytes()[:headerEnd]
+
+	tampered := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated tail", sealed.Bytes()[:sealed.Len()-5]},
+		{"last chunk removed", join(header, chunks[:len(chunks)-1]...)},
+		{"middle chunk removed", join(header, chunks[0], chunks[2], chunks[3])},
+		{"chunks reordered", join(header, chunks[1], chunks[0], chunks[2], chunks[3])},
+		{"ciphertext bit flipped", flip(sealed.Bytes(), headerEnd+4+100)},
+		{"header modified", replaceOnce(sealed.Bytes(), []byte(`"chunk_size":65536`), []byte(`"chunk_size":65537`))},
+		{"trailing data", append(append([]byte(nil), sealed.Bytes()...), 0)},
+	}
+	for _, tc := range tampered {
+		_, err := envelope.Decrypt(&bytes.Buffer{}, bytes.NewReader(tc.data), kw)
+		if !errors.Is(err, envelope.ErrCorrupted) {
+			fmt.Printf("FAIL %s: 期望 ErrCorrupted，实际 %v\n", tc.name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("ok   %s rejected\n", tc.name)
+	}
+
+	if _, err := envelope.Decrypt(&bytes.Buffer{}, bytes.NewReader(sealed.Bytes()), newKeyWrapper("test-1")); err == nil {
+		fmt.Println("FAIL wrong master key: 解密成功")
+		failed++
+	} else {
+		fmt.Println("ok   wrong master key rejected")
+	}
+
+	if err := decryptFileLeavesNothing(kw, join(header, chunks[:len(chunks)-1]...)); err != nil {
+		fmt.Printf("FAIL decrypt file: %v\n", err)
+		failed++
+	} else {
+		fmt.Println("ok   decrypt file leaves no partial output")
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("all envelope checks passed")
+}
+
+func roundTrip(kw envelope.KeyWrapper, size int) error {
+	plain := randomBytes(size)
+	var sealed bytes.Buffer
+	if _, err := envelope.Encrypt(&sealed, bytes.NewReader(plain), kw); err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	var opened bytes.Buffer
+	header, err := envelope.Decrypt(&opened, bytes.NewReader(sealed.Bytes()), kw)
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	if header.KeyID != kw.KeyID() {
+		return fmt.Errorf("key id %q, want %q", header.KeyID, kw.KeyID())
+	}
+	if !bytes.Equal(opened.Bytes(), plain) {
+		return fmt.Errorf("解密结果与原文不一致（%d / %d bytes）", opened.Len(), len(plain))
+	}
+	return nil
+}
+
+// decryptFileLeavesNothing 解密被截断的文件应失败，且目标路径和临时文件都不存在
+func decryptFileLeavesNothing(kw envelope.KeyWrapper, data []byte) error {
+	dir, err := os.MkdirTemp("", "envelope")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "archive.zip"+envelope.FileExt)
+	dst := filepath.Join(dir, "archive.zip")
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		return err
+	}
+	if _, err := envelope.DecryptFile(src, dst, kw); !errors.Is(err, envelope.ErrCorrupted) {
+		return fmt.Errorf("期望 ErrCorrupted，实际 %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 {
+		return fmt.Errorf("目录中留下了 %d 个文件", len(entries)-1)
+	}
+	return nil
+}
+
+// splitChunks 按文件格式拆出文件头的结束位置和每个块（含长度前缀）
+func splitChunks(data []byte) (int, [][]byte, error) {
+	if len(data) < 9 {
+		return 0, nil, fmt.Errorf("文件过短")
+	}
+	offset := 9 + int(binary.BigEndian.Uint32(data[5:9]))
+	headerEnd := offset
+	var chunks [][]byte
+	for offset < len(data) {
+		if offset+4 > len(data) {
+			return 0, nil, fmt.Errorf("块长度不完整")
+		}
+		end := offset + 4 + int(binary.BigEndian.Uint32(data[offset:offset+4]))
+		if end > len(data) {
+			return 0, nil, fmt.Errorf("块不完整")
+		}
+		chunks = append(chunks, data[offset:end])
+		offset = end
+	}
+	if len(chunks) != 4 {
+		return 0, nil, fmt.Errorf("块数 %d，期望 4", len(chunks))
+	}
+	return headerEnd, chunks, nil
+}
+
+func join(header []byte, chunks ...[]byte) []byte {
+	out := append([]byte(nil), header...)
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	return out
+}
+
+func flip(data []byte, i int) []byte {
+	out := append([]byte(nil), data...)
+	out[i] ^= 0x01
+	return out
+}
+
+func replaceOnce(data, old, repl []byte) []byte {
+	if !bytes.Contains(data, old) {
+		fmt.Printf("FAIL 文件头中没有 %s\n", old)
+		os.Exit(1)
+	}
+	return bytes.Replace(data, old, repl, 1)
+}
+
+func newKeyWrapper(keyID string) envelope.KeyWrapper {
+	kw, err := envelope.NewLocalKeyWrapper(keyID, randomBytes(32))
+	if err != nil {
+		fmt.Printf("FAIL 创建主密钥失败: %v\n", err)
+		os.Exit(1)
+	}
+	return kw
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		fmt.Printf("FAIL 生成随机数据失败: %v\n", err)
+		os.Exit(1)
+	}
+	return b
+}
@@ -0,0 +1,204 @@
+package main
+
+// 刷新与上传并发时的数据完整性检查
+// 用法: go run ./test/sealrace [-users 8] [-batches 300] [-seal-every 20]
+// 多个 goroutine 模拟刷新任务向打开的文件追加记录，并不时封存（模拟会话结束），
+// 同时上传器使用本地存储后端反复强制上传。结束后解开所有压缩包和剩余的本地文件，
+// 检查每条记录恰好出现一次。
+import (
+	"NewsEyeTracking/internal/blobstore"
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"NewsEyeTracking/internal/utils"
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const recordsPerFlush = 5
+
+func main() {
+	users := flag.Int("users", 8, "并发写入的用户数")
+	batches := flag.Int("batches", 300, "每个用户的刷新次数")
+	sealEvery := flag.Int("seal-every", 20, "每隔多少次刷新封存一次")
+	keep := flag.Bool("keep", false, "保留临时目录")
+	flag.Parse()
+
+	root, err := os.MkdirTemp("", "sealrace")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !*keep {
+		defer os.RemoveAll(root)
+	}
+	log.SetOutput(io.Discard) // 上传器的日志太多，只看结果
+
+	trackingDir := filepath.Join(root, "tracking")
+	newsDir := filepath.Join(root, "news")
+	archiveDir := filepath.Join(root, "archive")
+	for _, dir := range []string{trackingDir, newsDir, filepath.Join(root, "upload")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fail("创建目录失败: %v", err)
+		}
+	}
+	os.Setenv("UPLOAD_LEDGER_PATH", filepath.Join(root, "ledger", "uploads.ndjson"))
+
+	store, err := blobstore.NewLocalStore(archiveDir)
+	if err != nil {
+		fail("创建本地存储失败: %v", err)
+	}
+	uploader := utils.NewUploader(utils.Config{
+		TrackingDir:   trackingDir,
+		NewsDir:       newsDir,
+		UploadDir:     filepath.Join(root, "upload"),
+		CheckInterval: time.Hour,
+		Store:         store,
+	})
+
+	// 写入端：与 flushTrackingCache 相同，按 <date>/<user>.open.ndjson 追加，会话结束时封存
+	today := time.Now().Format("2006-01-02")
+	sessions := make([]uuid.UUID, *users)
+	var writers sync.WaitGroup
+	for i := range sessions {
+		sessions[i] = uuid.New()
+		writers.Add(1)
+		go func(userID string, sessionID uuid.UUID) {
+			defer writers.Done()
+			path := storage.UserFilePath(trackingDir, today, userID)
+			var seq uint64
+			for b := 1; b <= *batches; b++ {
+				records := make([]models.UserTrackingRecord, recordsPerFlush)
+				for j := range records {
+					seq++
+					records[j] = models.UserTrackingRecord{SessionID: sessionID, StartTime: time.Now(), Seq: seq}
+				}
+				if err := storage.AppendRecords(path, storage.TrackingHeader(), records); err != nil {
+					fail("写入失败: %v", err)
+				}
+				if b%*sealEvery == 0 {
+					if _, err := storage.Seal(path); err != nil {
+						fail("封存失败: %v", err)
+					}
+				}
+			}
+		}(fmt.Sprintf("user%02d", i), sessions[i])
+	}
+
+	// 上传端：写入期间不停地强制上传
+	done := make(chan struct{})
+	var uploads sync.WaitGroup
+	uploads.Add(1)
+	go func() {
+		defer uploads.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := uploader.ForceUploadAll(); err != nil {
+				fail("上传失败: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	writers.Wait()
+	close(done)
+	uploads.Wait()
+
+	// 模拟跨天：封存剩余的打开文件并做最后一次上传
+	if _, err := storage.SealStaleFiles(trackingDir, "9999-12-31"); err != nil {
+		fail("封存剩余文件失败: %v", err)
+	}
+	if err := uploader.ForceUploadAll(); err != nil {
+		fail("最后一次上传失败: %v", err)
+	}
+
+	seen := make(map[uuid.UUID]map[uint64]int)
+	count := func(record models.UserTrackingRecord) {
+		if seen[record.SessionID] == nil {
+			seen[record.SessionID] = make(map[uint64]int)
+		}
+		seen[record.SessionID][record.Seq]++
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(archiveDir, "tracking", "*.zip"))
+	for _, path := range archives {
+		if err := readArchive(path, count); err != nil {
+			fail("读取压缩包 %s 失败: %v", path, err)
+		}
+	}
+	leftover := 0
+	filepath.Walk(trackingDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		leftover++
+		return storage.ReadFile(path, func(_ storage.FileHeader, record models.UserTrackingRecord) error {
+			count(record)
+			return nil
+		})
+	})
+
+	expected := uint64(*batches * recordsPerFlush)
+	missing, duplicated := 0, 0
+	for _, sessionID := range sessions {
+		for seq := uint64(1); seq <= expected; seq++ {
+			switch n := seen[sessionID][seq]; {
+			case n == 0:
+				missing++
+			case n > 1:
+				duplicated++
+			}
+		}
+	}
+
+	fmt.Printf("写入 %d 条记录，压缩包 %d 个，剩余本地文件 %d 个，缺失 %d 条，重复 %d 条\n",
+		int(expected)*len(sessions), len(archives), leftover, missing, duplicated)
+	if missing > 0 || duplicated > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
+
+func readArchive(path string, fn func(models.UserTrackingRecord)) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		reader, err := storage.NewReader[models.UserTrackingRecord](rc)
+		if err != nil {
+			rc.Close()
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+		for reader.Next() {
+			fn(reader.Record())
+		}
+		rc.Close()
+		if err := reader.Err(); err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+	return nil
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Printf("FAIL "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,261 @@
+package main
+
+// 追踪数据预写日志的写入、轮转与重放检查
+// 用法: go run ./test/walreplay [-users 4] [-records 50]
+// 模拟刷新失败时保留的旧段、进程崩溃时被截断的最后一帧以及跨天时切换的段文件，
+// 检查重放后每个批次恰好恢复一次、同一用户的记录保持顺序，并且所有段文件都被删除。
+import (
+	"NewsEyeTracking/internal/models"
+	"NewsEyeTracking/internal/storage"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	users := flag.Int("users", 4, "写入的用户数")
+	records := flag.Int("records", 50, "每个用户的记录数")
+	flag.Parse()
+
+	log.SetOutput(io.Discard) // 截断的段会打印警告，只看结果
+
+	checks := []struct {
+		name string
+		run  func() error
+	}{
+		{"replay retained segments and torn tail", func() error { return checkReplay(*users, *records) }},
+		{"duplicate batch across segments", checkDuplicate},
+		{"day rollover removes closed segments", checkRollover},
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if err := check.run(); err != nil {
+			fmt.Printf("FAIL %s: %v\n", check.name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("ok   %s\n", check.name)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("all wal checks passed")
+}
+
+// checkReplay 前一半记录轮转后不删除（刷新失败时保留的旧段），后一半写入新段，
+// 截掉最后一个段的最后几个字节模拟崩溃，重放后应恢复除被截断的一条之外的全部记录
+func checkReplay(users, perUser int) error {
+	dir, cleanup := tempDir()
+	defer cleanup()
+
+	wal, err := storage.OpenWAL(dir, 2)
+	if err != nil {
+		return err
+	}
+	sessionID := uuid.New()
+	for i := 0; i < perUser; i++ {
+		if i == perUser/2 {
+			if _, err := wal.Rotate(); err != nil {
+				return err
+			}
+		}
+		for u := 0; u < users; u++ {
+			if err := wal.Append(userName(u), newRecord(sessionID, u, i)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := wal.Close(); err != nil {
+		return err
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("没有生成段文件")
+	}
+	torn := segments[len(segments)-1]
+	info, err := os.Stat(torn)
+	if err != nil {
+		return err
+	}
+	if err := os.Truncate(torn, info.Size()-3); err != nil {
+		return err
+	}
+
+	seen, order, replayed, err := replay(dir)
+	if err != nil {
+		return err
+	}
+
+	if want := users*perUser - 1; replayed != want {
+		return fmt.Errorf("重放 %d 条记录，期望 %d 条", replayed, want)
+	}
+	for key, n := range seen {
+		if n > 1 {
+			return fmt.Errorf("批次 %s 重放了 %d 次", key, n)
+		}
+	}
+	for user, seqs := range order {
+		if !sort.SliceIsSorted(seqs, func(i, j int) bool { return seqs[i] < seqs[j] }) {
+			return fmt.Errorf("用户 %s 的记录顺序错乱: %v", user, seqs)
+		}
+	}
+	return expectNoSegments(dir)
+}
+
+// checkDuplicate 同一批次同时出现在保留的旧段和重新写入的新段中，重放时只应恢复一次
+func checkDuplicate() error {
+	dir, cleanup := tempDir()
+	defer cleanup()
+
+	wal, err := storage.OpenWAL(dir, 1)
+	if err != nil {
+		return err
+	}
+	sessionID := uuid.New()
+	record := newRecord(sessionID, 0, 0)
+	if err := wal.Append(userName(0), record); err != nil {
+		return err
+	}
+	if _, err := wal.Rotate(); err != nil {
+		return err
+	}
+	if err := wal.Append(userName(0), record); err != nil {
+		return err
+	}
+	// 没有批次ID的旧客户端记录无法去重，两次都应恢复
+	legacy := newRecord(sessionID, 0, 1)
+	legacy.BatchID = ""
+	for i := 0; i < 2; i++ {
+		if err := wal.Append(userName(0), legacy); err != nil {
+			return err
+		}
+	}
+	if err := wal.Close(); err != nil {
+		return err
+	}
+
+	_, _, replayed, err := replay(dir)
+	if err != nil {
+		return err
+	}
+	if replayed != 3 {
+		return fmt.Errorf("重放 %d 条记录，期望 3 条", replayed)
+	}
+	return expectNoSegments(dir)
+}
+
+// checkRollover 跨天后旧段被关闭，下一次 Rotate 应同时返回新旧两天的段，Remove 后不留下段文件和旧日期目录
+func checkRollover() error {
+	dir, cleanup := tempDir()
+	defer cleanup()
+
+	wal, err := storage.OpenWAL(dir, 1)
+	if err != nil {
+		return err
+	}
+	day := time.Now().AddDate(0, 0, -1)
+	wal.SetClock(func() time.Time { return day })
+
+	sessionID := uuid.New()
+	for i := 0; i < 6; i++ {
+		if i == 3 {
+			day = day.AddDate(0, 0, 1)
+		}
+		if err := wal.Append(userName(0), newRecord(sessionID, 0, i)); err != nil {
+			return err
+		}
+	}
+
+	segments, err := wal.Rotate()
+	if err != nil {
+		return err
+	}
+	if len(segments) != 2 {
+		return fmt.Errorf("Rotate 返回 %d 个段，期望 2 个: %v", len(segments), segments)
+	}
+	if err := wal.Remove(segments); err != nil {
+		return err
+	}
+	if err := expectNoSegments(dir); err != nil {
+		return err
+	}
+	oldDir := filepath.Join(dir, day.AddDate(0, 0, -1).Format("2006-01-02"))
+	if _, err := os.Stat(oldDir); !os.IsNotExist(err) {
+		return fmt.Errorf("旧日期目录 %s 没有删除", oldDir)
+	}
+	return nil
+}
+
+// replay 重放目录中的段，返回每个批次的次数、每个用户的序号顺序和重放的记录数
+func replay(dir string) (map[string]int, map[string][]uint64, int, error) {
+	wal, err := storage.OpenWAL(dir, 1)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	seen := make(map[string]int)
+	order := make(map[string][]uint64)
+	replayed, err := wal.Replay(func(entries []storage.WALEntry) error {
+		for _, entry := range entries {
+			if entry.Record.BatchID != "" {
+				seen[entry.UserID+"/"+entry.Record.BatchID]++
+			}
+			order[entry.UserID] = append(order[entry.UserID], entry.Record.Seq)
+		}
+		return nil
+	})
+	return seen, order, replayed, err
+}
+
+func newRecord(sessionID uuid.UUID, user, i int) *models.UserTrackingRecord {
+	return &models.UserTrackingRecord{
+		SessionID: sessionID,
+		StartTime: time.Now(),
+		Seq:       uint64(i + 1),
+		BatchID:   fmt.Sprintf("b-%d-%d", user, i),
+		Data: models.TrackingData{
+			EyeEvents: []models.EyeEvent{{ID: "w1", X: float32(i), Y: float32(user)}},
+		},
+	}
+}
+
+func userName(u int) string {
+	return fmt.Sprintf("user-%02d", u)
+}
+
+func listSegments(dir string) ([]string, error) {
+	segments, err := filepath.Glob(filepath.Join(dir, "*", "*.seg"))
+	sort.Strings(segments)
+	return segments, err
+}
+
+func expectNoSegments(dir string) error {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return err
+	}
+	if len(segments) > 0 {
+		return fmt.Errorf("仍有 %d 个段文件: %v", len(segments), segments)
+	}
+	return nil
+}
+
+func tempDir() (string, func()) {
+	dir, err := os.MkdirTemp("", "walreplay")
+	if err != nil {
+		fmt.Printf("FAIL 创建临时目录失败: %v\n", err)
+		os.Exit(1)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
@@ -0,0 +1,97 @@
+package main
+
+// WebSocket 上传通道的压测客户端
+// 用法: go run ./test/wsclient -session <阅读会话ID> -token <JWT> [-hz 120] [-batch 30] [-duration 30s]
+import (
+	"NewsEyeTracking/internal/models"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func main() {
+	server := flag.String("server", "ws://localhost:8080", "服务端地址")
+	sessionID := flag.String("session", "", "阅读会话ID")
+	token := flag.String("token", "", "参与者 JWT")
+	hz := flag.Int("hz", 120, "模拟的眼动仪采样率")
+	batch := flag.Int("batch", 30, "每帧包含的采样数")
+	duration := flag.Duration("duration", 30*time.Second, "运行时长")
+	flag.Parse()
+
+	if *sessionID == "" || *token == "" {
+		log.Fatal("必须提供 -session 和 -token")
+	}
+
+	target := fmt.Sprintf("%s/api/v1/sessions/%s/ws?access_token=%s", *server, *sessionID, url.QueryEscape(*token))
+	conn, _, err := websocket.DefaultDialer.Dial(target, nil)
+	if err != nil {
+		log.Fatalf("连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	acked := make(chan uint64, 1024)
+	go func() {
+		defer close(acked)
+		for {
+			var reply models.TrackingFrameReply
+			if err := conn.ReadJSON(&reply); err != nil {
+				return
+			}
+			if reply.Type == models.FrameTypeError {
+				log.Printf("帧 %d 失败: %s %s", reply.Seq, reply.Code, reply.Message)
+				continue
+			}
+			acked <- reply.Seq
+		}
+	}()
+
+	interval := time.Duration(*batch) * time.Second / time.Duration(*hz)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+
+	var seq, ackCount uint64
+	start := time.Now()
+	clock := 0.0
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case _, ok := <-acked:
+			if !ok {
+				log.Println("连接已关闭")
+				break loop
+			}
+			ackCount++
+		case <-ticker.C:
+			seq++
+			data := &models.TrackingData{SamplingRate: float32(*hz)}
+			for i := 0; i < *batch; i++ {
+				t := clock
+				clock += 1000 / float64(*hz)
+				data.EyeEvents = append(data.EyeEvents, models.EyeEvent{
+					X:         rand.Float32() * 1920,
+					Y:         rand.Float32() * 1080,
+					Timestamp: &t,
+				})
+			}
+			last := clock - 1000/float64(*hz)
+			data.ClientClock = &last
+
+			frame := models.TrackingFrame{Type: models.FrameTypeData, Seq: seq, Timestamp: time.Now(), Data: data}
+			if err := conn.WriteJSON(frame); err != nil {
+				log.Printf("发送失败: %v", err)
+				break loop
+			}
+		}
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	fmt.Printf("发送 %d 帧, 收到 %d 个 ack, 用时 %s\n", seq, ackCount, time.Since(start).Round(time.Millisecond))
+}